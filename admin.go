@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+type adminCommand struct {
+	name        string
+	description string
+	run         func(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error
+}
+
+func getAdminCommands() []adminCommand {
+	return []adminCommand{
+		{"create-user", "create a new user from -email, -password and an optional -role", adminCreateUser},
+		{"reset-password", "set a new -password for the user with -email", adminResetPassword},
+		{"grant-red", "give the user with -email Chirpy Red", adminGrantRed},
+		{"revoke-red", "take Chirpy Red away from the user with -email", adminRevokeRed},
+		{"set-role", "set the -role (user, moderator, admin) of the user with -email", adminSetRole},
+		{"revoke-sessions", "revoke all refresh tokens of the user with -email and close their WebSocket connections", adminRevokeSessions},
+		{"delete-chirp", "delete the chirp with -id", adminDeleteChirp},
+		{"delete-user", "delete the user with -email together with their chirps and uploads", adminDeleteUser},
+		{"seed", "fill the database with fake users and chirps for development", adminSeed},
+	}
+}
+
+// runAdmin is the entry point for "chirpy admin <command> [flags]", commands change the storage of cfg
+// and publish their events on its bus like the server does
+func runAdmin(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		printAdminUsage(out)
+		return errors.New("no admin command given")
+	}
+	for _, c := range getAdminCommands() {
+		if c.name == args[0] {
+			return c.run(ctx, cfg, out, args[1:])
+		}
+	}
+	printAdminUsage(out)
+	return fmt.Errorf("unknown admin command: '%s'", args[0])
+}
+
+func printAdminUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: chirpy admin <command> [flags]")
+	fmt.Fprintln(out, "Commands:")
+	for _, c := range getAdminCommands() {
+		fmt.Fprintf(out, "  %-16s %s\n", c.name, c.description)
+	}
+}
+
+// parseAdminFlags parses the flags of a command and checks that all required ones were set
+func parseAdminFlags(fs *flag.FlagSet, args []string, required ...string) error {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Name(), err)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, name := range required {
+		if !set[name] {
+			return fmt.Errorf("%s: flag -%s is required", fs.Name(), name)
+		}
+	}
+	return nil
+}
+
+func adminCreateUser(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the new user")
+	password := fs.String("password", "", "password of the new user")
+	role := fs.String("role", string(auth.RoleUser), "role of the new user")
+	err := parseAdminFlags(fs, args, "email", "password")
+	if err != nil {
+		return err
+	}
+	userRole, err := auth.ParseRole(*role)
+	if err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(*password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+	user, err := cfg.db.CreateUser(ctx, database.CreateUserParams{
+		Email:          *email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating user: %w", err)
+	}
+	if userRole != auth.RoleUser {
+		user, err = cfg.db.SetUserRole(ctx, database.SetUserRoleParams{
+			ID:   user.ID,
+			Role: string(userRole),
+		})
+		if err != nil {
+			return fmt.Errorf("error setting role: %w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Created %s %s (%s)\n", user.Role, user.Email, user.ID)
+	return nil
+}
+
+func adminResetPassword(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "new password of the user")
+	err := parseAdminFlags(fs, args, "email", "password")
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	hash, err := auth.HashPassword(*password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+	_, err = cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	fmt.Fprintf(out, "Reset password of user %s\n", user.Email)
+	return nil
+}
+
+func adminGrantRed(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("grant-red", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	err := parseAdminFlags(fs, args, "email")
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	err = cfg.db.UpgradeUserToRed(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error upgrading user: %w", err)
+	}
+
+	fmt.Fprintf(out, "User %s is now Chirpy Red\n", user.Email)
+	return nil
+}
+
+func adminRevokeRed(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("revoke-red", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	err := parseAdminFlags(fs, args, "email")
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	err = cfg.db.DowngradeUserFromRed(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error downgrading user: %w", err)
+	}
+
+	fmt.Fprintf(out, "User %s is no longer Chirpy Red\n", user.Email)
+	return nil
+}
+
+func adminSetRole(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	role := fs.String("role", "", "new role of the user")
+	err := parseAdminFlags(fs, args, "email", "role")
+	if err != nil {
+		return err
+	}
+	userRole, err := auth.ParseRole(*role)
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	_, err = cfg.db.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: string(userRole),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting role: %w", err)
+	}
+
+	fmt.Fprintf(out, "User %s is now %s\n", user.Email, userRole)
+	return nil
+}
+
+func adminRevokeSessions(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("revoke-sessions", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	err := parseAdminFlags(fs, args, "email")
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	revoked, err := cfg.db.RevokeAllRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+	cfg.publish(ctx, events.SessionRevoked, user.ID, sessionRevoked{
+		UserID:    user.ID,
+		RevokedAt: time.Now().UTC(),
+		All:       true,
+	})
+
+	fmt.Fprintf(out, "Revoked %d session(s) of user %s\n", revoked, user.Email)
+	return nil
+}
+
+func adminDeleteChirp(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("delete-chirp", flag.ContinueOnError)
+	id := fs.String("id", "", "id of the chirp")
+	err := parseAdminFlags(fs, args, "id")
+	if err != nil {
+		return err
+	}
+
+	chirpID, err := uuid.Parse(*id)
+	if err != nil {
+		return fmt.Errorf("not a valid chirp id '%s': %w", *id, err)
+	}
+	chirp, err := cfg.db.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return fmt.Errorf("error fetching chirp '%s': %w", chirpID, err)
+	}
+	err = cfg.deleteChirp(ctx, chirp)
+	if err != nil {
+		return fmt.Errorf("error deleting chirp: %w", err)
+	}
+
+	fmt.Fprintf(out, "Deleted chirp %s\n", chirpID)
+	return nil
+}
+
+func adminDeleteUser(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("delete-user", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	err := parseAdminFlags(fs, args, "email")
+	if err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserByEMail(ctx, *email)
+	if err != nil {
+		return fmt.Errorf("error fetching user '%s': %w", *email, err)
+	}
+	// the chirps are deleted one by one before the user, so their files go and clients are told
+	chirps, err := cfg.db.GetChirpsByAuthor(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching chirps: %w", err)
+	}
+	for _, chirp := range chirps {
+		err = cfg.deleteChirp(ctx, chirp)
+		if err != nil {
+			return fmt.Errorf("error deleting chirp %s: %w", chirp.ID, err)
+		}
+	}
+	err = cfg.db.DeleteUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+	cfg.deleteUploadedAvatar(ctx, user.AvatarUrl)
+	cfg.publish(ctx, events.SessionRevoked, user.ID, sessionRevoked{
+		UserID:    user.ID,
+		RevokedAt: time.Now().UTC(),
+		All:       true,
+	})
+
+	fmt.Fprintf(out, "Deleted user %s and %d chirp(s)\n", user.Email, len(chirps))
+	return nil
+}
+
+func getSeedNames() ([]string, []string) {
+	first := []string{"ada", "alan", "grace", "linus", "margaret", "ken", "barbara", "dennis", "radia", "edsger", "frances", "john"}
+	last := []string{"lovelace", "turing", "hopper", "torvalds", "hamilton", "thompson", "liskov", "ritchie", "perlman", "dijkstra", "allen", "mccarthy"}
+	return first, last
+}
+
+func getSeedChirps() []string {
+	return []string{
+		"Just shipped a new feature, time for coffee",
+		"Is it just me or are Mondays getting longer?",
+		"Reading a great book about distributed systems",
+		"The sunset today was unreal",
+		"Anyone else still debugging at midnight?",
+		"Hot take: tabs are better than spaces",
+		"Learning Go has been so much fun",
+		"My cat just walked over the keyboard and fixed a bug",
+		"Finally cleaned up my desk, productivity incoming",
+		"Trying out a new recipe tonight, wish me luck",
+		"Weekend plans: hiking and absolutely no screens",
+		"Who else loves a good rainy day?",
+	}
+}
+
+func adminSeed(ctx context.Context, cfg *apiConfig, out io.Writer, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := fs.Int("users", 10, "number of users to create")
+	chirps := fs.Int("chirps", 5, "number of chirps to create per user")
+	password := fs.String("password", "password", "password of all seeded users")
+	err := parseAdminFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *users < 0 || *chirps < 0 {
+		return errors.New("seed: -users and -chirps must not be negative")
+	}
+
+	// all seeded users share a password, so it only needs to be hashed once
+	hash, err := auth.HashPassword(*password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	first, last := getSeedNames()
+	bodies := getSeedChirps()
+	for range *users {
+		// the id keeps emails unique when seeding more than once
+		email := fmt.Sprintf("%s.%s.%s@example.com",
+			first[rand.IntN(len(first))],
+			last[rand.IntN(len(last))],
+			uuid.New(),
+		)
+		user, err := cfg.db.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hash,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating user '%s': %w", email, err)
+		}
+		if rand.IntN(4) == 0 {
+			err = cfg.db.UpgradeUserToRed(ctx, user.ID)
+			if err != nil {
+				return fmt.Errorf("error upgrading user '%s': %w", email, err)
+			}
+		}
+
+		for range *chirps {
+			_, err = cfg.db.CreateChirp(ctx, database.CreateChirpParams{
+				Body:       bodies[rand.IntN(len(bodies))],
+				UserID:     user.ID,
+				Visibility: visibilityPublic,
+			})
+			if err != nil {
+				return fmt.Errorf("error creating chirp for '%s': %w", email, err)
+			}
+		}
+		fmt.Fprintf(out, "Seeded user %s with %d chirp(s)\n", email, *chirps)
+	}
+
+	fmt.Fprintf(out, "Seeded %d user(s) with password '%s'\n", *users, *password)
+	return nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// SendPolkaWebhook sends a Polka payment event, apiKey is the POLKA_KEY of the server
+func (c *Client) SendPolkaWebhook(ctx context.Context, apiKey, event string, userID uuid.UUID) error {
+	type data struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	return c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/polka/webhooks",
+		body: struct {
+			Event string `json:"event"`
+			Data  data   `json:"data"`
+		}{event, data{userID}},
+		header: http.Header{"Authorization": {"ApiKey " + apiKey}},
+	}, nil)
+}
+
+// OpenAPI returns the OpenAPI document of the server
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	var doc []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/openapi.json"}, &doc)
+	return doc, err
+}
+
+// Metrics returns the admin metrics page, the logged in user has to be an admin
+func (c *Client) Metrics(ctx context.Context) (string, error) {
+	var page []byte
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/admin/metrics",
+		auth:   authAccess,
+	}, &page)
+	return string(page), err
+}
+
+// Reset deletes all users, the logged in user has to be an admin and the server has to run on the dev platform
+func (c *Client) Reset(ctx context.Context) error {
+	return c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/admin/reset",
+		auth:   authAccess,
+	}, nil)
+}
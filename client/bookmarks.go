@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Bookmark struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// CollectionID is nil if the bookmark is in no collection
+	CollectionID *uuid.UUID `json:"collection_id"`
+	Chirp        Chirp      `json:"chirp"`
+}
+
+type BookmarkPage struct {
+	Bookmarks []Bookmark `json:"bookmarks"`
+	// NextCursor is nil on the last page
+	NextCursor *uuid.UUID `json:"next_cursor"`
+}
+
+type Collection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+// BookmarkChirp saves a chirp for the logged in user in one of their collections, in none if collectionID is uuid.Nil.
+// Bookmarking a chirp again moves it to the collection.
+func (c *Client) BookmarkChirp(ctx context.Context, chirpID, collectionID uuid.UUID) (Bookmark, error) {
+	params := struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}{}
+	if collectionID != uuid.Nil {
+		params.CollectionID = &collectionID
+	}
+	bookmark := Bookmark{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps/" + chirpID.String() + "/bookmark",
+		body:   params,
+		auth:   authAccess,
+	}, &bookmark)
+	return bookmark, err
+}
+
+func (c *Client) RemoveBookmark(ctx context.Context, chirpID uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/chirps/" + chirpID.String() + "/bookmark",
+		auth:   authAccess,
+	}, nil)
+}
+
+// ListBookmarks returns a page of the bookmarks of the logged in user, the last saved first,
+// only those in the collection unless collectionID is uuid.Nil
+func (c *Client) ListBookmarks(ctx context.Context, collectionID uuid.UUID, opts PageOptions) (BookmarkPage, error) {
+	query := opts.query()
+	if collectionID != uuid.Nil {
+		query.Set("collection_id", collectionID.String())
+	}
+	page := BookmarkPage{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/bookmarks",
+		query:  query,
+		auth:   authAccess,
+	}, &page)
+	return page, err
+}
+
+func (c *Client) CreateCollection(ctx context.Context, name string) (Collection, error) {
+	return c.saveCollection(ctx, http.MethodPost, "/api/collections", name)
+}
+
+func (c *Client) RenameCollection(ctx context.Context, id uuid.UUID, name string) (Collection, error) {
+	return c.saveCollection(ctx, http.MethodPut, "/api/collections/"+id.String(), name)
+}
+
+func (c *Client) saveCollection(ctx context.Context, method, path, name string) (Collection, error) {
+	collection := Collection{}
+	err := c.do(ctx, request{
+		method: method,
+		path:   path,
+		body: struct {
+			Name string `json:"name"`
+		}{name},
+		auth: authAccess,
+	}, &collection)
+	return collection, err
+}
+
+// ListCollections lists the collections of the logged in user by name
+func (c *Client) ListCollections(ctx context.Context) ([]Collection, error) {
+	collections := []Collection{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/collections",
+		auth:   authAccess,
+	}, &collections)
+	return collections, err
+}
+
+// DeleteCollection deletes a collection of the logged in user, its bookmarks are kept in no collection
+func (c *Client) DeleteCollection(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/collections/" + id.String(),
+		auth:   authAccess,
+	}, nil)
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Who a chirp is shown to, see CreateChirpWithVisibility
+const (
+	VisibilityPublic = "public"
+	// unlisted chirps can be opened by id but are left out of ListChirps and streams
+	VisibilityUnlisted = "unlisted"
+	// followers-only chirps are only shown to the author and their followers
+	VisibilityFollowers = "followers"
+)
+
+// CreateChirp posts a chirp with up to four images from UploadMedia
+func (c *Client) CreateChirp(ctx context.Context, body string, mediaIDs ...uuid.UUID) (Chirp, error) {
+	return c.createChirp(ctx, body, nil, nil, nil, "", mediaIDs)
+}
+
+// CreateChirpWithVisibility posts a chirp that is not shown to everyone
+func (c *Client) CreateChirpWithVisibility(ctx context.Context, body, visibility string, mediaIDs ...uuid.UUID) (Chirp, error) {
+	return c.createChirp(ctx, body, nil, nil, nil, visibility, mediaIDs)
+}
+
+// ScheduleChirp posts a chirp that is published at publishAt, until then only the author sees it
+func (c *Client) ScheduleChirp(ctx context.Context, body string, publishAt time.Time, mediaIDs ...uuid.UUID) (Chirp, error) {
+	return c.createChirp(ctx, body, &publishAt, nil, nil, "", mediaIDs)
+}
+
+// createChirp posts a chirp, an empty visibility is public
+func (c *Client) createChirp(ctx context.Context, body string, publishAt *time.Time, poll *NewPoll, warning *Warning, visibility string, mediaIDs []uuid.UUID) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps",
+		body: struct {
+			Body       string      `json:"body"`
+			MediaIDs   []uuid.UUID `json:"media_ids,omitempty"`
+			PublishAt  *time.Time  `json:"publish_at,omitempty"`
+			Poll       *NewPoll    `json:"poll,omitempty"`
+			Visibility string      `json:"visibility,omitempty"`
+			*Warning
+		}{body, mediaIDs, publishAt, poll, visibility, warning},
+		auth: authAccess,
+	}, &chirp)
+	return chirp, err
+}
+
+type ListChirpsOptions struct {
+	// only list chirps of this author if set
+	AuthorID uuid.UUID
+	// "asc" (default) or "desc" by creation time
+	Sort string
+}
+
+func (c *Client) ListChirps(ctx context.Context, opts ListChirpsOptions) ([]Chirp, error) {
+	query := url.Values{}
+	if opts.AuthorID != uuid.Nil {
+		query.Set("author_id", opts.AuthorID.String())
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	chirps := []Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/chirps",
+		query:  query,
+	}, &chirps)
+	return chirps, err
+}
+
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/chirps/" + id.String(),
+	}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes a chirp of the logged in user
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/chirps/" + id.String(),
+		auth:   authAccess,
+	}, nil)
+}
+
+// ListScheduledChirps lists the chirps of the logged in user that are not published yet
+func (c *Client) ListScheduledChirps(ctx context.Context) ([]Chirp, error) {
+	chirps := []Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/chirps/scheduled",
+		auth:   authAccess,
+	}, &chirps)
+	return chirps, err
+}
+
+// CancelScheduledChirp deletes a scheduled chirp, it fails with CodeConflict once the chirp is published
+func (c *Client) CancelScheduledChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/chirps/scheduled",
+		query:  url.Values{"chirp_id": {id.String()}},
+		auth:   authAccess,
+	}, nil)
+}
+
+// PublishScheduledChirp publishes a scheduled chirp right away, it fails with CodeConflict once the chirp is published
+func (c *Client) PublishScheduledChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps/scheduled/" + id.String() + "/publish",
+		auth:   authAccess,
+	}, &chirp)
+	return chirp, err
+}
+
+// PinChirp pins a chirp of the logged in user, ListChirps with their AuthorID lists it first.
+// It fails with CodeTooManyPins if they pinned as many chirps as they can.
+func (c *Client) PinChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users/me/pins/" + id.String(),
+		auth:   authAccess,
+	}, &chirp)
+	return chirp, err
+}
+
+func (c *Client) UnpinChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/users/me/pins/" + id.String(),
+		auth:   authAccess,
+	}, nil)
+}
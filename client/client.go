@@ -0,0 +1,244 @@
+// Package client is a Go client for the Chirpy API.
+//
+// A Client keeps the access and refresh token of the last login and renews
+// the access token on its own once it expires.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Role        string    `json:"role"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	// SensitiveContent is one of the Sensitive constants
+	SensitiveContent string `json:"sensitive_content"`
+}
+
+// Profile is what everybody can see of a user
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	Media     []Media   `json:"media"`
+	// PublishAt is only set while the chirp is scheduled
+	PublishAt *time.Time `json:"publish_at"`
+	// Poll is nil if the chirp has none
+	Poll *Poll `json:"poll"`
+	// Pinned chirps are listed first among the chirps of their author
+	Pinned bool `json:"pinned"`
+	// ContentWarning is nil if the chirp has none
+	ContentWarning *string `json:"content_warning"`
+	Sensitive      bool    `json:"sensitive"`
+	// Collapsed chirps should only show their warning until the reader expands them
+	Collapsed bool `json:"collapsed"`
+	// Visibility is VisibilityPublic, VisibilityUnlisted or VisibilityFollowers
+	Visibility string `json:"visibility"`
+}
+
+// Media is an uploaded image, URL and ThumbnailURL are paths on the server
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTokens starts the client with tokens from an earlier login
+func WithTokens(accessToken, refreshToken string) Option {
+	return func(c *Client) {
+		c.accessToken = accessToken
+		c.refreshToken = refreshToken
+	}
+}
+
+// New creates a client for the server at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tokens returns the current access and refresh token
+func (c *Client) Tokens() (accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, c.refreshToken
+}
+
+func (c *Client) setTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = accessToken
+	c.refreshToken = refreshToken
+}
+
+// authMode tells do which Authorization header a request needs
+type authMode int
+
+const (
+	authNone authMode = iota
+	authAccess
+	authRefresh
+)
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// body is encoded as JSON, unless it is a []byte which is sent as contentType
+	body        any
+	contentType string
+	auth        authMode
+	header      http.Header
+}
+
+// do sends the request and decodes a successful response into out, which may be nil.
+// Requests with an access token are sent a second time after a refresh if the token was rejected.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if raw, ok := req.body.([]byte); ok {
+		body = raw
+	} else if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+	}
+
+	resp, err := c.send(ctx, req, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && req.auth == authAccess && c.canRefresh() {
+		resp.Body.Close()
+		err = c.Refresh(ctx)
+		if err != nil {
+			return err
+		}
+		resp, err = c.send(ctx, req, body)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) canRefresh() bool {
+	_, refreshToken := c.Tokens()
+	return refreshToken != ""
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+	if body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	accessToken, refreshToken := c.Tokens()
+	switch req.auth {
+	case authAccess:
+		if accessToken == "" {
+			return nil, ErrNotLoggedIn
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+	case authRefresh:
+		if refreshToken == "" {
+			return nil, ErrNotLoggedIn
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+refreshToken)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenRenewal(t *testing.T) {
+	refreshes := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer refresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		refreshes++
+		w.Write([]byte(`{"token": "fresh"}`))
+	})
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status": 401, "code": "invalid_token"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"body": "hello"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := New(srv.URL, WithTokens("expired", "refresh"))
+	chirp, err := c.CreateChirp(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("CreateChirp() error = %v", err)
+	}
+	if chirp.Body != "hello" {
+		t.Errorf("CreateChirp() body = %q, want %q", chirp.Body, "hello")
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+	if access, _ := c.Tokens(); access != "fresh" {
+		t.Errorf("access token = %q, want %q", access, "fresh")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"type": "urn:chirpy:problem:email_taken", "status": 409, "detail": "Email is already taken", "code": "email_taken"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err := c.CreateUser(context.Background(), "user@example.com", "password")
+	if !IsCode(err, CodeEmailTaken) {
+		t.Errorf("CreateUser() error = %v, want code %s", err, CodeEmailTaken)
+	}
+
+	_, err = c.CreateChirp(context.Background(), "hello")
+	if err != ErrNotLoggedIn {
+		t.Errorf("CreateChirp() error = %v, want %v", err, ErrNotLoggedIn)
+	}
+}
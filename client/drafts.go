@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Draft is a chirp that is still being written, only its author sees it
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+type draftBody struct {
+	Body string `json:"body"`
+}
+
+func (c *Client) CreateDraft(ctx context.Context, body string) (Draft, error) {
+	draft := Draft{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/drafts",
+		body:   draftBody{body},
+		auth:   authAccess,
+	}, &draft)
+	return draft, err
+}
+
+// ListDrafts lists the drafts of the logged in user, the last edited first
+func (c *Client) ListDrafts(ctx context.Context) ([]Draft, error) {
+	drafts := []Draft{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/drafts",
+		auth:   authAccess,
+	}, &drafts)
+	return drafts, err
+}
+
+func (c *Client) UpdateDraft(ctx context.Context, id uuid.UUID, body string) (Draft, error) {
+	draft := Draft{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/drafts/" + id.String(),
+		body:   draftBody{body},
+		auth:   authAccess,
+	}, &draft)
+	return draft, err
+}
+
+func (c *Client) DeleteDraft(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   "/api/drafts/" + id.String(),
+		auth:   authAccess,
+	}, nil)
+}
+
+// PublishDraft turns the draft into a chirp, it fails with CodeConflict if the draft changed at the same time
+func (c *Client) PublishDraft(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/drafts/" + id.String() + "/publish",
+		auth:   authAccess,
+	}, &chirp)
+	return chirp, err
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrNotLoggedIn is returned when a request needs a token the client does not have
+var ErrNotLoggedIn = errors.New("client: not logged in")
+
+// error codes of the API, see the "code" of an Error
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeRequestTooLarge    = "request_too_large"
+	CodeValidationFailed   = "validation_failed"
+	CodeRequired           = "required"
+	CodeInvalidEmail       = "invalid_email"
+	CodeInvalidPassword    = "invalid_password"
+	CodeInvalidID          = "invalid_id"
+	CodeInvalidHandle      = "invalid_handle"
+	CodeHandleReserved     = "handle_reserved"
+	CodeChirpTooLong       = "chirp_too_long"
+	CodeMessageTooLong     = "message_too_long"
+	CodeInvalidImage       = "invalid_image"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeMissingToken       = "missing_token"
+	CodeInvalidToken       = "invalid_token"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidAPIKey      = "invalid_api_key"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeHandleTaken        = "handle_taken"
+	CodeEmailTaken         = "email_taken"
+	CodeConflict           = "conflict"
+	CodeAlreadyVoted       = "already_voted"
+	CodePollClosed         = "poll_closed"
+	CodeTooManyPins        = "too_many_pins"
+	CodeInternal           = "internal_error"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int          `json:"status"`
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail"`
+	Code       string       `json:"code"`
+	Fields     []FieldError `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("chirpy: %d %s: %s", e.StatusCode, e.Code, e.Detail)
+	}
+	return fmt.Sprintf("chirpy: %d %s", e.StatusCode, e.Code)
+}
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{}
+	data, err := io.ReadAll(resp.Body)
+	if err == nil {
+		err = json.Unmarshal(data, apiErr)
+	}
+	if err != nil || apiErr.Code == "" {
+		// not a problem document, e.g. from a proxy in front of the server
+		apiErr = &Error{Title: http.StatusText(resp.StatusCode), Detail: string(data)}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
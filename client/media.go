@@ -0,0 +1,45 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+)
+
+// UploadMedia uploads a JPEG, PNG or GIF to attach to a chirp with CreateChirp
+func (c *Client) UploadMedia(ctx context.Context, image []byte) (Media, error) {
+	media := Media{}
+	err := c.upload(ctx, http.MethodPost, "/api/media", image, &media)
+	return media, err
+}
+
+// UploadAvatar replaces the avatar of the logged in user
+func (c *Client) UploadAvatar(ctx context.Context, image []byte) (User, error) {
+	user := User{}
+	err := c.upload(ctx, http.MethodPut, "/api/users/me/avatar", image, &user)
+	return user, err
+}
+
+// upload sends the image as the "file" of a multipart/form-data request
+func (c *Client) upload(ctx context.Context, method, path string, image []byte, out any) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "upload")
+	if err == nil {
+		_, err = part.Write(image)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, request{
+		method:      method,
+		path:        path,
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+		auth:        authAccess,
+	}, out)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	IsGroup     bool                 `json:"is_group"`
+	Members     []ConversationMember `json:"members"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+type ConversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	// NextCursor is nil on the last page
+	NextCursor *uuid.UUID `json:"next_cursor"`
+}
+
+// StartConversation returns a conversation of the logged in user with the users,
+// with a single user it is the existing one of the two if there is one
+func (c *Client) StartConversation(ctx context.Context, userIDs ...uuid.UUID) (Conversation, error) {
+	conversation := Conversation{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/conversations",
+		body: struct {
+			UserIDs []uuid.UUID `json:"user_ids"`
+		}{userIDs},
+		auth: authAccess,
+	}, &conversation)
+	return conversation, err
+}
+
+// ListConversations returns the conversations of the logged in user, the one with the latest message first
+func (c *Client) ListConversations(ctx context.Context) ([]Conversation, error) {
+	conversations := []Conversation{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/conversations", auth: authAccess}, &conversations)
+	return conversations, err
+}
+
+func (c *Client) GetConversation(ctx context.Context, id uuid.UUID) (Conversation, error) {
+	conversation := Conversation{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/conversations/" + id.String(), auth: authAccess}, &conversation)
+	return conversation, err
+}
+
+// MarkConversationRead marks every message of the conversation as read
+func (c *Client) MarkConversationRead(ctx context.Context, id uuid.UUID) (Conversation, error) {
+	conversation := Conversation{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/conversations/" + id.String() + "/read",
+		auth:   authAccess,
+	}, &conversation)
+	return conversation, err
+}
+
+// ListMessages returns a page of the messages of the conversation, newest first
+func (c *Client) ListMessages(ctx context.Context, conversationID uuid.UUID, opts PageOptions) (MessagePage, error) {
+	page := MessagePage{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/conversations/" + conversationID.String() + "/messages",
+		query:  opts.query(),
+		auth:   authAccess,
+	}, &page)
+	return page, err
+}
+
+func (c *Client) SendMessage(ctx context.Context, conversationID uuid.UUID, body string) (Message, error) {
+	msg := Message{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/conversations/" + conversationID.String() + "/messages",
+		body: struct {
+			Body string `json:"body"`
+		}{body},
+		auth: authAccess,
+	}, &msg)
+	return msg, err
+}
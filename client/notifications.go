@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+	// NextCursor is nil on the last page
+	NextCursor *uuid.UUID `json:"next_cursor"`
+}
+
+type PageOptions struct {
+	// items per page, the server default if 0
+	Limit int
+	// the NextCursor of the previous page, the first page if uuid.Nil
+	Cursor uuid.UUID
+}
+
+func (o PageOptions) query() url.Values {
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != uuid.Nil {
+		query.Set("cursor", o.Cursor.String())
+	}
+	return query
+}
+
+// ListNotifications returns a page of the notifications of the logged in user, newest first
+func (c *Client) ListNotifications(ctx context.Context, opts PageOptions) (NotificationPage, error) {
+	page := NotificationPage{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/notifications",
+		query:  opts.query(),
+		auth:   authAccess,
+	}, &page)
+	return page, err
+}
+
+// MarkNotificationsRead marks the notifications with the ids as read and returns the number of unread ones left
+func (c *Client) MarkNotificationsRead(ctx context.Context, ids ...uuid.UUID) (int64, error) {
+	return c.markNotificationsRead(ctx, struct {
+		IDs []uuid.UUID `json:"ids"`
+	}{ids})
+}
+
+// MarkNotificationsReadUpTo marks the notification with the id and all older ones as read
+// and returns the number of unread ones left
+func (c *Client) MarkNotificationsReadUpTo(ctx context.Context, id uuid.UUID) (int64, error) {
+	return c.markNotificationsRead(ctx, struct {
+		UpTo uuid.UUID `json:"up_to"`
+	}{id})
+}
+
+func (c *Client) markNotificationsRead(ctx context.Context, body any) (int64, error) {
+	resp := struct {
+		UnreadCount int64 `json:"unread_count"`
+	}{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/notifications/read",
+		body:   body,
+		auth:   authAccess,
+	}, &resp)
+	return resp.UnreadCount, err
+}
+
+// NotificationPreferences returns whether each notification type is on
+func (c *Client) NotificationPreferences(ctx context.Context) (map[string]bool, error) {
+	prefs := map[string]bool{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/notifications/preferences",
+		auth:   authAccess,
+	}, &prefs)
+	return prefs, err
+}
+
+// UpdateNotificationPreferences turns notification types on or off, the others keep their setting
+func (c *Client) UpdateNotificationPreferences(ctx context.Context, prefs map[string]bool) (map[string]bool, error) {
+	updated := map[string]bool{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/notifications/preferences",
+		body:   prefs,
+		auth:   authAccess,
+	}, &updated)
+	return updated, err
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Poll is the poll of a chirp as the logged in user sees it
+type Poll struct {
+	Options        []PollOption `json:"options"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	ClosesAt       time.Time    `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	// VoterCount and the votes of the options are nil until the user voted or the poll closed
+	VoterCount *int64 `json:"voter_count"`
+	// Choices are the positions the user voted for
+	Choices []int32 `json:"choices"`
+}
+
+type PollOption struct {
+	Position int32  `json:"position"`
+	Text     string `json:"text"`
+	Votes    *int64 `json:"votes"`
+}
+
+// NewPoll is the poll of a new chirp, with 2 to 4 options
+type NewPoll struct {
+	Options []string `json:"options"`
+	// ClosesAt is 5 minutes to 7 days after publishing, up to 30 days with Chirpy Red
+	ClosesAt       time.Time `json:"closes_at"`
+	MultipleChoice bool      `json:"multiple_choice"`
+}
+
+// CreateChirpWithPoll posts a chirp with a poll and up to four images from UploadMedia
+func (c *Client) CreateChirpWithPoll(ctx context.Context, body string, poll NewPoll, mediaIDs ...uuid.UUID) (Chirp, error) {
+	return c.createChirp(ctx, body, nil, &poll, nil, "", mediaIDs)
+}
+
+// Vote chooses options of the poll of a chirp by their positions and returns the chirp with the results,
+// every user votes once, again it fails with CodeAlreadyVoted
+func (c *Client) Vote(ctx context.Context, chirpID uuid.UUID, choices ...int32) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps/" + chirpID.String() + "/poll/votes",
+		body: struct {
+			Choices []int32 `json:"choices"`
+		}{choices},
+		auth: authAccess,
+	}, &chirp)
+	return chirp, err
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Health returns nil if the server is ready
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodGet, path: "/api/healthz"}, nil)
+}
+
+func (c *Client) CreateUser(ctx context.Context, email, password string) (User, error) {
+	user := User{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+	}, &user)
+	return user, err
+}
+
+// UpdateUser changes email and password of the logged in user
+func (c *Client) UpdateUser(ctx context.Context, email, password string) (User, error) {
+	user := User{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users",
+		body:   credentials{Email: email, Password: password},
+		auth:   authAccess,
+	}, &user)
+	return user, err
+}
+
+// ProfileUpdate holds the profile fields to change, nil fields keep their value
+// and an empty Handle removes the handle
+type ProfileUpdate struct {
+	Handle      *string `json:"handle,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	AvatarURL   *string `json:"avatar_url,omitempty"`
+}
+
+// UpdateProfile changes the profile of the logged in user
+func (c *Client) UpdateProfile(ctx context.Context, update ProfileUpdate) (User, error) {
+	user := User{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users",
+		body:   update,
+		auth:   authAccess,
+	}, &user)
+	return user, err
+}
+
+// GetProfile returns the public profile of a user by id or handle
+func (c *Client) GetProfile(ctx context.Context, handleOrID string) (Profile, error) {
+	profile := Profile{}
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/users/" + url.PathEscape(handleOrID),
+	}, &profile)
+	return profile, err
+}
+
+// Login stores the tokens of the user for all following requests
+func (c *Client) Login(ctx context.Context, email, password string) (User, error) {
+	resp := struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/login",
+		body:   credentials{Email: email, Password: password},
+	}, &resp)
+	if err != nil {
+		return User{}, err
+	}
+	c.setTokens(resp.Token, resp.RefreshToken)
+	return resp.User, nil
+}
+
+// Refresh gets a new access token, the client calls it on its own when the access token expired
+func (c *Client) Refresh(ctx context.Context) error {
+	resp := struct {
+		Token string `json:"token"`
+	}{}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/refresh",
+		auth:   authRefresh,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	_, refreshToken := c.Tokens()
+	c.setTokens(resp.Token, refreshToken)
+	return nil
+}
+
+// Revoke ends the session of the refresh token and forgets both tokens
+func (c *Client) Revoke(ctx context.Context) error {
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/revoke",
+		auth:   authRefresh,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	c.setTokens("", "")
+	return nil
+}
+
+// Block stops the user from messaging the logged in user and hides their chirps from them
+func (c *Client) Block(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodPost, userID, "block")
+}
+
+func (c *Client) Unblock(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodDelete, userID, "block")
+}
+
+// Mute hides the chirps of the user from the logged in user
+func (c *Client) Mute(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodPost, userID, "mute")
+}
+
+func (c *Client) Unmute(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodDelete, userID, "mute")
+}
+
+// Follow shows the followers-only chirps of the user to the logged in user
+func (c *Client) Follow(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodPost, userID, "follow")
+}
+
+func (c *Client) Unfollow(ctx context.Context, userID uuid.UUID) error {
+	return c.userAction(ctx, http.MethodDelete, userID, "follow")
+}
+
+func (c *Client) userAction(ctx context.Context, method string, userID uuid.UUID, action string) error {
+	return c.do(ctx, request{
+		method: method,
+		path:   "/api/users/" + userID.String() + "/" + action,
+		auth:   authAccess,
+	}, nil)
+}
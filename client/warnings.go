@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// How chirps with a warning are shown to a user, see SetSensitiveContent
+const (
+	SensitiveCollapse = "collapse"
+	SensitiveExpand   = "expand"
+	SensitiveHide     = "hide"
+)
+
+// Warning is the content warning of a chirp, an empty ContentWarning is none
+type Warning struct {
+	ContentWarning string `json:"content_warning,omitempty"`
+	// Sensitive marks the images as upsetting or not safe for work
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// CreateChirpWithWarning posts a chirp that clients show collapsed behind its warning
+func (c *Client) CreateChirpWithWarning(ctx context.Context, body string, warning Warning, mediaIDs ...uuid.UUID) (Chirp, error) {
+	return c.createChirp(ctx, body, nil, nil, &warning, "", mediaIDs)
+}
+
+// SetChirpWarning replaces the warning of any chirp, the logged in user has to be a moderator
+func (c *Client) SetChirpWarning(ctx context.Context, id uuid.UUID, warning Warning) (Chirp, error) {
+	chirp := Chirp{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/chirps/" + id.String() + "/warning",
+		body:   warning,
+		auth:   authAccess,
+	}, &chirp)
+	return chirp, err
+}
+
+// SetSensitiveContent changes how the logged in user sees chirps with a warning,
+// SensitiveHide also leaves them out of ListChirps
+func (c *Client) SetSensitiveContent(ctx context.Context, setting string) (User, error) {
+	user := User{}
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users",
+		body: struct {
+			SensitiveContent string `json:"sensitive_content"`
+		}{setting},
+		auth: authAccess,
+	}, &user)
+	return user, err
+}
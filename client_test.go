@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zelieen/Chirpy/client"
+
+	"github.com/google/uuid"
+)
+
+func TestClient(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c := client.New(srv.URL)
+	email := uuid.NewString() + "@example.com"
+
+	err := c.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+
+	user, err := c.CreateUser(ctx, email, "password")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	_, err = c.CreateUser(ctx, email, "password")
+	if !client.IsCode(err, client.CodeEmailTaken) {
+		t.Errorf("CreateUser() twice error = %v, want %s", err, client.CodeEmailTaken)
+	}
+
+	_, err = c.Login(ctx, email, "wrong password")
+	if !client.IsCode(err, client.CodeInvalidCredentials) {
+		t.Errorf("Login() error = %v, want %s", err, client.CodeInvalidCredentials)
+	}
+	_, err = c.Login(ctx, email, "password")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	chirp, err := c.CreateChirp(ctx, "hello fornax")
+	if err != nil {
+		t.Fatalf("CreateChirp() error = %v", err)
+	}
+	if chirp.Body != "hello ****" || chirp.UserID != user.ID {
+		t.Errorf("CreateChirp() = %+v", chirp)
+	}
+	_, err = c.CreateChirp(ctx, strings.Repeat("a", 141))
+	if !client.IsCode(err, client.CodeChirpTooLong) {
+		t.Errorf("CreateChirp() too long error = %v, want %s", err, client.CodeChirpTooLong)
+	}
+
+	chirps, err := c.ListChirps(ctx, client.ListChirpsOptions{AuthorID: user.ID})
+	if err != nil || len(chirps) != 1 {
+		t.Errorf("ListChirps() = %v, %v", chirps, err)
+	}
+	got, err := c.GetChirp(ctx, chirp.ID)
+	if err != nil || got.ID != chirp.ID {
+		t.Errorf("GetChirp() = %v, %v", got, err)
+	}
+
+	// a broken access token is renewed with the refresh token
+	_, refreshToken := c.Tokens()
+	c = client.New(srv.URL, client.WithTokens("broken", refreshToken))
+	err = c.DeleteChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatalf("DeleteChirp() error = %v", err)
+	}
+	_, err = c.GetChirp(ctx, chirp.ID)
+	if !client.IsCode(err, client.CodeNotFound) {
+		t.Errorf("GetChirp() deleted error = %v, want %s", err, client.CodeNotFound)
+	}
+
+	err = c.SendPolkaWebhook(ctx, "test-polka-key", "user.upgraded", user.ID)
+	if err != nil {
+		t.Errorf("SendPolkaWebhook() error = %v", err)
+	}
+	updated, err := c.UpdateUser(ctx, email, "new password")
+	if err != nil || !updated.IsChirpyRed {
+		t.Errorf("UpdateUser() = %+v, %v", updated, err)
+	}
+	handle, bio := "c_"+uuid.NewString()[:8], "testing the client"
+	_, err = c.UpdateProfile(ctx, client.ProfileUpdate{Handle: &handle, Bio: &bio})
+	if err != nil {
+		t.Errorf("UpdateProfile() error = %v", err)
+	}
+	profile, err := c.GetProfile(ctx, "@"+handle)
+	if err != nil || profile.ID != user.ID || profile.Bio != bio {
+		t.Errorf("GetProfile() = %+v, %v", profile, err)
+	}
+
+	upload, err := c.UploadMedia(ctx, testPNG(t, 8, 8))
+	if err != nil || upload.Width != 8 || upload.ContentType != "image/png" {
+		t.Fatalf("UploadMedia() = %+v, %v", upload, err)
+	}
+	withImage, err := c.CreateChirp(ctx, "look at this", upload.ID)
+	if err != nil || len(withImage.Media) != 1 || withImage.Media[0].ID != upload.ID {
+		t.Errorf("CreateChirp() with media = %+v, %v", withImage, err)
+	}
+	later, err := c.ScheduleChirp(ctx, "see you tomorrow", time.Now().Add(24*time.Hour))
+	if err != nil || later.PublishAt == nil {
+		t.Errorf("ScheduleChirp() = %+v, %v", later, err)
+	}
+	scheduled, err := c.ListScheduledChirps(ctx)
+	if err != nil || len(scheduled) != 1 || scheduled[0].ID != later.ID {
+		t.Errorf("ListScheduledChirps() = %+v, %v", scheduled, err)
+	}
+	err = c.CancelScheduledChirp(ctx, later.ID)
+	if err != nil {
+		t.Errorf("CancelScheduledChirp() error = %v", err)
+	}
+	soon, _ := c.ScheduleChirp(ctx, "or right now", time.Now().Add(time.Hour))
+	published, err := c.PublishScheduledChirp(ctx, soon.ID)
+	if err != nil || published.PublishAt != nil {
+		t.Errorf("PublishScheduledChirp() = %+v, %v", published, err)
+	}
+	draft, err := c.CreateDraft(ctx, "half a")
+	if err != nil {
+		t.Fatalf("CreateDraft() error = %v", err)
+	}
+	_, err = c.UpdateDraft(ctx, draft.ID, "half a thought")
+	if err != nil {
+		t.Errorf("UpdateDraft() error = %v", err)
+	}
+	if drafts, err := c.ListDrafts(ctx); err != nil || len(drafts) != 1 || drafts[0].Body != "half a thought" {
+		t.Errorf("ListDrafts() = %+v, %v", drafts, err)
+	}
+	fromDraft, err := c.PublishDraft(ctx, draft.ID)
+	if err != nil || fromDraft.Body != "half a thought" {
+		t.Errorf("PublishDraft() = %+v, %v", fromDraft, err)
+	}
+	err = c.DeleteDraft(ctx, draft.ID)
+	if !client.IsCode(err, client.CodeNotFound) {
+		t.Errorf("DeleteDraft() published error = %v, want %s", err, client.CodeNotFound)
+	}
+	poll, err := c.CreateChirpWithPoll(ctx, "tea or coffee?", client.NewPoll{
+		Options:  []string{"tea", "coffee"},
+		ClosesAt: time.Now().Add(time.Hour),
+	})
+	if err != nil || poll.Poll == nil || len(poll.Poll.Options) != 2 {
+		t.Fatalf("CreateChirpWithPoll() = %+v, %v", poll, err)
+	}
+	voted, err := c.Vote(ctx, poll.ID, 1)
+	if err != nil || voted.Poll.Options[1].Votes == nil || *voted.Poll.Options[1].Votes != 1 {
+		t.Errorf("Vote() = %+v, %v", voted.Poll, err)
+	}
+	_, err = c.Vote(ctx, poll.ID, 0)
+	if !client.IsCode(err, client.CodeAlreadyVoted) {
+		t.Errorf("Vote() twice error = %v, want %s", err, client.CodeAlreadyVoted)
+	}
+	favorites, err := c.CreateCollection(ctx, "Favorites")
+	if err != nil {
+		t.Fatalf("CreateCollection() error = %v", err)
+	}
+	saved, err := c.BookmarkChirp(ctx, poll.ID, favorites.ID)
+	if err != nil || saved.CollectionID == nil || *saved.CollectionID != favorites.ID {
+		t.Errorf("BookmarkChirp() = %+v, %v", saved, err)
+	}
+	if page, err := c.ListBookmarks(ctx, favorites.ID, client.PageOptions{}); err != nil || len(page.Bookmarks) != 1 || page.Bookmarks[0].Chirp.ID != poll.ID {
+		t.Errorf("ListBookmarks() = %+v, %v", page, err)
+	}
+	err = c.RemoveBookmark(ctx, poll.ID)
+	if err != nil {
+		t.Errorf("RemoveBookmark() error = %v", err)
+	}
+	pinned, err := c.PinChirp(ctx, poll.ID)
+	if err != nil || !pinned.Pinned {
+		t.Errorf("PinChirp() = %+v, %v", pinned, err)
+	}
+	err = c.UnpinChirp(ctx, poll.ID)
+	if err != nil {
+		t.Errorf("UnpinChirp() error = %v", err)
+	}
+	warned, err := c.CreateChirpWithWarning(ctx, "the ending", client.Warning{ContentWarning: "spoilers"})
+	if err != nil || warned.ContentWarning == nil || !warned.Collapsed {
+		t.Errorf("CreateChirpWithWarning() = %+v, %v", warned, err)
+	}
+	if u, err := c.SetSensitiveContent(ctx, client.SensitiveExpand); err != nil || u.SensitiveContent != client.SensitiveExpand {
+		t.Errorf("SetSensitiveContent() = %+v, %v", u, err)
+	}
+	private, err := c.CreateChirpWithVisibility(ctx, "for friends", client.VisibilityFollowers)
+	if err != nil || private.Visibility != client.VisibilityFollowers {
+		t.Errorf("CreateChirpWithVisibility() = %+v, %v", private, err)
+	}
+	avatar, err := c.UploadAvatar(ctx, testPNG(t, 8, 8))
+	if err != nil || !strings.HasPrefix(avatar.AvatarURL, "/media/avatars/") {
+		t.Errorf("UploadAvatar() = %+v, %v", avatar, err)
+	}
+
+	page, err := c.ListNotifications(ctx, client.PageOptions{Limit: 10})
+	if err != nil || len(page.Notifications) != 1 || page.Notifications[0].Type != "chirpy_red" || page.UnreadCount != 1 {
+		t.Errorf("ListNotifications() = %+v, %v", page, err)
+	} else {
+		unread, err := c.MarkNotificationsRead(ctx, page.Notifications[0].ID)
+		if err != nil || unread != 0 {
+			t.Errorf("MarkNotificationsRead() = %d, %v", unread, err)
+		}
+	}
+	prefs, err := c.UpdateNotificationPreferences(ctx, map[string]bool{"chirpy_red": false})
+	if err != nil || prefs["chirpy_red"] || !prefs["mention"] {
+		t.Errorf("UpdateNotificationPreferences() = %v, %v", prefs, err)
+	}
+
+	friend, err := c.CreateUser(ctx, uuid.NewString()+"@example.com", "password")
+	if err != nil {
+		t.Fatalf("CreateUser() friend error = %v", err)
+	}
+	conversation, err := c.StartConversation(ctx, friend.ID)
+	if err != nil || len(conversation.Members) != 2 {
+		t.Fatalf("StartConversation() = %+v, %v", conversation, err)
+	}
+	_, err = c.SendMessage(ctx, conversation.ID, "hello friend")
+	if err != nil {
+		t.Errorf("SendMessage() error = %v", err)
+	}
+	messages, err := c.ListMessages(ctx, conversation.ID, client.PageOptions{})
+	if err != nil || len(messages.Messages) != 1 || messages.Messages[0].Body != "hello friend" {
+		t.Errorf("ListMessages() = %+v, %v", messages, err)
+	}
+
+	err = c.Revoke(ctx)
+	if err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	err = client.New(srv.URL, client.WithTokens("", refreshToken)).Refresh(ctx)
+	if !client.IsCode(err, client.CodeInvalidToken) {
+		t.Errorf("Refresh() after revoke error = %v, want %s", err, client.CodeInvalidToken)
+	}
+}
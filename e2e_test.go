@@ -0,0 +1,2081 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/blob"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+	"github.com/zelieen/Chirpy/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	testSecret   = "test-secret"
+	testPolkaKey = "test-polka-key"
+)
+
+// getTestStorages returns a constructor for every backend the end-to-end tests run against,
+// each call creates a new empty database so tests never share state. Postgres is only
+// tested if TEST_DB_URL points to a database the tests may create schemas in.
+func getTestStorages() map[string]func(t *testing.T) store.Store {
+	storages := map[string]func(t *testing.T) store.Store{
+		"memory": func(t *testing.T) store.Store {
+			return store.NewMemory()
+		},
+		"sqlite": func(t *testing.T) store.Store {
+			db, err := store.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "chirpy.db"), os.DirFS("sql/sqlite/schema"))
+			if err != nil {
+				t.Fatalf("OpenSQLite() error = %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	}
+	if dbURL := os.Getenv("TEST_DB_URL"); dbURL != "" {
+		storages["postgres"] = func(t *testing.T) store.Store {
+			return openTestPostgres(t, dbURL)
+		}
+	}
+	return storages
+}
+
+// openTestPostgres migrates a new schema in the database at dbURL and returns a store using
+// only that schema, the schema is dropped after the test
+func openTestPostgres(t *testing.T, dbURL string) store.Store {
+	t.Helper()
+	ctx := context.Background()
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("Error opening the database: %s", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := "e2e_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.ExecContext(ctx, "CREATE SCHEMA "+schema)
+	if err != nil {
+		t.Fatalf("Error creating schema %s: %s", schema, err)
+	}
+	t.Cleanup(func() { admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	// every connection of the store looks up the tables in the new schema only
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		t.Fatalf("TEST_DB_URL is not a URL: %s", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("Error opening the database: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("sql/schema/*.sql")
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("No migrations in sql/schema: %v", err)
+	}
+	for _, name := range migrations {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Error reading %s: %s", name, err)
+		}
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		_, err = db.ExecContext(ctx, up)
+		if err != nil {
+			t.Fatalf("Error applying %s: %s", name, err)
+		}
+	}
+	return store.NewPostgres(db)
+}
+
+// forEachStorage runs test once per storage backend, each with its own server and database
+func forEachStorage(t *testing.T, test func(t *testing.T, api *testAPI)) {
+	for name, newStore := range getTestStorages() {
+		t.Run(name, func(t *testing.T) {
+			test(t, newTestAPI(t, newStore(t)))
+		})
+	}
+}
+
+type testAPI struct {
+	cfg *apiConfig
+	srv *httptest.Server
+}
+
+// newTestAPI serves the real mux on db
+func newTestAPI(t *testing.T, db store.Store) *testAPI {
+	t.Helper()
+	cfg := &apiConfig{
+		db:       db,
+		events:   events.NewLocal(eventHistorySize, streamBufferSize),
+		blobs:    blob.NewLocal(t.TempDir()),
+		platform: "dev",
+		secret:   testSecret,
+		polkaKey: testPolkaKey,
+	}
+	srv := httptest.NewServer(cfg.newServeMux("."))
+	t.Cleanup(srv.Close)
+	return &testAPI{cfg: cfg, srv: srv}
+}
+
+// newTestServer serves the real mux with its own in-memory storage
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestAPI(t, store.NewMemory()).srv
+}
+
+type testResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// decode unmarshals the response body into a value of type T
+func decode[T any](t *testing.T, resp testResponse) T {
+	t.Helper()
+	var v T
+	err := json.Unmarshal(resp.body, &v)
+	if err != nil {
+		t.Fatalf("Error decoding %q: %v", resp.body, err)
+	}
+	return v
+}
+
+// do sends a request, authorization is sent as is, e.g. "Bearer <token>", if not empty
+func (api *testAPI) do(t *testing.T, method, path, authorization string, body any) testResponse {
+	t.Helper()
+	var reader io.Reader
+	if s, ok := body.(string); ok {
+		reader = strings.NewReader(s)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error encoding request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, api.srv.URL+path, reader)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := api.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading response: %v", err)
+	}
+	return testResponse{status: resp.StatusCode, header: resp.Header, body: data}
+}
+
+func expectStatus(t *testing.T, resp testResponse, want int) {
+	t.Helper()
+	if resp.status != want {
+		t.Fatalf("status = %d, want %d, body: %s", resp.status, want, resp.body)
+	}
+}
+
+func expectProblem(t *testing.T, resp testResponse, wantStatus int, wantCode ErrorCode) problem {
+	t.Helper()
+	expectStatus(t, resp, wantStatus)
+	if ct := resp.header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	p := decode[problem](t, resp)
+	if p.Code != wantCode {
+		t.Errorf("code = %q, want %q", p.Code, wantCode)
+	}
+	return p
+}
+
+type testUser struct {
+	User
+	password     string
+	token        string
+	refreshToken string
+}
+
+func (u testUser) bearer() string {
+	return "Bearer " + u.token
+}
+
+// signup creates a user and logs them in
+func (api *testAPI) signup(t *testing.T, email string) testUser {
+	t.Helper()
+	password := "password"
+	resp := api.do(t, "POST", "/api/users", "", map[string]string{"email": email, "password": password})
+	expectStatus(t, resp, http.StatusCreated)
+	return api.login(t, email, password)
+}
+
+func (api *testAPI) login(t *testing.T, email, password string) testUser {
+	t.Helper()
+	resp := api.do(t, "POST", "/api/login", "", map[string]string{"email": email, "password": password})
+	expectStatus(t, resp, http.StatusOK)
+	login := decode[loginResponse](t, resp)
+	return testUser{User: login.User, password: password, token: login.Token, refreshToken: login.RefreshToken}
+}
+
+// withRole gives the user the role and logs them in again to get a token with it
+func (api *testAPI) withRole(t *testing.T, u testUser, role auth.Role) testUser {
+	t.Helper()
+	_, err := api.cfg.db.SetUserRole(context.Background(), database.SetUserRoleParams{ID: u.ID, Role: string(role)})
+	if err != nil {
+		t.Fatalf("SetUserRole() error = %v", err)
+	}
+	return api.login(t, u.Email, u.password)
+}
+
+func (api *testAPI) chirp(t *testing.T, u testUser, body string) Chirp {
+	t.Helper()
+	resp := api.do(t, "POST", "/api/chirps", u.bearer(), map[string]string{"body": body})
+	expectStatus(t, resp, http.StatusCreated)
+	return decode[Chirp](t, resp)
+}
+
+func TestE2EHealthAndStaticFiles(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		resp := api.do(t, "GET", "/api/healthz", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if string(resp.body) != "OK" {
+			t.Errorf("healthz body = %q, want OK", resp.body)
+		}
+
+		resp = api.do(t, "GET", "/app/", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if !strings.Contains(string(resp.body), "Welcome to Chirpy") {
+			t.Errorf("/app/ body = %q, want index.html", resp.body)
+		}
+		if hits := api.cfg.fileserverHits.Load(); hits != 1 {
+			t.Errorf("fileserverHits = %d, want 1", hits)
+		}
+
+		resp = api.do(t, "GET", "/api/openapi.json", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+
+		// the docs page only loads files the server has
+		resp = api.do(t, "GET", "/api/docs", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		for _, file := range []string{"/swagger-ui/swagger-ui.css", "/swagger-ui/swagger-ui-bundle.js"} {
+			if !strings.Contains(string(resp.body), `"`+file+`"`) {
+				t.Errorf("/api/docs does not load %s", file)
+			}
+			expectStatus(t, api.do(t, "GET", file, "", nil), http.StatusOK)
+		}
+		if strings.Contains(string(resp.body), "https://") {
+			t.Errorf("/api/docs loads files from elsewhere: %s", resp.body)
+		}
+	})
+}
+
+func TestE2ESignupAndLogin(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		if user.Email != "user@example.com" || user.IsChirpyRed || user.Role != "user" {
+			t.Errorf("signup user = %+v", user.User)
+		}
+		if user.token == "" || user.refreshToken == "" {
+			t.Errorf("login returned no tokens")
+		}
+		if strings.Contains(string(api.do(t, "POST", "/api/login", "", map[string]string{"email": "user@example.com", "password": "password"}).body), "hashed_password") {
+			t.Errorf("login leaks the password hash")
+		}
+
+		resp := api.do(t, "POST", "/api/users", "", map[string]string{"email": "user@example.com", "password": "password"})
+		expectProblem(t, resp, http.StatusConflict, CodeEmailTaken)
+
+		resp = api.do(t, "POST", "/api/users", "", map[string]string{"email": "", "password": ""})
+		p := expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		if len(p.Errors) != 2 {
+			t.Errorf("validation errors = %v, want one per field", p.Errors)
+		}
+
+		resp = api.do(t, "POST", "/api/users", "", `{"email": "user@example.com"`)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidJSON)
+
+		resp = api.do(t, "POST", "/api/login", "", map[string]string{"email": "user@example.com", "password": "wrong password"})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidCredentials)
+
+		resp = api.do(t, "POST", "/api/login", "", map[string]string{"email": "nobody@example.com", "password": "password"})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidCredentials)
+	})
+}
+
+func TestE2EUpdateUser(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		api.signup(t, "other@example.com")
+
+		resp := api.do(t, "PUT", "/api/users", "", map[string]string{"email": "new@example.com", "password": "new password"})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		if resp.header.Get("WWW-Authenticate") == "" {
+			t.Errorf("401 without WWW-Authenticate header")
+		}
+
+		resp = api.do(t, "PUT", "/api/users", "Bearer not-a-jwt", map[string]string{"email": "new@example.com", "password": "new password"})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidToken)
+
+		resp = api.do(t, "PUT", "/api/users", user.bearer(), map[string]string{"email": "other@example.com", "password": "new password"})
+		expectProblem(t, resp, http.StatusConflict, CodeEmailTaken)
+
+		resp = api.do(t, "PUT", "/api/users", user.bearer(), map[string]string{"email": "new@example.com", "password": "new password"})
+		expectStatus(t, resp, http.StatusOK)
+		updated := decode[User](t, resp)
+		if updated.ID != user.ID || updated.Email != "new@example.com" {
+			t.Errorf("updated user = %+v", updated)
+		}
+
+		api.login(t, "new@example.com", "new password")
+	})
+}
+
+func TestE2EProfiles(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+
+		resp := api.do(t, "PUT", "/api/users", alice.bearer(), map[string]string{
+			"handle":       "Alice_1",
+			"display_name": "Alice",
+			"bio":          "hello",
+			"avatar_url":   "https://example.com/alice.png",
+		})
+		expectStatus(t, resp, http.StatusOK)
+		updated := decode[User](t, resp)
+		if updated.Handle == nil || *updated.Handle != "Alice_1" || updated.DisplayName != "Alice" || updated.Email != alice.Email {
+			t.Errorf("updated user = %+v", updated)
+		}
+		// the password was left out and still works
+		api.login(t, alice.Email, alice.password)
+
+		for _, path := range []string{alice.ID.String(), "alice_1", "@ALICE_1"} {
+			resp = api.do(t, "GET", "/api/users/"+path, "", nil)
+			expectStatus(t, resp, http.StatusOK)
+			if strings.Contains(string(resp.body), alice.Email) {
+				t.Errorf("profile at %s contains the email: %s", path, resp.body)
+			}
+			profile := decode[Profile](t, resp)
+			if profile.ID != alice.ID || profile.Bio != "hello" || profile.AvatarURL != "https://example.com/alice.png" {
+				t.Errorf("profile at %s = %+v", path, profile)
+			}
+		}
+		resp = api.do(t, "GET", "/api/users/nobody", "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]string{"handle": "ALICE_1"})
+		expectProblem(t, resp, http.StatusConflict, CodeHandleTaken)
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]string{"handle": "Admin"})
+		expectProblem(t, resp, http.StatusBadRequest, CodeHandleReserved)
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]string{"handle": "bob-1"})
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidHandle)
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]string{"avatar_url": "javascript:alert(1)", "bio": strings.Repeat("a", maxBioLength+1)})
+		problem := expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		if len(problem.Errors) != 2 {
+			t.Errorf("field errors = %+v, want avatar_url and bio", problem.Errors)
+		}
+
+		// an empty handle frees it
+		resp = api.do(t, "PUT", "/api/users", alice.bearer(), map[string]string{"handle": ""})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[User](t, resp); got.Handle != nil || got.DisplayName != "Alice" {
+			t.Errorf("user without handle = %+v", got)
+		}
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]string{"handle": "alice_1"})
+		expectStatus(t, resp, http.StatusOK)
+	})
+}
+
+// upload sends data as the "file" part of a multipart/form-data request
+func (api *testAPI) upload(t *testing.T, method, path string, u testUser, data []byte) testResponse {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "upload")
+	part.Write(data)
+	form.Close()
+
+	req, _ := http.NewRequest(method, api.srv.URL+path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", u.bearer())
+	resp, err := api.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return testResponse{status: resp.StatusCode, header: resp.Header, body: respBody}
+}
+
+func TestE2EScheduledChirps(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		sub := api.cfg.events.Subscribe(0)
+		defer sub.Close()
+
+		publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+		resp := api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "coming soon", "publish_at": publishAt})
+		expectStatus(t, resp, http.StatusCreated)
+		scheduled := decode[Chirp](t, resp)
+		if scheduled.PublishAt == nil || !scheduled.PublishAt.Equal(publishAt) {
+			t.Errorf("publish_at = %v, want %v", scheduled.PublishAt, publishAt)
+		}
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "never mind", "publish_at": publishAt.Add(time.Hour)})
+		expectStatus(t, resp, http.StatusCreated)
+		canceled := decode[Chirp](t, resp)
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "too late", "publish_at": time.Now().Add(-time.Minute)})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+
+		// only the author sees them until they are published
+		resp = api.do(t, "GET", "/api/chirps/"+scheduled.ID.String(), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "GET", "/api/chirps/"+scheduled.ID.String(), "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "GET", "/api/chirps/"+scheduled.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if chirps := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 0 {
+			t.Errorf("chirps for everyone = %+v, want none", chirps)
+		}
+		if chirps := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", alice.bearer(), nil)); len(chirps) != 2 {
+			t.Errorf("chirps for the author = %+v, want both", chirps)
+		}
+		resp = api.do(t, "GET", "/api/chirps/scheduled", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if list := decode[[]Chirp](t, resp); len(list) != 2 || list[0].ID != scheduled.ID {
+			t.Errorf("scheduled chirps = %+v, want the next one first", list)
+		}
+
+		// canceling
+		resp = api.do(t, "DELETE", "/api/chirps/scheduled?chirp_id="+canceled.ID.String(), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "DELETE", "/api/chirps/scheduled?chirp_id=nope", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+		resp = api.do(t, "DELETE", "/api/chirps/scheduled?chirp_id="+canceled.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "GET", "/api/chirps/"+canceled.ID.String(), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// publishing one right away
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "right now", "publish_at": publishAt})
+		expectStatus(t, resp, http.StatusCreated)
+		rightAway := decode[Chirp](t, resp)
+		resp = api.do(t, "POST", "/api/chirps/scheduled/"+rightAway.ID.String()+"/publish", bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "POST", "/api/chirps/scheduled/"+rightAway.ID.String()+"/publish", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if published := decode[Chirp](t, resp); published.PublishAt != nil {
+			t.Errorf("published chirp publish_at = %v, want null", published.PublishAt)
+		}
+		if created := <-sub.Events(); created.Type != events.ChirpCreated || !strings.Contains(string(created.Data), rightAway.ID.String()) {
+			t.Errorf("event = %+v, want %s of the chirp published right away", created, events.ChirpCreated)
+		}
+		resp = api.do(t, "GET", "/api/chirps/"+rightAway.ID.String(), bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp = api.do(t, "POST", "/api/chirps/scheduled/"+rightAway.ID.String()+"/publish", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusConflict, CodeConflict)
+
+		// the scheduler publishes and announces them once they are due
+		api.cfg.publishDueChirps(context.Background(), time.Now())
+		if chirps := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 1 {
+			t.Errorf("chirps before publish_at = %+v, want the one published right away", chirps)
+		}
+		api.cfg.publishDueChirps(context.Background(), publishAt)
+		resp = api.do(t, "GET", "/api/chirps/"+scheduled.ID.String(), bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if published := decode[Chirp](t, resp); published.PublishAt != nil {
+			t.Errorf("published chirp publish_at = %v, want null", published.PublishAt)
+		}
+		created := <-sub.Events()
+		if created.Type != events.ChirpCreated || !strings.Contains(string(created.Data), scheduled.ID.String()) {
+			t.Errorf("event = %+v, want %s of the scheduled chirp", created, events.ChirpCreated)
+		}
+		resp = api.do(t, "DELETE", "/api/chirps/scheduled?chirp_id="+scheduled.ID.String(), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusConflict, CodeConflict)
+	})
+}
+
+func TestE2EDrafts(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		sub := api.cfg.events.Subscribe(0)
+		defer sub.Close()
+
+		// drafts may be empty or too long for a chirp
+		resp := api.do(t, "POST", "/api/drafts", alice.bearer(), map[string]string{"body": ""})
+		expectStatus(t, resp, http.StatusCreated)
+		draft := decode[Draft](t, resp)
+		resp = api.do(t, "POST", "/api/drafts", alice.bearer(), map[string]string{"body": strings.Repeat("a", maxChirpLength+1)})
+		expectStatus(t, resp, http.StatusCreated)
+		long := decode[Draft](t, resp)
+		resp = api.do(t, "POST", "/api/drafts", alice.bearer(), map[string]string{"body": strings.Repeat("a", maxDraftLength+1)})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+
+		resp = api.do(t, "POST", "/api/drafts/"+draft.ID.String()+"/publish", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeRequired)
+		resp = api.do(t, "POST", "/api/drafts/"+long.ID.String()+"/publish", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeChirpTooLong)
+
+		resp = api.do(t, "PUT", "/api/drafts/"+draft.ID.String(), bob.bearer(), map[string]string{"body": "mine now"})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "PUT", "/api/drafts/"+draft.ID.String(), alice.bearer(), map[string]string{"body": "what a kerfuffle"})
+		expectStatus(t, resp, http.StatusOK)
+		if updated := decode[Draft](t, resp); updated.Body != "what a kerfuffle" || updated.ID != draft.ID {
+			t.Errorf("updated draft = %+v", updated)
+		}
+		resp = api.do(t, "GET", "/api/drafts", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if drafts := decode[[]Draft](t, resp); len(drafts) != 2 || drafts[0].ID != draft.ID {
+			t.Errorf("drafts = %+v, want the last edited first", drafts)
+		}
+		if drafts := decode[[]Draft](t, api.do(t, "GET", "/api/drafts", bob.bearer(), nil)); len(drafts) != 0 {
+			t.Errorf("drafts of another user = %+v, want none", drafts)
+		}
+
+		// publishing moderates like a new chirp and removes the draft
+		resp = api.do(t, "POST", "/api/drafts/"+draft.ID.String()+"/publish", bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "POST", "/api/drafts/"+draft.ID.String()+"/publish", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusCreated)
+		chirp := decode[Chirp](t, resp)
+		if chirp.Body != "what a ****" || chirp.UserID != alice.ID {
+			t.Errorf("published chirp = %+v", chirp)
+		}
+		if created := <-sub.Events(); created.Type != events.ChirpCreated {
+			t.Errorf("event = %+v, want %s", created, events.ChirpCreated)
+		}
+		resp = api.do(t, "POST", "/api/drafts/"+draft.ID.String()+"/publish", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		if chirps := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); len(chirps) != 1 || chirps[0].ID != chirp.ID {
+			t.Errorf("chirps = %+v, want the published draft", chirps)
+		}
+
+		resp = api.do(t, "DELETE", "/api/drafts/"+long.ID.String(), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "DELETE", "/api/drafts/"+long.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		if drafts := decode[[]Draft](t, api.do(t, "GET", "/api/drafts", alice.bearer(), nil)); len(drafts) != 0 {
+			t.Errorf("drafts after publishing and deleting = %+v, want none", drafts)
+		}
+	})
+}
+
+func TestE2EPolls(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		carol := api.signup(t, "carol@example.com")
+
+		closesAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+		poll := map[string]any{"options": []string{"tea", "coffee", "kerfuffle"}, "closes_at": closesAt}
+		resp := api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "what do you drink?", "poll": poll})
+		expectStatus(t, resp, http.StatusCreated)
+		chirp := decode[Chirp](t, resp)
+		if chirp.Poll == nil || len(chirp.Poll.Options) != 3 || chirp.Poll.Options[2].Text != "****" || !chirp.Poll.ClosesAt.Equal(closesAt) {
+			t.Fatalf("poll = %+v, want 3 moderated options", chirp.Poll)
+		}
+		if chirp.Poll.VoterCount != nil || chirp.Poll.Options[0].Votes != nil {
+			t.Errorf("poll before voting = %+v, want the results hidden", chirp.Poll)
+		}
+
+		for name, bad := range map[string]map[string]any{
+			"one option":   {"options": []string{"tea"}, "closes_at": closesAt},
+			"same option":  {"options": []string{"tea", "tea"}, "closes_at": closesAt},
+			"too short":    {"options": []string{"tea", "coffee"}, "closes_at": time.Now().Add(time.Minute)},
+			"too long":     {"options": []string{"tea", "coffee"}, "closes_at": time.Now().AddDate(0, 0, maxPollDays+1)},
+			"long option":  {"options": []string{"tea", strings.Repeat("a", maxPollOption+1)}, "closes_at": closesAt},
+			"five options": {"options": []string{"a", "b", "c", "d", "e"}, "closes_at": closesAt},
+		} {
+			resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": name, "poll": bad})
+			expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		}
+		// Chirpy Red members may run polls longer
+		resp = api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}})
+		expectStatus(t, resp, http.StatusNoContent)
+		long := map[string]any{"options": []string{"tea", "coffee"}, "closes_at": time.Now().AddDate(0, 0, maxPollDays+1), "multiple_choice": true}
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "for a while", "poll": long})
+		expectStatus(t, resp, http.StatusCreated)
+		multiple := decode[Chirp](t, resp)
+
+		// voting shows the results to the voter, once
+		votes := "/api/chirps/" + chirp.ID.String() + "/poll/votes"
+		resp = api.do(t, "POST", votes, "", map[string]any{"choices": []int{0}})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		resp = api.do(t, "POST", votes, bob.bearer(), map[string]any{"choices": []int{0, 1}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", votes, bob.bearer(), map[string]any{"choices": []int{3}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", votes, bob.bearer(), map[string]any{"choices": []int{1}})
+		expectStatus(t, resp, http.StatusOK)
+		voted := decode[Chirp](t, resp).Poll
+		if voted.VoterCount == nil || *voted.VoterCount != 1 || *voted.Options[1].Votes != 1 || !slices.Equal(voted.Choices, []int32{1}) {
+			t.Errorf("poll after voting = %+v", voted)
+		}
+		resp = api.do(t, "POST", votes, bob.bearer(), map[string]any{"choices": []int{0}})
+		expectProblem(t, resp, http.StatusConflict, CodeAlreadyVoted)
+		if p := decode[Chirp](t, api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), carol.bearer(), nil)).Poll; p.VoterCount != nil {
+			t.Errorf("poll for someone who did not vote = %+v, want the results hidden", p)
+		}
+
+		resp = api.do(t, "POST", "/api/chirps/"+multiple.ID.String()+"/poll/votes", carol.bearer(), map[string]any{"choices": []int{0, 1}})
+		expectStatus(t, resp, http.StatusOK)
+		if p := decode[Chirp](t, resp).Poll; *p.Options[0].Votes != 1 || *p.Options[1].Votes != 1 || *p.VoterCount != 1 {
+			t.Errorf("multiple choice poll = %+v", p)
+		}
+		resp = api.do(t, "POST", "/api/chirps/"+api.chirp(t, alice, "no poll").ID.String()+"/poll/votes", bob.bearer(), map[string]any{"choices": []int{0}})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// users the author blocked cannot vote
+		resp = api.do(t, "POST", "/api/users/"+carol.ID.String()+"/block", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "POST", votes, carol.bearer(), map[string]any{"choices": []int{0}})
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+
+		// closed polls show their results to everyone and take no more votes
+		closed, err := api.cfg.db.PostChirp(context.Background(), database.PostChirpParams{
+			UserID:       alice.ID,
+			Body:         "closed already",
+			Visibility:   "public",
+			PollClosesAt: sql.NullTime{Time: time.Now().Add(-time.Minute).UTC(), Valid: true},
+			PollOptions:  []string{"yes", "no"},
+		})
+		if err != nil {
+			t.Fatalf("PostChirp() error = %v", err)
+		}
+		resp = api.do(t, "POST", "/api/chirps/"+closed.ID.String()+"/poll/votes", bob.bearer(), map[string]any{"choices": []int{0}})
+		expectProblem(t, resp, http.StatusConflict, CodePollClosed)
+		if p := decode[Chirp](t, api.do(t, "GET", "/api/chirps/"+closed.ID.String(), "", nil)).Poll; !p.Closed || p.VoterCount == nil || *p.Options[0].Votes != 0 {
+			t.Errorf("closed poll = %+v, want the results shown", p)
+		}
+	})
+}
+
+func TestE2EBookmarks(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		var chirps []Chirp
+		for _, body := range []string{"one", "two", "three"} {
+			chirps = append(chirps, api.chirp(t, bob, body))
+		}
+
+		resp := api.do(t, "POST", "/api/collections", alice.bearer(), map[string]string{"name": "Later"})
+		expectStatus(t, resp, http.StatusCreated)
+		later := decode[Collection](t, resp)
+		resp = api.do(t, "POST", "/api/collections", alice.bearer(), map[string]string{"name": "later"})
+		expectProblem(t, resp, http.StatusConflict, CodeConflict)
+		resp = api.do(t, "POST", "/api/collections", alice.bearer(), map[string]string{"name": ""})
+		expectProblem(t, resp, http.StatusBadRequest, CodeRequired)
+		resp = api.do(t, "PUT", "/api/collections/"+later.ID.String(), bob.bearer(), map[string]string{"name": "Mine"})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// the body is optional, with it the bookmark goes into one of your collections
+		for _, c := range chirps {
+			resp = api.do(t, "POST", "/api/chirps/"+c.ID.String()+"/bookmark", alice.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+		}
+		bookmark := "/api/chirps/" + chirps[0].ID.String() + "/bookmark"
+		resp = api.do(t, "POST", bookmark, bob.bearer(), map[string]any{"collection_id": later.ID})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "POST", bookmark, alice.bearer(), map[string]any{"collection_id": later.ID})
+		expectStatus(t, resp, http.StatusOK)
+		if b := decode[Bookmark](t, resp); b.CollectionID == nil || *b.CollectionID != later.ID || b.Chirp.ID != chirps[0].ID {
+			t.Errorf("bookmark = %+v, want it in the collection", b)
+		}
+		resp = api.do(t, "POST", "/api/chirps/"+uuid.NewString()+"/bookmark", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// pages of bookmarks, the last saved first
+		resp = api.do(t, "GET", "/api/bookmarks?limit=2", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		page := decode[bookmarksResponse](t, resp)
+		if len(page.Bookmarks) != 2 || page.Bookmarks[0].Chirp.ID != chirps[2].ID || page.NextCursor == nil {
+			t.Fatalf("first page = %+v", page)
+		}
+		resp = api.do(t, "GET", "/api/bookmarks?limit=2&cursor="+page.NextCursor.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if page := decode[bookmarksResponse](t, resp); len(page.Bookmarks) != 1 || page.Bookmarks[0].Chirp.ID != chirps[0].ID || page.NextCursor != nil {
+			t.Errorf("last page = %+v", page)
+		}
+		resp = api.do(t, "GET", "/api/bookmarks?collection_id="+later.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if page := decode[bookmarksResponse](t, resp); len(page.Bookmarks) != 1 {
+			t.Errorf("bookmarks in the collection = %+v", page)
+		}
+		resp = api.do(t, "GET", "/api/bookmarks?collection_id="+later.ID.String(), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		if page := decode[bookmarksResponse](t, api.do(t, "GET", "/api/bookmarks", bob.bearer(), nil)); len(page.Bookmarks) != 0 {
+			t.Errorf("bookmarks of another user = %+v, want none", page)
+		}
+
+		// deleted chirps take their bookmarks with them, deleted collections leave them
+		resp = api.do(t, "DELETE", "/api/chirps/"+chirps[2].ID.String(), bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "DELETE", "/api/collections/"+later.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		page = decode[bookmarksResponse](t, api.do(t, "GET", "/api/bookmarks", alice.bearer(), nil))
+		if len(page.Bookmarks) != 2 || page.Bookmarks[1].CollectionID != nil {
+			t.Errorf("bookmarks after deleting a chirp and the collection = %+v", page)
+		}
+		resp = api.do(t, "DELETE", bookmark, alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "DELETE", bookmark, alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+	})
+}
+
+func TestE2EPins(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		var chirps []Chirp
+		for i := range maxPins + 2 {
+			chirps = append(chirps, api.chirp(t, alice, "chirp "+strings.Repeat("!", i)))
+		}
+		pin := func(c Chirp) string { return "/api/users/me/pins/" + c.ID.String() }
+
+		resp := api.do(t, "PUT", pin(chirps[0]), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "PUT", "/api/users/me/pins/"+uuid.NewString(), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		for _, c := range chirps[:maxPins] {
+			resp = api.do(t, "PUT", pin(c), alice.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+			if got := decode[Chirp](t, resp); !got.Pinned {
+				t.Errorf("pinned chirp = %+v, want pinned", got)
+			}
+		}
+		// pinning again is not one more pin
+		resp = api.do(t, "PUT", pin(chirps[0]), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp = api.do(t, "PUT", pin(chirps[maxPins]), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusConflict, CodeTooManyPins)
+
+		// pinned chirps come first, the last pinned first, only in the list of their author
+		list := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps?author_id="+alice.ID.String()+"&sort=desc", "", nil))
+		want := []uuid.UUID{chirps[2].ID, chirps[1].ID, chirps[0].ID, chirps[4].ID, chirps[3].ID}
+		for i, c := range list {
+			if i >= len(want) || c.ID != want[i] || c.Pinned != (i < maxPins) {
+				t.Fatalf("chirps of the author = %+v, want %v", list, want)
+			}
+		}
+		if all := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); all[0].ID != chirps[0].ID {
+			t.Errorf("all chirps start with %s, want them by creation time", all[0].Body)
+		}
+
+		resp = api.do(t, "DELETE", pin(chirps[1]), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "DELETE", pin(chirps[1]), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "DELETE", pin(chirps[1]), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// Chirpy Red members can pin more
+		resp = api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}})
+		expectStatus(t, resp, http.StatusNoContent)
+		for _, c := range chirps[maxPins-1:] {
+			resp = api.do(t, "PUT", pin(c), alice.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+		}
+	})
+}
+
+func TestE2EContentWarnings(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		moderator := api.withRole(t, api.signup(t, "mod@example.com"), auth.RoleModerator)
+
+		resp := api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "the ending", "content_warning": "kerfuffle spoilers", "sensitive": true})
+		expectStatus(t, resp, http.StatusCreated)
+		warned := decode[Chirp](t, resp)
+		if warned.ContentWarning == nil || *warned.ContentWarning != "**** spoilers" || !warned.Sensitive || !warned.Collapsed {
+			t.Errorf("chirp with a warning = %+v", warned)
+		}
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "long", "content_warning": strings.Repeat("a", maxContentWarning+1)})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		plain := api.chirp(t, bob, "hello")
+		if plain.ContentWarning != nil || plain.Collapsed {
+			t.Errorf("chirp without a warning = %+v", plain)
+		}
+
+		// only moderators set warnings on chirps after they are posted
+		warning := "/api/chirps/" + plain.ID.String() + "/warning"
+		resp = api.do(t, "PUT", warning, bob.bearer(), map[string]any{"content_warning": "loud"})
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "PUT", warning, moderator.bearer(), map[string]any{"content_warning": "loud"})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[Chirp](t, resp); got.ContentWarning == nil || *got.ContentWarning != "loud" || got.Sensitive {
+			t.Errorf("chirp after the moderator's warning = %+v", got)
+		}
+		resp = api.do(t, "PUT", "/api/chirps/"+uuid.NewString()+"/warning", moderator.bearer(), map[string]any{"sensitive": true})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// the preference expands or hides chirps with a warning
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]any{"sensitive_content": "blur"})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]any{"sensitive_content": "expand"})
+		expectStatus(t, resp, http.StatusOK)
+		if u := decode[User](t, resp); u.SensitiveContent != "expand" {
+			t.Errorf("sensitive_content = %q, want expand", u.SensitiveContent)
+		}
+		for _, c := range decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", bob.bearer(), nil)) {
+			if c.Collapsed {
+				t.Errorf("chirp %q is collapsed for a user who expands them", c.Body)
+			}
+		}
+		resp = api.do(t, "PUT", "/api/users", bob.bearer(), map[string]any{"sensitive_content": "hide"})
+		expectStatus(t, resp, http.StatusOK)
+		list := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", bob.bearer(), nil))
+		if len(list) != 1 || list[0].ID != plain.ID {
+			t.Errorf("chirps when hiding them = %+v, want only the own one", list)
+		}
+		if list := decode[[]Chirp](t, api.do(t, "GET", "/api/chirps", "", nil)); len(list) != 2 || !list[0].Collapsed {
+			t.Errorf("chirps for anonymous readers = %+v, want both collapsed", list)
+		}
+
+		resp = api.do(t, "PUT", warning, moderator.bearer(), map[string]any{"content_warning": nil})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[Chirp](t, resp); got.ContentWarning != nil || got.Collapsed {
+			t.Errorf("chirp after removing the warning = %+v", got)
+		}
+	})
+}
+
+func TestE2EVisibility(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		carol := api.signup(t, "carol@example.com")
+		post := func(body, visibility string) Chirp {
+			t.Helper()
+			resp := api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": body, "visibility": visibility})
+			expectStatus(t, resp, http.StatusCreated)
+			c := decode[Chirp](t, resp)
+			if c.Visibility != visibility {
+				t.Errorf("visibility = %q, want %q", c.Visibility, visibility)
+			}
+			return c
+		}
+		// subscribeAlice subscribes to the chirps of alice over the WebSocket
+		subscribeAlice := func(u testUser) *websocket.Conn {
+			t.Helper()
+			conn := api.dialWebsocket(t, u.token)
+			wsSend(t, conn, map[string]string{"type": "subscribe", "id": "alice", "channel": "author", "author_id": alice.ID.String()})
+			expectWsMessage(t, wsReceive(t, conn), "subscribed", "alice")
+			return conn
+		}
+		bodies := func(authorization, query string) []string {
+			t.Helper()
+			resp := api.do(t, "GET", "/api/chirps"+query, authorization, nil)
+			expectStatus(t, resp, http.StatusOK)
+			var got []string
+			for _, c := range decode[[]Chirp](t, resp) {
+				got = append(got, c.Body)
+			}
+			return got
+		}
+		getChirp := func(authorization string, c Chirp) int {
+			t.Helper()
+			return api.do(t, "GET", "/api/chirps/"+c.ID.String(), authorization, nil).status
+		}
+
+		resp := api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "hello", "visibility": "secret"})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", "/api/users/"+alice.ID.String()+"/follow", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+
+		stream := api.openStream(t, "", "")
+		bobConn := subscribeAlice(bob)
+		carolConn := subscribeAlice(carol)
+
+		// following twice is fine, alice is notified once
+		for range 2 {
+			resp = api.do(t, "POST", "/api/users/"+alice.ID.String()+"/follow", bob.bearer(), nil)
+			expectStatus(t, resp, http.StatusNoContent)
+		}
+		resp = api.do(t, "GET", "/api/notifications", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[notificationsResponse](t, resp).Notifications; len(got) != 1 || got[0].Type != notificationFollow || *got[0].ActorID != bob.ID {
+			t.Errorf("notifications after being followed = %+v", got)
+		}
+
+		unlisted := post("by link", visibilityUnlisted)
+		friends := post("for friends", visibilityFollowers)
+		public := post("for all", visibilityPublic)
+
+		// streams and subscriptions leave out what the reader may not list
+		expectChirpEvent(t, nextEvent(t, stream), events.ChirpCreated, public)
+		if got := wsReceive(t, carolConn); !strings.Contains(string(got.Data), public.ID.String()) {
+			t.Errorf("first chirp of alice for carol = %s, want the public one", got.Data)
+		}
+		for _, want := range []Chirp{friends, public} {
+			if got := wsReceive(t, bobConn); !strings.Contains(string(got.Data), want.ID.String()) {
+				t.Errorf("chirp of alice for bob = %s, want %q", got.Data, want.Body)
+			}
+		}
+
+		if got := bodies("", ""); !slices.Equal(got, []string{"for all"}) {
+			t.Errorf("chirps for anonymous readers = %v", got)
+		}
+		if got := bodies(carol.bearer(), "?author_id="+alice.ID.String()); !slices.Equal(got, []string{"for all"}) {
+			t.Errorf("chirps of alice for carol = %v", got)
+		}
+		if got := bodies(bob.bearer(), ""); !slices.Equal(got, []string{"for friends", "for all"}) {
+			t.Errorf("chirps for a follower = %v", got)
+		}
+		if got := bodies(alice.bearer(), ""); len(got) != 3 {
+			t.Errorf("chirps for the author = %v, want all three", got)
+		}
+
+		if status := getChirp("", unlisted); status != http.StatusOK {
+			t.Errorf("GET unlisted chirp = %d, want 200", status)
+		}
+		if status := getChirp(carol.bearer(), friends); status != http.StatusNotFound {
+			t.Errorf("GET followers-only chirp by a stranger = %d, want 404", status)
+		}
+		if status := getChirp(bob.bearer(), friends); status != http.StatusOK {
+			t.Errorf("GET followers-only chirp by a follower = %d, want 200", status)
+		}
+
+		// changing a chirp the user cannot see tells them it does not exist, not who may change it
+		for _, change := range []struct{ method, path string }{
+			{"DELETE", "/api/chirps/" + friends.ID.String()},
+			{"PUT", "/api/users/me/pins/" + friends.ID.String()},
+			{"DELETE", "/api/users/me/pins/" + friends.ID.String()},
+		} {
+			resp = api.do(t, change.method, change.path, carol.bearer(), nil)
+			expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+			resp = api.do(t, change.method, change.path, bob.bearer(), nil)
+			expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		}
+
+		resp = api.do(t, "POST", "/api/users/"+alice.ID.String()+"/follow", carol.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "DELETE", "/api/users/"+alice.ID.String()+"/follow", carol.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		if status := getChirp(carol.bearer(), friends); status != http.StatusNotFound {
+			t.Errorf("GET followers-only chirp after unfollowing = %d, want 404", status)
+		}
+
+		// blocking ends the follow and keeps it from coming back
+		resp = api.do(t, "POST", "/api/users/"+bob.ID.String()+"/block", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		if status := getChirp(bob.bearer(), friends); status != http.StatusNotFound {
+			t.Errorf("GET followers-only chirp after being blocked = %d, want 404", status)
+		}
+		resp = api.do(t, "POST", "/api/users/"+alice.ID.String()+"/follow", bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+	})
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestE2EMedia(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+
+		resp := api.upload(t, "POST", "/api/media", alice, testPNG(t, 3000, 100))
+		expectStatus(t, resp, http.StatusCreated)
+		wide := decode[Media](t, resp)
+		if wide.Width != maxImageSide || wide.ContentType != "image/png" || !strings.HasPrefix(wide.URL, "/media/") {
+			t.Errorf("uploaded media = %+v", wide)
+		}
+		resp = api.do(t, "GET", wide.ThumbnailURL, "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if thumb, err := png.DecodeConfig(bytes.NewReader(resp.body)); err != nil || thumb.Width != thumbnailSide {
+			t.Errorf("thumbnail = %+v, %v", thumb, err)
+		}
+		if resp.header.Get("Content-Type") != "image/png" || resp.header.Get("Cache-Control") == "" {
+			t.Errorf("media headers = %v", resp.header)
+		}
+
+		// metadata never reaches the stored file
+		var jpg bytes.Buffer
+		jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 10, 10)), nil)
+		exif := []byte("Exif\x00\x00GPS 52.52N 13.40E")
+		withExif := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+		withExif = append(withExif, jpg.Bytes()[2:]...)
+		resp = api.upload(t, "POST", "/api/media", alice, withExif)
+		expectStatus(t, resp, http.StatusCreated)
+		photo := decode[Media](t, resp)
+		resp = api.do(t, "GET", photo.URL, "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if bytes.Contains(resp.body, []byte("GPS")) || resp.header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("stored JPEG kept its EXIF data")
+		}
+
+		resp = api.upload(t, "POST", "/api/media", alice, []byte("just text"))
+		expectProblem(t, resp, http.StatusUnsupportedMediaType, CodeUnsupportedMedia)
+		resp = api.upload(t, "POST", "/api/media", alice, bytes.Repeat([]byte{0}, maxImageBytes+1))
+		expectProblem(t, resp, http.StatusRequestEntityTooLarge, CodeRequestTooLarge)
+		resp = api.do(t, "POST", "/api/media", alice.bearer(), map[string]string{"file": "x"})
+		expectProblem(t, resp, http.StatusUnsupportedMediaType, CodeUnsupportedMedia)
+		resp = api.do(t, "GET", "/media/media/missing.png", "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// attaching
+		resp = api.do(t, "POST", "/api/chirps", bob.bearer(), map[string]any{"body": "not mine", "media_ids": []uuid.UUID{photo.ID}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "too many", "media_ids": []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "pictures", "media_ids": []uuid.UUID{photo.ID, wide.ID}})
+		expectStatus(t, resp, http.StatusCreated)
+		chirp := decode[Chirp](t, resp)
+		if len(chirp.Media) != 2 || chirp.Media[0].ID != photo.ID || chirp.Media[1].ID != wide.ID {
+			t.Errorf("chirp media = %+v", chirp.Media)
+		}
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]any{"body": "again", "media_ids": []uuid.UUID{photo.ID}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		if plain := api.chirp(t, bob, "no pictures"); plain.Media == nil || len(plain.Media) != 0 {
+			t.Errorf("chirp without media = %+v, want an empty list", plain.Media)
+		}
+
+		// deleting the chirp deletes the files
+		resp = api.do(t, "DELETE", "/api/chirps/"+chirp.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "GET", photo.URL, "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// avatars
+		resp = api.upload(t, "PUT", "/api/users/me/avatar", alice, testPNG(t, 800, 500))
+		expectStatus(t, resp, http.StatusOK)
+		first := decode[User](t, resp)
+		resp = api.do(t, "GET", first.AvatarURL, "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if avatar, err := png.DecodeConfig(bytes.NewReader(resp.body)); err != nil || avatar.Width != avatarSide || avatar.Height != avatarSide {
+			t.Errorf("avatar = %+v, %v, want a %d pixel square", avatar, err, avatarSide)
+		}
+		resp = api.upload(t, "PUT", "/api/users/me/avatar", alice, testPNG(t, 50, 50))
+		expectStatus(t, resp, http.StatusOK)
+		if second := decode[User](t, resp); second.AvatarURL == first.AvatarURL {
+			t.Errorf("avatar_url did not change")
+		}
+		resp = api.do(t, "GET", first.AvatarURL, "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+	})
+}
+
+func TestE2ERefreshAndRevoke(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+
+		resp := api.do(t, "POST", "/api/refresh", "", nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+
+		resp = api.do(t, "POST", "/api/refresh", "Bearer "+user.refreshToken, nil)
+		expectStatus(t, resp, http.StatusOK)
+		refreshed := decode[refreshResponse](t, resp)
+		resp = api.do(t, "POST", "/api/chirps", "Bearer "+refreshed.AccessToken, map[string]string{"body": "refreshed"})
+		expectStatus(t, resp, http.StatusCreated)
+
+		// an access token is not a refresh token
+		resp = api.do(t, "POST", "/api/refresh", user.bearer(), nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidToken)
+
+		resp = api.do(t, "POST", "/api/revoke", "Bearer "+user.refreshToken, nil)
+		expectStatus(t, resp, http.StatusNoContent)
+
+		resp = api.do(t, "POST", "/api/refresh", "Bearer "+user.refreshToken, nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidToken)
+	})
+}
+
+func TestE2EChirps(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+
+		resp := api.do(t, "POST", "/api/chirps", "", map[string]string{"body": "anonymous"})
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+
+		resp = api.do(t, "POST", "/api/chirps", alice.bearer(), map[string]string{"body": strings.Repeat("a", 141)})
+		expectProblem(t, resp, http.StatusBadRequest, CodeChirpTooLong)
+
+		first := api.chirp(t, alice, "Example Chirp text with profane Fornax word")
+		if first.Body != "Example Chirp text with profane **** word" || first.UserID != alice.ID {
+			t.Errorf("created chirp = %+v", first)
+		}
+		second := api.chirp(t, bob, "second")
+		third := api.chirp(t, alice, "third")
+
+		resp = api.do(t, "GET", "/api/chirps/"+second.ID.String(), "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[Chirp](t, resp); !reflect.DeepEqual(got, second) {
+			t.Errorf("get chirp = %+v, want %+v", got, second)
+		}
+		resp = api.do(t, "GET", "/api/chirps/"+uuid.NewString(), "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "GET", "/api/chirps/not-a-uuid", "", nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+
+		listIDs := func(path string) []uuid.UUID {
+			t.Helper()
+			resp := api.do(t, "GET", path, "", nil)
+			expectStatus(t, resp, http.StatusOK)
+			ids := []uuid.UUID{}
+			for _, c := range decode[[]Chirp](t, resp) {
+				ids = append(ids, c.ID)
+			}
+			return ids
+		}
+		expectIDs := func(path string, want ...uuid.UUID) {
+			t.Helper()
+			got := listIDs(path)
+			if len(got) != len(want) {
+				t.Fatalf("%s = %v, want %v", path, got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("%s = %v, want %v", path, got, want)
+				}
+			}
+		}
+		expectIDs("/api/chirps", first.ID, second.ID, third.ID)
+		expectIDs("/api/chirps?sort=asc", first.ID, second.ID, third.ID)
+		expectIDs("/api/chirps?sort=desc", third.ID, second.ID, first.ID)
+		expectIDs("/api/chirps?author_id="+alice.ID.String(), first.ID, third.ID)
+		expectIDs("/api/chirps?author_id="+alice.ID.String()+"&sort=desc", third.ID, first.ID)
+		expectIDs("/api/chirps?author_id=" + uuid.NewString())
+
+		resp = api.do(t, "GET", "/api/chirps?author_id=nobody", "", nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+
+		resp = api.do(t, "DELETE", "/api/chirps/"+first.ID.String(), bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "DELETE", "/api/chirps/"+first.ID.String(), "", nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		resp = api.do(t, "DELETE", "/api/chirps/"+first.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "DELETE", "/api/chirps/"+first.ID.String(), alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		expectIDs("/api/chirps", second.ID, third.ID)
+	})
+}
+
+func TestE2EPolkaWebhook(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		event := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": user.ID.String()}}
+
+		resp := api.do(t, "POST", "/api/polka/webhooks", "", event)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidAPIKey)
+		resp = api.do(t, "POST", "/api/polka/webhooks", "ApiKey wrong", event)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeInvalidAPIKey)
+
+		resp = api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.payment_failed", "data": map[string]string{"user_id": user.ID.String()}})
+		expectStatus(t, resp, http.StatusNoContent)
+		if api.login(t, user.Email, user.password).IsChirpyRed {
+			t.Errorf("unknown event upgraded the user")
+		}
+
+		resp = api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, event)
+		expectStatus(t, resp, http.StatusNoContent)
+		if !api.login(t, user.Email, user.password).IsChirpyRed {
+			t.Errorf("user.upgraded did not upgrade the user")
+		}
+	})
+}
+
+func TestE2EAdmin(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		admin := api.withRole(t, api.signup(t, "admin@example.com"), auth.RoleAdmin)
+		api.chirp(t, user, "hello")
+
+		resp := api.do(t, "GET", "/admin/metrics", "", nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		resp = api.do(t, "GET", "/admin/metrics", user.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "GET", "/admin/metrics", admin.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+
+		api.cfg.platform = "prod"
+		resp = api.do(t, "POST", "/admin/reset", admin.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+
+		api.cfg.platform = "dev"
+		resp = api.do(t, "POST", "/admin/reset", user.bearer(), nil)
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "POST", "/admin/reset", admin.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+
+		resp = api.do(t, "GET", "/api/chirps", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if chirps := decode[[]Chirp](t, resp); len(chirps) != 0 {
+			t.Errorf("chirps after reset = %v, want none", chirps)
+		}
+	})
+}
+
+func TestE2EAdminUsers(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		admin := func(args ...string) error {
+			return runAdmin(context.Background(), api.cfg, io.Discard, args)
+		}
+
+		err := admin("create-user", "-email", "mod@example.com", "-password", "secret", "-role", "moderator")
+		if err != nil {
+			t.Fatalf("admin create-user: %v", err)
+		}
+		user := api.login(t, "mod@example.com", "secret")
+		if user.Role != "moderator" || user.IsChirpyRed {
+			t.Errorf("created user = %+v, want a moderator without Chirpy Red", user.User)
+		}
+		if err := admin("create-user", "-email", "mod@example.com", "-password", "secret"); err == nil {
+			t.Errorf("admin create-user with a taken email succeeded")
+		}
+		if err := admin("create-user", "-email", "x@example.com", "-password", "secret", "-role", "king"); err == nil {
+			t.Errorf("admin create-user with an unknown role succeeded")
+		}
+		if err := admin("create-user", "-email", "x@example.com"); err == nil {
+			t.Errorf("admin create-user without -password succeeded")
+		}
+
+		err = admin("reset-password", "-email", user.Email, "-password", "new secret")
+		if err != nil {
+			t.Fatalf("admin reset-password: %v", err)
+		}
+		resp := api.do(t, "POST", "/api/login", "", map[string]string{"email": user.Email, "password": "secret"})
+		expectStatus(t, resp, http.StatusUnauthorized)
+		user = api.login(t, user.Email, "new secret")
+
+		err = admin("grant-red", "-email", user.Email)
+		if err != nil {
+			t.Fatalf("admin grant-red: %v", err)
+		}
+		if !api.login(t, user.Email, "new secret").IsChirpyRed {
+			t.Errorf("user is not Chirpy Red after grant-red")
+		}
+		err = admin("revoke-red", "-email", user.Email)
+		if err != nil {
+			t.Fatalf("admin revoke-red: %v", err)
+		}
+		if api.login(t, user.Email, "new secret").IsChirpyRed {
+			t.Errorf("user is still Chirpy Red after revoke-red")
+		}
+
+		err = admin("set-role", "-email", user.Email, "-role", "admin")
+		if err != nil {
+			t.Fatalf("admin set-role: %v", err)
+		}
+		if role := api.login(t, user.Email, "new secret").Role; role != "admin" {
+			t.Errorf("role after set-role = %s, want admin", role)
+		}
+
+		for _, args := range [][]string{
+			{"reset-password", "-email", "missing@example.com", "-password", "secret"},
+			{"grant-red", "-email", "missing@example.com"},
+			{"revoke-red", "-email", "missing@example.com"},
+			{"set-role", "-email", "missing@example.com", "-role", "admin"},
+			{"set-role", "-email", user.Email, "-role", "king"},
+			{"unknown"},
+			{},
+		} {
+			if err := admin(args...); err == nil {
+				t.Errorf("admin %v succeeded", args)
+			}
+		}
+	})
+}
+
+func TestE2EAdminSeed(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		// seeding twice must not run into taken emails
+		for range 2 {
+			err := runAdmin(context.Background(), api.cfg, io.Discard, []string{"seed", "-users", "3", "-chirps", "2", "-password", "seeded"})
+			if err != nil {
+				t.Fatalf("admin seed: %v", err)
+			}
+		}
+
+		resp := api.do(t, "GET", "/api/chirps", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		chirps := decode[[]Chirp](t, resp)
+		if len(chirps) != 12 {
+			t.Fatalf("chirps after seeding = %d, want 12", len(chirps))
+		}
+		authors := map[uuid.UUID]bool{}
+		for _, c := range chirps {
+			authors[c.UserID] = true
+		}
+		if len(authors) != 6 {
+			t.Errorf("authors after seeding = %d, want 6", len(authors))
+		}
+
+		var out strings.Builder
+		err := runAdmin(context.Background(), api.cfg, &out, []string{"seed", "-users", "1", "-chirps", "0", "-password", "seeded"})
+		if err != nil {
+			t.Fatalf("admin seed: %v", err)
+		}
+		var email string
+		_, err = fmt.Sscanf(out.String(), "Seeded user %s with", &email)
+		if err != nil {
+			t.Fatalf("seed output %q: %v", out.String(), err)
+		}
+		api.login(t, email, "seeded")
+
+		err = runAdmin(context.Background(), api.cfg, io.Discard, []string{"seed", "-users", "-1"})
+		if err == nil {
+			t.Errorf("admin seed with -users -1 succeeded")
+		}
+	})
+}
+
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// openStream starts reading the chirp stream, it is closed at the end of the test
+func (api *testAPI) openStream(t *testing.T, query, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", api.srv.URL+"/api/chirps/stream"+query, nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := api.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/chirps/stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// nextEvent reads up to the next event, skipping comments and the retry field
+func nextEvent(t *testing.T, stream *bufio.Reader) streamEvent {
+	t.Helper()
+	var e streamEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if e.event != "" {
+				return e
+			}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.event = value
+		case "data":
+			e.data = value
+		}
+	}
+}
+
+func expectChirpEvent(t *testing.T, e streamEvent, wantEvent events.Type, want Chirp) {
+	t.Helper()
+	if e.event != string(wantEvent) {
+		t.Fatalf("event = %q, want %q", e.event, wantEvent)
+	}
+	var got Chirp
+	err := json.Unmarshal([]byte(e.data), &got)
+	if err != nil {
+		t.Fatalf("Error decoding %q: %v", e.data, err)
+	}
+	if got.ID != want.ID {
+		t.Errorf("%s chirp = %+v, want %+v", e.event, got, want)
+	}
+}
+
+func TestE2EChirpStream(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+
+		resp := api.do(t, "GET", "/api/chirps/stream?author_id=nobody", "", nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+		all := api.openStream(t, "", "")
+		fromAlice := api.openStream(t, "?author_id="+alice.ID.String(), "")
+
+		fromBob := api.chirp(t, bob, "from bob")
+		chirp := api.chirp(t, alice, "from alice")
+		resp = api.do(t, "DELETE", "/api/chirps/"+chirp.ID.String(), alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+
+		first := nextEvent(t, all)
+		expectChirpEvent(t, first, events.ChirpCreated, fromBob)
+		expectChirpEvent(t, nextEvent(t, all), events.ChirpCreated, chirp)
+		expectChirpEvent(t, nextEvent(t, all), events.ChirpDeleted, chirp)
+
+		expectChirpEvent(t, nextEvent(t, fromAlice), events.ChirpCreated, chirp)
+		expectChirpEvent(t, nextEvent(t, fromAlice), events.ChirpDeleted, chirp)
+
+		// a reconnecting client gets what it missed
+		resumed := api.openStream(t, "", first.id)
+		expectChirpEvent(t, nextEvent(t, resumed), events.ChirpCreated, chirp)
+		expectChirpEvent(t, nextEvent(t, resumed), events.ChirpDeleted, chirp)
+
+		// chirps deleted by an admin command are announced as well
+		err := runAdmin(context.Background(), api.cfg, io.Discard, []string{"delete-chirp", "-id", fromBob.ID.String()})
+		if err != nil {
+			t.Fatalf("admin delete-chirp: %v", err)
+		}
+		expectChirpEvent(t, nextEvent(t, all), events.ChirpDeleted, fromBob)
+		resp = api.do(t, "GET", "/api/chirps/"+fromBob.ID.String(), "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		req, err := http.NewRequest("GET", api.srv.URL+"/api/chirps/stream", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Last-Event-ID", "not-an-id")
+		badResp, err := api.srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET /api/chirps/stream: %v", err)
+		}
+		badResp.Body.Close()
+		if badResp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status with invalid Last-Event-ID = %d, want 400", badResp.StatusCode)
+		}
+	})
+}
+
+func TestE2EUserEvents(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		sub := api.cfg.events.Subscribe(0)
+		defer sub.Close()
+		stream := api.openStream(t, "", "")
+
+		resp := api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": user.ID.String()}})
+		expectStatus(t, resp, http.StatusNoContent)
+		resp = api.do(t, "POST", "/api/revoke", "Bearer "+user.refreshToken, nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		chirp := api.chirp(t, user, "hello")
+
+		upgraded := <-sub.Events()
+		if upgraded.Type != events.UserUpgraded || upgraded.UserID != user.ID {
+			t.Errorf("first event = %+v, want %s", upgraded, events.UserUpgraded)
+		}
+		var u User
+		err := json.Unmarshal(upgraded.Data, &u)
+		if err != nil || !u.IsChirpyRed {
+			t.Errorf("%s data = %s", upgraded.Type, upgraded.Data)
+		}
+		notification := <-sub.Events()
+		if notification.Type != events.NotificationCreated || notification.UserID != user.ID {
+			t.Errorf("second event = %+v, want %s", notification, events.NotificationCreated)
+		}
+		revoked := <-sub.Events()
+		if revoked.Type != events.SessionRevoked || revoked.UserID != user.ID {
+			t.Errorf("third event = %+v, want %s", revoked, events.SessionRevoked)
+		}
+
+		// the chirp stream only sends chirp events
+		expectChirpEvent(t, nextEvent(t, stream), events.ChirpCreated, chirp)
+	})
+}
+
+// dialWebsocket connects to the WebSocket API with the access token
+func (api *testAPI) dialWebsocket(t *testing.T, token string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(api.srv.URL, "http")+"/api/ws", header)
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func wsSend(t *testing.T, conn *websocket.Conn, msg map[string]string) {
+	t.Helper()
+	err := conn.WriteJSON(msg)
+	if err != nil {
+		t.Fatalf("Error sending %v: %v", msg, err)
+	}
+}
+
+func wsReceive(t *testing.T, conn *websocket.Conn) wsServerMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsServerMessage
+	err := conn.ReadJSON(&msg)
+	if err != nil {
+		t.Fatalf("Error receiving: %v", err)
+	}
+	return msg
+}
+
+func expectWsMessage(t *testing.T, got wsServerMessage, wantType, wantID string) {
+	t.Helper()
+	if got.Type != wantType || got.ID != wantID {
+		t.Fatalf("received %+v, want %s for %q", got, wantType, wantID)
+	}
+}
+
+func expectWsClosed(t *testing.T, conn *websocket.Conn, wantCode int) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, wantCode) {
+		t.Fatalf("read error = %v, want close %d", err, wantCode)
+	}
+}
+
+func TestE2EWebsocket(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(api.srv.URL, "http")+"/api/ws", nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("connecting without a token: %v, want 401", err)
+		}
+
+		conn := api.dialWebsocket(t, alice.token)
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "home", "channel": "timeline"})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "home")
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "bob", "channel": "author", "author_id": bob.ID.String()})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "bob")
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "me", "channel": "notifications"})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "me")
+
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "me", "channel": "timeline"})
+		if got := wsReceive(t, conn); got.Type != "error" || got.Code != CodeConflict {
+			t.Errorf("reused subscription id: %+v", got)
+		}
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "x", "channel": "everything"})
+		if got := wsReceive(t, conn); got.Type != "error" || got.Code != CodeValidationFailed {
+			t.Errorf("unknown channel: %+v", got)
+		}
+		err = conn.WriteMessage(websocket.TextMessage, []byte("{"))
+		if err != nil {
+			t.Fatalf("Error sending: %v", err)
+		}
+		if got := wsReceive(t, conn); got.Type != "error" || got.Code != CodeInvalidJSON {
+			t.Errorf("invalid JSON: %+v", got)
+		}
+
+		fromAlice := api.chirp(t, alice, "from alice")
+		got := wsReceive(t, conn)
+		expectWsMessage(t, got, "event", "home")
+		if got.Event != events.ChirpCreated || !strings.Contains(string(got.Data), fromAlice.ID.String()) {
+			t.Errorf("timeline event = %+v", got)
+		}
+
+		// the timeline only has the chirps of followed users
+		carol := api.signup(t, "carol@example.com")
+		api.chirp(t, carol, "from carol")
+		api.chirp(t, bob, "before the follow")
+		expectWsMessage(t, wsReceive(t, conn), "event", "bob")
+		follow := api.do(t, "POST", "/api/users/"+bob.ID.String()+"/follow", alice.bearer(), nil)
+		expectStatus(t, follow, http.StatusNoContent)
+		got = wsReceive(t, conn)
+		expectWsMessage(t, got, "event", "me")
+		if got.Event != events.UserFollowed {
+			t.Errorf("follow event = %+v", got)
+		}
+
+		// now a chirp of bob matches two subscriptions
+		api.chirp(t, bob, "from bob")
+		ids := map[string]bool{}
+		for range 2 {
+			got := wsReceive(t, conn)
+			expectWsMessage(t, got, "event", got.ID)
+			ids[got.ID] = true
+		}
+		if !ids["home"] || !ids["bob"] {
+			t.Errorf("bob's chirp went to %v, want home and bob", ids)
+		}
+
+		wsSend(t, conn, map[string]string{"type": "unsubscribe", "id": "home"})
+		expectWsMessage(t, wsReceive(t, conn), "unsubscribed", "home")
+
+		webhook := api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}})
+		expectStatus(t, webhook, http.StatusNoContent)
+		got = wsReceive(t, conn)
+		expectWsMessage(t, got, "event", "me")
+		if got.Event != events.UserUpgraded {
+			t.Errorf("account event = %+v", got)
+		}
+		got = wsReceive(t, conn)
+		expectWsMessage(t, got, "event", "me")
+		if got.Event != events.NotificationCreated || !strings.Contains(string(got.Data), notificationChirpyRed) {
+			t.Errorf("notification = %+v", got)
+		}
+
+		// a new token for the same user keeps the connection open
+		wsSend(t, conn, map[string]string{"type": "auth", "token": api.login(t, alice.Email, alice.password).token})
+		if got := wsReceive(t, conn); got.Type != "authenticated" || got.ExpiresAt == nil {
+			t.Errorf("auth: %+v", got)
+		}
+		wsSend(t, conn, map[string]string{"type": "auth", "token": "not-a-jwt"})
+		if got := wsReceive(t, conn); got.Type != "error" || got.Code != CodeInvalidToken {
+			t.Errorf("auth with an invalid token: %+v", got)
+		}
+		wsSend(t, conn, map[string]string{"type": "auth", "token": bob.token})
+		expectWsClosed(t, conn, websocket.ClosePolicyViolation)
+	})
+}
+
+func TestE2EWebsocketRevokedSessions(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		other := api.login(t, user.Email, user.password)
+		conn := api.dialWebsocket(t, user.token)
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "me", "channel": "notifications"})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "me")
+
+		// logging out on another device keeps the connection
+		resp := api.do(t, "POST", "/api/revoke", "Bearer "+other.refreshToken, nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		got := wsReceive(t, conn)
+		expectWsMessage(t, got, "event", "me")
+		if got.Event != events.SessionRevoked {
+			t.Errorf("logout event = %+v", got)
+		}
+
+		err := runAdmin(context.Background(), api.cfg, io.Discard, []string{"revoke-sessions", "-email", user.Email})
+		if err != nil {
+			t.Fatalf("admin revoke-sessions: %v", err)
+		}
+		expectWsClosed(t, conn, websocket.ClosePolicyViolation)
+	})
+}
+
+func TestE2EAdminDeleteUser(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		other := api.signup(t, "other@example.com")
+		chirp := api.chirp(t, user, "soon gone")
+		kept := api.chirp(t, other, "still here")
+		stream := api.openStream(t, "", "")
+		conn := api.dialWebsocket(t, user.token)
+
+		err := runAdmin(context.Background(), api.cfg, io.Discard, []string{"delete-user", "-email", user.Email})
+		if err != nil {
+			t.Fatalf("admin delete-user: %v", err)
+		}
+		expectChirpEvent(t, nextEvent(t, stream), events.ChirpDeleted, chirp)
+		expectWsClosed(t, conn, websocket.ClosePolicyViolation)
+		resp := api.do(t, "GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "GET", "/api/chirps/"+kept.ID.String(), "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp = api.do(t, "POST", "/api/login", "", map[string]string{"email": user.Email, "password": user.password})
+		expectStatus(t, resp, http.StatusUnauthorized)
+
+		err = runAdmin(context.Background(), api.cfg, io.Discard, []string{"delete-user", "-email", user.Email})
+		if err == nil {
+			t.Errorf("admin delete-user of a missing user succeeded")
+		}
+	})
+}
+
+func TestE2EWebsocketTokenExpiry(t *testing.T) {
+	api := newTestAPI(t, store.NewMemory())
+	user := api.signup(t, "user@example.com")
+	token, err := auth.MakeJWT(user.ID, auth.RoleUser, testSecret, 2*time.Second)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+
+	conn := api.dialWebsocket(t, token)
+	expectWsClosed(t, conn, websocket.ClosePolicyViolation)
+}
+
+func TestE2ENotifications(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		user := api.signup(t, "user@example.com")
+		upgrade := func() {
+			t.Helper()
+			resp := api.do(t, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": user.ID.String()}})
+			expectStatus(t, resp, http.StatusNoContent)
+		}
+		list := func(query string) notificationsResponse {
+			t.Helper()
+			resp := api.do(t, "GET", "/api/notifications"+query, user.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+			return decode[notificationsResponse](t, resp)
+		}
+
+		resp := api.do(t, "GET", "/api/notifications", "", nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		if got := list(""); len(got.Notifications) != 0 || got.UnreadCount != 0 || got.NextCursor != nil {
+			t.Errorf("notifications of a new user = %+v", got)
+		}
+
+		// turned off types are not created
+		resp = api.do(t, "PUT", "/api/notifications/preferences", user.bearer(), map[string]bool{"chirpy_red": false})
+		expectStatus(t, resp, http.StatusOK)
+		if prefs := decode[map[string]bool](t, resp); prefs["chirpy_red"] || !prefs["mention"] {
+			t.Errorf("preferences = %v", prefs)
+		}
+		resp = api.do(t, "PUT", "/api/notifications/preferences", user.bearer(), map[string]bool{"birthday": true})
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		upgrade()
+		if got := list(""); len(got.Notifications) != 0 {
+			t.Errorf("notifications with chirpy_red off = %+v", got)
+		}
+
+		resp = api.do(t, "PUT", "/api/notifications/preferences", user.bearer(), map[string]bool{"chirpy_red": true})
+		expectStatus(t, resp, http.StatusOK)
+		resp = api.do(t, "GET", "/api/notifications/preferences", user.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if prefs := decode[map[string]bool](t, resp); len(prefs) != len(getNotificationTypes()) || !prefs["chirpy_red"] {
+			t.Errorf("preferences = %v", prefs)
+		}
+		for range 3 {
+			upgrade()
+		}
+
+		// pages of two, newest first
+		first := list("?limit=2")
+		if len(first.Notifications) != 2 || first.UnreadCount != 3 || first.NextCursor == nil {
+			t.Fatalf("first page = %+v", first)
+		}
+		if n := first.Notifications[0]; n.Type != notificationChirpyRed || n.ReadAt != nil || n.ActorID != nil {
+			t.Errorf("notification = %+v", n)
+		}
+		second := list("?limit=2&cursor=" + first.NextCursor.String())
+		if len(second.Notifications) != 1 || second.NextCursor != nil {
+			t.Fatalf("second page = %+v", second)
+		}
+		resp = api.do(t, "GET", "/api/notifications?limit=1000", user.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "GET", "/api/notifications?cursor=nope", user.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+
+		resp = api.do(t, "POST", "/api/notifications/read", user.bearer(), map[string]any{})
+		expectProblem(t, resp, http.StatusBadRequest, CodeRequired)
+		resp = api.do(t, "POST", "/api/notifications/read", user.bearer(), map[string]any{"ids": []uuid.UUID{second.Notifications[0].ID}})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[markReadResponse](t, resp); got.Marked != 1 || got.UnreadCount != 2 {
+			t.Errorf("mark by id = %+v", got)
+		}
+		resp = api.do(t, "POST", "/api/notifications/read", user.bearer(), map[string]any{"up_to": first.Notifications[0].ID})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[markReadResponse](t, resp); got.Marked != 2 || got.UnreadCount != 0 {
+			t.Errorf("mark up to = %+v", got)
+		}
+		if got := list(""); got.Notifications[0].ReadAt == nil {
+			t.Errorf("notification was not marked as read: %+v", got.Notifications[0])
+		}
+	})
+}
+
+func TestE2EMentions(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		withHandle := func(email, handle string) testUser {
+			t.Helper()
+			u := api.signup(t, email)
+			resp := api.do(t, "PUT", "/api/users", u.bearer(), map[string]string{"handle": handle})
+			expectStatus(t, resp, http.StatusOK)
+			return u
+		}
+		alice := withHandle("alice@example.com", "alice")
+		bob := withHandle("bob@example.com", "bob")
+		carol := withHandle("carol@example.com", "carol")
+		mentions := func(u testUser) []Notification {
+			t.Helper()
+			resp := api.do(t, "GET", "/api/notifications", u.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+			var got []Notification
+			for _, n := range decode[notificationsResponse](t, resp).Notifications {
+				if n.Type == notificationMention {
+					got = append(got, n)
+				}
+			}
+			return got
+		}
+		post := func(body, visibility string) Chirp {
+			t.Helper()
+			resp := api.do(t, "POST", "/api/chirps", bob.bearer(), map[string]any{"body": body, "visibility": visibility})
+			expectStatus(t, resp, http.StatusCreated)
+			return decode[Chirp](t, resp)
+		}
+
+		// every mentioned user is notified once, the author and email addresses are not
+		chirp := post("hi @Alice and @alice, @carol and @nobody, mail bob@alice.com, says @bob", visibilityPublic)
+		for _, u := range []testUser{alice, carol} {
+			if got := mentions(u); len(got) != 1 || *got[0].ActorID != bob.ID || *got[0].ChirpID != chirp.ID {
+				t.Errorf("mentions of %s = %+v, want one of the chirp", u.Email, got)
+			}
+		}
+		if got := mentions(bob); len(got) != 0 {
+			t.Errorf("mentions of the author = %+v", got)
+		}
+
+		// only users who may see the chirp are notified
+		resp := api.do(t, "POST", "/api/users/"+bob.ID.String()+"/follow", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		post("for friends @alice @carol", visibilityFollowers)
+		if got := mentions(alice); len(got) != 2 {
+			t.Errorf("mentions of a follower = %d, want 2", len(got))
+		}
+		if got := mentions(carol); len(got) != 1 {
+			t.Errorf("mentions of a stranger in a followers-only chirp = %d, want 1", len(got))
+		}
+
+		// nor by blocked users
+		resp = api.do(t, "POST", "/api/users/"+bob.ID.String()+"/block", carol.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		post("still there @carol?", visibilityPublic)
+		if got := mentions(carol); len(got) != 1 {
+			t.Errorf("mentions by a blocked user = %d, want 1", len(got))
+		}
+	})
+}
+
+func TestE2EMessages(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		carol := api.signup(t, "carol@example.com")
+		sub := api.cfg.events.Subscribe(0)
+		defer sub.Close()
+
+		resp := api.do(t, "POST", "/api/conversations", alice.bearer(), map[string]any{"user_ids": []uuid.UUID{alice.ID}})
+		expectProblem(t, resp, http.StatusBadRequest, CodeRequired)
+		resp = api.do(t, "POST", "/api/conversations", alice.bearer(), map[string]any{"user_ids": []uuid.UUID{uuid.New()}})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+
+		// two users have a single conversation of their own
+		resp = api.do(t, "POST", "/api/conversations", alice.bearer(), map[string]any{"user_ids": []uuid.UUID{bob.ID}})
+		expectStatus(t, resp, http.StatusCreated)
+		direct := decode[Conversation](t, resp)
+		if direct.IsGroup || len(direct.Members) != 2 || direct.UnreadCount != 0 {
+			t.Errorf("direct conversation = %+v", direct)
+		}
+		resp = api.do(t, "POST", "/api/conversations", bob.bearer(), map[string]any{"user_ids": []uuid.UUID{alice.ID, bob.ID}})
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[Conversation](t, resp); got.ID != direct.ID {
+			t.Errorf("second direct conversation = %+v, want %s", got, direct.ID)
+		}
+		resp = api.do(t, "POST", "/api/conversations", alice.bearer(), map[string]any{"user_ids": []uuid.UUID{bob.ID, carol.ID}})
+		expectStatus(t, resp, http.StatusCreated)
+		group := decode[Conversation](t, resp)
+		if !group.IsGroup || len(group.Members) != 3 {
+			t.Errorf("group conversation = %+v", group)
+		}
+
+		// messages pass the chirp moderation
+		path := "/api/conversations/" + direct.ID.String()
+		resp = api.do(t, "POST", path+"/messages", alice.bearer(), map[string]string{"body": strings.Repeat("a", maxMessageLength+1)})
+		expectProblem(t, resp, http.StatusBadRequest, CodeMessageTooLong)
+		resp = api.do(t, "POST", path+"/messages", alice.bearer(), map[string]string{"body": "what a kerfuffle"})
+		expectStatus(t, resp, http.StatusCreated)
+		if got := decode[Message](t, resp); got.Body != "what a ****" || got.SenderID != alice.ID {
+			t.Errorf("message = %+v", got)
+		}
+		for _, body := range []string{"one", "two"} {
+			resp = api.do(t, "POST", path+"/messages", alice.bearer(), map[string]string{"body": body})
+			expectStatus(t, resp, http.StatusCreated)
+		}
+		e := <-sub.Events()
+		if e.Type != events.MessageCreated || e.UserID != bob.ID {
+			t.Errorf("event = %+v, want %s for bob", e, events.MessageCreated)
+		}
+
+		// only members see the conversation
+		resp = api.do(t, "GET", path, carol.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "POST", path+"/messages", carol.bearer(), map[string]string{"body": "hi"})
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "GET", "/api/conversations/nope", bob.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+
+		// the conversation with the latest message first
+		resp = api.do(t, "GET", "/api/conversations", bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		list := decode[[]Conversation](t, resp)
+		if len(list) != 2 || list[0].ID != direct.ID || list[0].UnreadCount != 3 || list[1].UnreadCount != 0 {
+			t.Errorf("conversations = %+v", list)
+		}
+
+		// pages of two, newest first
+		resp = api.do(t, "GET", path+"/messages?limit=2", bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		first := decode[messagesResponse](t, resp)
+		if len(first.Messages) != 2 || first.Messages[0].Body != "two" || first.NextCursor == nil {
+			t.Fatalf("first page = %+v", first)
+		}
+		resp = api.do(t, "GET", path+"/messages?limit=2&cursor="+first.NextCursor.String(), bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		second := decode[messagesResponse](t, resp)
+		if len(second.Messages) != 1 || second.NextCursor != nil || len(second.Messages[0].ReadBy) != 0 {
+			t.Fatalf("second page = %+v", second)
+		}
+
+		// read receipts
+		resp = api.do(t, "POST", path+"/read", bob.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		read := decode[Conversation](t, resp)
+		for _, m := range read.Members {
+			if (m.LastReadAt != nil) != (m.UserID == bob.ID) {
+				t.Errorf("last read of %s = %v", m.UserID, m.LastReadAt)
+			}
+		}
+		if read.UnreadCount != 0 {
+			t.Errorf("unread after reading = %d", read.UnreadCount)
+		}
+		resp = api.do(t, "GET", path+"/messages", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		for _, msg := range decode[messagesResponse](t, resp).Messages {
+			if !slices.Equal(msg.ReadBy, []uuid.UUID{bob.ID}) {
+				t.Errorf("read by of %q = %v, want bob", msg.Body, msg.ReadBy)
+			}
+		}
+		for e.Type != events.ConversationRead {
+			e = <-sub.Events()
+		}
+		if e.UserID != alice.ID {
+			t.Errorf("%s event for %s, want alice", e.Type, e.UserID)
+		}
+	})
+}
+
+func TestE2EBlocksAndMutes(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, api *testAPI) {
+		alice := api.signup(t, "alice@example.com")
+		bob := api.signup(t, "bob@example.com")
+		carol := api.signup(t, "carol@example.com")
+		api.chirp(t, bob, "from bob")
+		api.chirp(t, carol, "from carol")
+		authors := func(u testUser, query string) []uuid.UUID {
+			t.Helper()
+			resp := api.do(t, "GET", "/api/chirps"+query, u.bearer(), nil)
+			expectStatus(t, resp, http.StatusOK)
+			var ids []uuid.UUID
+			for _, c := range decode[[]Chirp](t, resp) {
+				ids = append(ids, c.UserID)
+			}
+			return ids
+		}
+
+		resp := api.do(t, "POST", "/api/users/"+bob.ID.String()+"/block", "", nil)
+		expectProblem(t, resp, http.StatusUnauthorized, CodeMissingToken)
+		resp = api.do(t, "POST", "/api/users/"+alice.ID.String()+"/block", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeValidationFailed)
+		resp = api.do(t, "POST", "/api/users/"+uuid.NewString()+"/mute", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusNotFound, CodeNotFound)
+		resp = api.do(t, "POST", "/api/users/nope/mute", alice.bearer(), nil)
+		expectProblem(t, resp, http.StatusBadRequest, CodeInvalidID)
+
+		for range 2 {
+			resp = api.do(t, "POST", "/api/users/"+bob.ID.String()+"/block", alice.bearer(), nil)
+			expectStatus(t, resp, http.StatusNoContent)
+		}
+		resp = api.do(t, "POST", "/api/users/"+carol.ID.String()+"/mute", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+
+		// only the blocker and muter stops seeing the chirps
+		if got := authors(alice, ""); len(got) != 0 {
+			t.Errorf("chirps for alice by %v, want none", got)
+		}
+		if got := authors(alice, "?author_id="+bob.ID.String()); len(got) != 0 {
+			t.Errorf("chirps of bob for alice by %v, want none", got)
+		}
+		if got := authors(bob, ""); len(got) != 2 {
+			t.Errorf("chirps for bob by %v, want both", got)
+		}
+		resp = api.do(t, "GET", "/api/chirps", "", nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[[]Chirp](t, resp); len(got) != 2 {
+			t.Errorf("chirps without login = %d, want 2", len(got))
+		}
+
+		// a blocked user cannot message, a muted one can
+		resp = api.do(t, "POST", "/api/conversations", bob.bearer(), map[string]any{"user_ids": []uuid.UUID{alice.ID}})
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "POST", "/api/conversations", alice.bearer(), map[string]any{"user_ids": []uuid.UUID{bob.ID, carol.ID}})
+		expectStatus(t, resp, http.StatusCreated)
+		path := "/api/conversations/" + decode[Conversation](t, resp).ID.String() + "/messages"
+		resp = api.do(t, "POST", path, bob.bearer(), map[string]string{"body": "hi"})
+		expectProblem(t, resp, http.StatusForbidden, CodeForbidden)
+		resp = api.do(t, "POST", path, carol.bearer(), map[string]string{"body": "hi"})
+		expectStatus(t, resp, http.StatusCreated)
+
+		// and causes no notifications
+		api.cfg.notify(context.Background(), alice.ID, notificationMention, bob.ID, uuid.Nil)
+		api.cfg.notify(context.Background(), alice.ID, notificationMention, carol.ID, uuid.Nil)
+		resp = api.do(t, "GET", "/api/notifications", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusOK)
+		if got := decode[notificationsResponse](t, resp).Notifications; len(got) != 1 || *got[0].ActorID != carol.ID {
+			t.Errorf("notifications = %+v, want only the one of carol", got)
+		}
+
+		// subscriptions follow blocks while connected
+		conn := api.dialWebsocket(t, alice.token)
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "bob", "channel": "author", "author_id": bob.ID.String()})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "bob")
+		wsSend(t, conn, map[string]string{"type": "subscribe", "id": "me", "channel": "notifications"})
+		expectWsMessage(t, wsReceive(t, conn), "subscribed", "me")
+		api.chirp(t, bob, "hidden")
+		resp = api.do(t, "DELETE", "/api/users/"+bob.ID.String()+"/block", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		if got := wsReceive(t, conn); got.Type != "event" || got.Event != events.UserUnblocked {
+			t.Fatalf("received %+v, want %s", got, events.UserUnblocked)
+		}
+		shown := api.chirp(t, bob, "shown")
+		if got := wsReceive(t, conn); got.Event != events.ChirpCreated || !strings.Contains(string(got.Data), shown.ID.String()) {
+			t.Errorf("received %+v, want the chirp of bob", got)
+		}
+
+		resp = api.do(t, "DELETE", "/api/users/"+carol.ID.String()+"/mute", alice.bearer(), nil)
+		expectStatus(t, resp, http.StatusNoContent)
+		if got := authors(alice, ""); len(got) != 4 {
+			t.Errorf("chirps for alice by %v after unblocking and unmuting, want all 4", got)
+		}
+	})
+}
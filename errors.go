@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zelieen/Chirpy/internal/store"
+)
+
+// ErrorCode is a stable, machine readable reason for an error response
+type ErrorCode string
+
+const (
+	CodeInvalidJSON        ErrorCode = "invalid_json"
+	CodeRequestTooLarge    ErrorCode = "request_too_large"
+	CodeValidationFailed   ErrorCode = "validation_failed"
+	CodeRequired           ErrorCode = "required"
+	CodeInvalidEmail       ErrorCode = "invalid_email"
+	CodeInvalidPassword    ErrorCode = "invalid_password"
+	CodeInvalidID          ErrorCode = "invalid_id"
+	CodeInvalidHandle      ErrorCode = "invalid_handle"
+	CodeHandleReserved     ErrorCode = "handle_reserved"
+	CodeChirpTooLong       ErrorCode = "chirp_too_long"
+	CodeMessageTooLong     ErrorCode = "message_too_long"
+	CodeInvalidImage       ErrorCode = "invalid_image"
+	CodeUnsupportedMedia   ErrorCode = "unsupported_media_type"
+	CodeMissingToken       ErrorCode = "missing_token"
+	CodeInvalidToken       ErrorCode = "invalid_token"
+	CodeInvalidCredentials ErrorCode = "invalid_credentials"
+	CodeInvalidAPIKey      ErrorCode = "invalid_api_key"
+	CodeForbidden          ErrorCode = "forbidden"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeHandleTaken        ErrorCode = "handle_taken"
+	CodeEmailTaken         ErrorCode = "email_taken"
+	CodeConflict           ErrorCode = "conflict"
+	CodeAlreadyVoted       ErrorCode = "already_voted"
+	CodePollClosed         ErrorCode = "poll_closed"
+	CodeTooManyPins        ErrorCode = "too_many_pins"
+	CodeInternal           ErrorCode = "internal_error"
+)
+
+// FieldError describes why a single field of a request was rejected
+type FieldError struct {
+	Field   string    `json:"field"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// APIError is an error that knows how it is shown to the client,
+// Err is the underlying cause and is only logged
+type APIError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func newAPIError(status int, code ErrorCode, msg string, err error) *APIError {
+	return &APIError{
+		Status:  status,
+		Code:    code,
+		Message: msg,
+		Err:     err,
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// translateDBError maps a database error to the error shown to the client,
+// msg is used when there is no more specific translation
+func translateDBError(err error, msg string) *APIError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return newAPIError(http.StatusNotFound, CodeNotFound, msg, err)
+	}
+	if constraintErr, ok := store.AsConstraintError(err); ok {
+		switch constraintErr.Code {
+		case store.UniqueViolation:
+			if constraintErr.Constraint == "users_email_key" {
+				return newAPIError(http.StatusConflict, CodeEmailTaken, "Email is already taken", err)
+			}
+			if constraintErr.Constraint == "users_handle_key" {
+				return newAPIError(http.StatusConflict, CodeHandleTaken, "Handle is already taken", err)
+			}
+			if constraintErr.Constraint == "collections_user_id_name_key" {
+				return newAPIError(http.StatusConflict, CodeConflict, "You already have a collection with this name", err)
+			}
+			if constraintErr.Constraint == "poll_votes_pkey" {
+				return newAPIError(http.StatusConflict, CodeAlreadyVoted, "You already voted in this poll", err)
+			}
+			return newAPIError(http.StatusConflict, CodeConflict, "Resource already exists", err)
+		case store.ForeignKeyViolation:
+			return newAPIError(http.StatusConflict, CodeConflict, "Referenced resource does not exist", err)
+		case store.CheckViolation:
+			return newAPIError(http.StatusBadRequest, CodeValidationFailed, "Invalid value", err)
+		}
+	}
+	return newAPIError(http.StatusInternalServerError, CodeInternal, msg, err)
+}
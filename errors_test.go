@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/zelieen/Chirpy/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateDBError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{
+			name:       "No rows",
+			err:        sql.ErrNoRows,
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeNotFound,
+		},
+		{
+			name:       "Duplicate email",
+			err:        &pq.Error{Code: store.UniqueViolation, Constraint: "users_email_key"},
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeEmailTaken,
+		},
+		{
+			name:       "Wrapped duplicate email",
+			err:        fmt.Errorf("creating user: %w", &pq.Error{Code: store.UniqueViolation, Constraint: "users_email_key"}),
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeEmailTaken,
+		},
+		{
+			name:       "Duplicate handle",
+			err:        &store.ConstraintError{Code: store.UniqueViolation, Constraint: "users_handle_key"},
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeHandleTaken,
+		},
+		{
+			name:       "Other unique violation",
+			err:        &pq.Error{Code: store.UniqueViolation, Constraint: "refresh_tokens_pkey"},
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeConflict,
+		},
+		{
+			name:       "Duplicate email in memory store",
+			err:        &store.ConstraintError{Code: store.UniqueViolation, Constraint: "users_email_key"},
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeEmailTaken,
+		},
+		{
+			name:       "Check violation",
+			err:        &pq.Error{Code: store.CheckViolation},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeValidationFailed,
+		},
+		{
+			name:       "Unknown error",
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translateDBError(tt.err, "message")
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+				t.Errorf("translateDBError() = %d %s, want %d %s", got.Status, got.Code, tt.wantStatus, tt.wantCode)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("translateDBError() does not wrap the original error")
+			}
+		})
+	}
+}
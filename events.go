@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// sessionRevoked is the data of a session.revoked event
+type sessionRevoked struct {
+	UserID    uuid.UUID `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	// All is set when every session of the user was revoked, their WebSocket connections are closed then
+	All bool `json:"all"`
+}
+
+// userRelation is the data of the block, mute and follow events, UserID is the user who was blocked, muted or followed
+type userRelation struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// conversationRead is the data of a conversation.read event, sent when a member read all messages
+type conversationRead struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	LastReadAt     time.Time `json:"last_read_at"`
+}
+
+// publish sends an event about the user to all subscribers and creates the notifications
+// for it, a failure is only logged because the change the event is about already happened
+func (cfg *apiConfig) publish(ctx context.Context, typ events.Type, userID uuid.UUID, data any) {
+	e, err := events.NewEvent(typ, userID, data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = cfg.events.Publish(ctx, e)
+	if err != nil {
+		log.Println(err)
+	}
+	cfg.createNotifications(ctx, e)
+}
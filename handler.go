@@ -27,7 +27,7 @@ func (cfg *apiConfig) metricHandler(w http.ResponseWriter, request *http.Request
 
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
-		respondWithError(w, http.StatusForbidden, "Not allowed", errors.New("action is forbidden outside the development platform"))
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "Not allowed", errors.New("action is forbidden outside the development platform"))
 		return
 	}
 
@@ -35,7 +35,7 @@ func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 	cfg.fileserverHits.Store(0)
 	err := cfg.db.DeleteAllUsers(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting all users", err)
+		respondWithDBError(w, err, "Error deleting all users")
 		return
 	}
 
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// hiddenUsers returns the users the user blocked or muted, their chirps are left out of what the user reads
+func (cfg *apiConfig) hiddenUsers(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids, err := cfg.db.GetHiddenUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+// blockedByAny reports whether one of the users blocked the sender, who then cannot message them
+func (cfg *apiConfig) blockedByAny(ctx context.Context, userIDs []uuid.UUID, senderID uuid.UUID) (bool, error) {
+	for _, id := range userIDs {
+		if id == senderID {
+			continue
+		}
+		blocked, err := cfg.db.HasBlocked(ctx, database.HasBlockedParams{UserID: id, BlockedID: senderID})
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+	return false, nil
+}
+
+func (cfg *apiConfig) blockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserBlocked, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		err := cfg.db.BlockUser(ctx, database.BlockUserParams{UserID: userID, BlockedID: targetID})
+		if err != nil {
+			return err
+		}
+		// a blocked user no longer follows the user, so they lose their followers-only chirps
+		n, err := cfg.db.UnfollowUser(ctx, database.UnfollowUserParams{UserID: targetID, FollowedID: userID})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			cfg.publish(ctx, events.UserUnfollowed, targetID, userRelation{UserID: userID})
+		}
+		return nil
+	})
+}
+
+func (cfg *apiConfig) unblockHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserUnblocked, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		return cfg.db.UnblockUser(ctx, database.UnblockUserParams{UserID: userID, BlockedID: targetID})
+	})
+}
+
+func (cfg *apiConfig) muteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserMuted, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		return cfg.db.MuteUser(ctx, database.MuteUserParams{UserID: userID, MutedID: targetID})
+	})
+}
+
+func (cfg *apiConfig) unmuteHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserUnmuted, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		return cfg.db.UnmuteUser(ctx, database.UnmuteUserParams{UserID: userID, MutedID: targetID})
+	})
+}
+
+// changeRelation applies change to the logged in user and the user in the path,
+// doing it twice is fine, so clients can simply retry. An *APIError of change is sent as it is.
+func (cfg *apiConfig) changeRelation(w http.ResponseWriter, r *http.Request, typ events.Type, change func(ctx context.Context, userID, targetID uuid.UUID) error) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid user id", err)
+		return
+	}
+	if targetID == principal.UserID {
+		respondWithError(w, http.StatusBadRequest, CodeValidationFailed, "You cannot follow, block or mute yourself", nil)
+		return
+	}
+	_, err = cfg.db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		respondWithDBError(w, err, "User not found")
+		return
+	}
+
+	err = change(r.Context(), principal.UserID, targetID)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		respondWithAPIError(w, err)
+		return
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error saving the relation")
+		return
+	}
+	// the other sessions of the user update their timelines
+	cfg.publish(r.Context(), typ, principal.UserID, userRelation{UserID: targetID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// Bookmark is a chirp the user saved for later, only they see their bookmarks
+type Bookmark struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// CollectionID is null if the bookmark is in no collection
+	CollectionID *uuid.UUID `json:"collection_id"`
+	Chirp        Chirp      `json:"chirp"`
+}
+
+// Collection groups bookmarks under a name, it is private to its owner
+type Collection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type bookmarksResponse struct {
+	Bookmarks []Bookmark `json:"bookmarks"`
+	// NextCursor gets the next page, it is left out on the last page
+	NextCursor *uuid.UUID `json:"next_cursor,omitempty"`
+}
+
+func makeCollection(c database.Collection) Collection {
+	return Collection{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Name:      c.Name,
+	}
+}
+
+func makeBookmark(b database.Bookmark, chirp Chirp) Bookmark {
+	bookmark := Bookmark{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		Chirp:     chirp,
+	}
+	if b.CollectionID.Valid {
+		bookmark.CollectionID = &b.CollectionID.UUID
+	}
+	return bookmark
+}
+
+// bookmarkHandler saves a chirp for the logged in user, saving it again moves it to another collection
+func (cfg *apiConfig) bookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		// one of the user's collections, none if null
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// the body is optional, without it the bookmark is in no collection
+	params := parameters{}
+	err = decodeJSON(w, r, &params, nil)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+	collectionID := uuid.NullUUID{}
+	if params.CollectionID != nil {
+		// collections of other users do not exist for the user
+		_, err := cfg.db.GetCollectionByID(r.Context(), database.GetCollectionByIDParams{
+			ID:     *params.CollectionID,
+			UserID: principal.UserID,
+		})
+		if err != nil {
+			respondWithDBError(w, err, "Collection not found")
+			return
+		}
+		collectionID = uuid.NullUUID{UUID: *params.CollectionID, Valid: true}
+	}
+
+	bookmark, err := cfg.db.SaveBookmark(r.Context(), database.SaveBookmarkParams{
+		UserID:       principal.UserID,
+		ChirpID:      chirpID,
+		CollectionID: collectionID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error saving the bookmark")
+		return
+	}
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, makeBookmark(bookmark, response))
+}
+
+func (cfg *apiConfig) bookmarkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	n, err := cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  principal.UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error deleting the bookmark")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Bookmark not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bookmarkListHandler lists the bookmarks of the logged in user, the last saved first,
+// only those of one collection if collection_id is given
+func (cfg *apiConfig) bookmarkListHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	v := &validation{}
+	limit := v.pageLimit("limit", r.URL.Query().Get("limit"))
+	cursor := v.cursor("cursor", r.URL.Query().Get("cursor"))
+	collectionID := uuid.NullUUID{}
+	if value := r.URL.Query().Get("collection_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			v.add("collection_id", CodeInvalidID, "must be the id of one of your collections")
+		}
+		collectionID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if err := v.err(); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+	if collectionID.Valid {
+		_, err := cfg.db.GetCollectionByID(r.Context(), database.GetCollectionByIDParams{
+			ID:     collectionID.UUID,
+			UserID: principal.UserID,
+		})
+		if err != nil {
+			respondWithDBError(w, err, "Collection not found")
+			return
+		}
+	}
+
+	// get one more to know if there is a next page
+	var bookmarks []database.Bookmark
+	var err error
+	if cursor == uuid.Nil {
+		bookmarks, err = cfg.db.GetBookmarks(r.Context(), database.GetBookmarksParams{
+			UserID:       principal.UserID,
+			CollectionID: collectionID,
+			MaxCount:     limit + 1,
+		})
+	} else {
+		bookmarks, err = cfg.db.GetBookmarksBefore(r.Context(), database.GetBookmarksBeforeParams{
+			UserID:       principal.UserID,
+			CollectionID: collectionID,
+			ID:           cursor,
+			MaxCount:     limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error getting bookmarks")
+		return
+	}
+
+	following, err := cfg.followedUsers(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting followed users")
+		return
+	}
+
+	resp := bookmarksResponse{Bookmarks: []Bookmark{}}
+	if len(bookmarks) > int(limit) {
+		bookmarks = bookmarks[:limit]
+		resp.NextCursor = &bookmarks[limit-1].ID
+	}
+	ids := []uuid.UUID{}
+	for _, b := range bookmarks {
+		ids = append(ids, b.ChirpID)
+	}
+	list, err := cfg.db.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting the bookmarked Chirps")
+		return
+	}
+	byID := map[uuid.UUID]database.Chirp{}
+	for _, c := range list {
+		byID[c.ID] = c
+	}
+
+	shown := []database.Bookmark{}
+	chirpList := []database.Chirp{}
+	for _, b := range bookmarks {
+		chirp, ok := byID[b.ChirpID]
+		// bookmarks of followers-only chirps stay after unfollowing the author, but are not shown
+		if !ok || !visibleTo(chirp, principal.UserID, following) {
+			continue
+		}
+		shown = append(shown, b)
+		chirpList = append(chirpList, chirp)
+	}
+	chirps, err := cfg.makeChirps(r.Context(), chirpList, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+	for i, b := range shown {
+		resp.Bookmarks = append(resp.Bookmarks, makeBookmark(b, chirps[i]))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+type collectionParameters struct {
+	Name string `json:"name"`
+}
+
+func (cfg *apiConfig) collectionCreateHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	params := collectionParameters{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.collectionName("name", params.Name)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	collection, err := cfg.db.CreateCollection(r.Context(), database.CreateCollectionParams{
+		UserID: principal.UserID,
+		Name:   params.Name,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error creating the collection")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, makeCollection(collection))
+}
+
+// collectionListHandler lists the collections of the logged in user by name
+func (cfg *apiConfig) collectionListHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	list, err := cfg.db.GetCollectionsForUser(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting collections")
+		return
+	}
+	collections := []Collection{}
+	for _, c := range list {
+		collections = append(collections, makeCollection(c))
+	}
+
+	respondWithJSON(w, http.StatusOK, collections)
+}
+
+// collectionUpdateHandler renames a collection of the logged in user
+func (cfg *apiConfig) collectionUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid collection id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	params := collectionParameters{}
+	err = decodeJSON(w, r, &params, func(v *validation) {
+		v.collectionName("name", params.Name)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	collection, err := cfg.db.UpdateCollection(r.Context(), database.UpdateCollectionParams{
+		ID:     collectionID,
+		UserID: principal.UserID,
+		Name:   params.Name,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Collection not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, makeCollection(collection))
+}
+
+// collectionDeleteHandler deletes a collection of the logged in user, its bookmarks are kept outside of any collection
+func (cfg *apiConfig) collectionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid collection id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	n, err := cfg.db.DeleteCollection(r.Context(), database.DeleteCollectionParams{
+		ID:     collectionID,
+		UserID: principal.UserID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error deleting the collection")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Collection not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"slices"
 	"strings"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/zelieen/Chirpy/internal/auth"
 	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
 
 	"github.com/google/uuid"
 )
@@ -20,54 +23,153 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	Media     []Media   `json:"media"`
+	// PublishAt is only set while the chirp is scheduled
+	PublishAt *time.Time `json:"publish_at"`
+	Poll      *Poll      `json:"poll"`
+	// Pinned chirps are listed first among the chirps of their author
+	Pinned bool `json:"pinned"`
+	// ContentWarning is shown instead of the body until the reader expands the chirp
+	ContentWarning *string `json:"content_warning"`
+	Sensitive      bool    `json:"sensitive"`
+	// Collapsed is true if clients should only show the warning, as the viewer's sensitive_content says
+	Collapsed bool `json:"collapsed"`
+	// Visibility is public, unlisted or followers
+	Visibility string `json:"visibility"`
 }
 
-func getProfanityList() []string {
-	return []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
+// makeChirp adds what is stored next to the chirp, like its images and poll,
+// the poll results depend on whether the viewer voted, uuid.Nil is nobody
+func (cfg *apiConfig) makeChirp(ctx context.Context, c database.Chirp, viewerID uuid.UUID) (Chirp, error) {
+	chirps, err := cfg.makeChirps(ctx, []database.Chirp{c}, viewerID)
+	if err != nil {
+		return Chirp{}, err
 	}
+	return chirps[0], nil
 }
 
-func (cfg *apiConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
+// makeChirps is makeChirp for a list, what is attached to the chirps is read for all of them at once
+func (cfg *apiConfig) makeChirps(ctx context.Context, list []database.Chirp, viewerID uuid.UUID) ([]Chirp, error) {
+	ids := make([]uuid.UUID, 0, len(list))
+	for _, c := range list {
+		ids = append(ids, c.ID)
 	}
-
-	// Decode Request
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	attached, err := cfg.db.GetMediaForChirps(ctx, ids)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
-		return
+		return nil, err
 	}
-
-	// check log in status
-	token, err := auth.GetBearerToken(r.Header)
+	media := map[uuid.UUID][]Media{}
+	for _, m := range attached {
+		media[m.ChirpID.UUID] = append(media[m.ChirpID.UUID], makeMedia(m))
+	}
+	polls, err := cfg.makePolls(ctx, ids, viewerID)
 	if err != nil {
-		log.Printf("Token bearer: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error getting the token bearer", err)
-		return
+		return nil, err
 	}
-	tokenUser, err := auth.ValidateJWT(token, cfg.secret)
+	pins, err := cfg.db.GetPinsForChirps(ctx, ids)
 	if err != nil {
-		log.Printf("Invalid token: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error validating the token", err)
-		return
+		return nil, err
+	}
+	pinned := map[uuid.UUID]bool{}
+	for _, p := range pins {
+		pinned[p.ChirpID] = true
+	}
+	// the viewer is only looked up if a chirp has a warning
+	setting := ""
+	if slices.ContainsFunc(list, hasWarning) {
+		setting, err = cfg.sensitiveContentSetting(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// check Chirp length
-	if len(params.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
-		return
+	chirps := make([]Chirp, 0, len(list))
+	for _, c := range list {
+		chirp := bareChirp(c)
+		chirp.Media = append(chirp.Media, media[c.ID]...)
+		chirp.Poll = polls[c.ID]
+		chirp.Pinned = pinned[c.ID]
+		chirp.Collapsed = collapsedFor(c, setting)
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+// bareChirp is the chirp as it is stored, without its media, poll or pin, collapsed like for a logged out viewer
+func bareChirp(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Media:      []Media{},
+		Sensitive:  c.Sensitive,
+		Collapsed:  hasWarning(c),
+		Visibility: c.Visibility,
+	}
+	if c.PublishAt.Valid {
+		chirp.PublishAt = &c.PublishAt.Time
+	}
+	if c.ContentWarning.Valid {
+		chirp.ContentWarning = &c.ContentWarning.String
+	}
+	return chirp
+}
+
+// pollStart is when the poll of a new chirp opens, when the chirp is published
+func pollStart(publishAt *time.Time) time.Time {
+	if publishAt != nil {
+		return *publishAt
+	}
+	return time.Now()
+}
+
+// visibleTo reports whether the user may see the chirp: scheduled chirps are only shown to their author,
+// followers-only chirps to the author and their followers, following holds the users the user follows
+func visibleTo(c database.Chirp, userID uuid.UUID, following map[uuid.UUID]bool) bool {
+	if c.UserID == userID {
+		return true
+	}
+	if c.PublishAt.Valid {
+		return false
+	}
+	return c.Visibility != visibilityFollowers || following[c.UserID]
+}
+
+// listedFor reports whether the chirp is listed for the user, unlisted chirps can only be opened by id
+// except by their author
+func listedFor(c database.Chirp, userID uuid.UUID, following map[uuid.UUID]bool) bool {
+	if c.Visibility == visibilityUnlisted && c.UserID != userID {
+		return false
+	}
+	return visibleTo(c, userID, following)
+}
+
+// canSee is visibleTo for a single chirp, it only looks up whether the user follows the author if it matters
+func (cfg *apiConfig) canSee(ctx context.Context, c database.Chirp, userID uuid.UUID) (bool, error) {
+	following := map[uuid.UUID]bool{}
+	if c.Visibility == visibilityFollowers && userID != uuid.Nil && userID != c.UserID {
+		follows, err := cfg.db.IsFollowing(ctx, database.IsFollowingParams{UserID: userID, FollowedID: c.UserID})
+		if err != nil {
+			return false, err
+		}
+		following[c.UserID] = follows
+	}
+	return visibleTo(c, userID, following), nil
+}
+
+func getProfanityList() []string {
+	return []string{
+		"kerfuffle",
+		"sharbert",
+		"fornax",
 	}
+}
 
-	// replace "profanity"
-	words := strings.Split(params.Body, " ")
+// moderate replaces "profanity", everything users post for others to read goes through it
+func moderate(body string) string {
+	words := strings.Split(body, " ")
 	for i, w := range words {
 		for _, p := range getProfanityList() {
 			if strings.ToLower(w) == p {
@@ -76,36 +178,145 @@ func (cfg *apiConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	cleaned := strings.Join(words, " ")
+	return strings.Join(words, " ")
+}
 
-	// create Chirp
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleaned,
-		UserID: tokenUser,
+func (cfg *apiConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+		// uploads of the author from POST /api/media
+		MediaIDs []uuid.UUID `json:"media_ids"`
+		// publishes the chirp later, see runScheduler
+		PublishAt *time.Time      `json:"publish_at"`
+		Poll      *pollParameters `json:"poll"`
+		// shown instead of the body until the reader expands the chirp
+		ContentWarning *string `json:"content_warning"`
+		// the images may upset or are not safe for work
+		Sensitive bool `json:"sensitive"`
+		// public if not given
+		Visibility string `json:"visibility"`
+		// ignored, the author is always the owner of the access token
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request
+	params := parameters{Visibility: visibilityPublic}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.chirpBody("body", params.Body)
+		v.mediaIDs("media_ids", params.MediaIDs)
+		v.publishAt("publish_at", params.PublishAt)
+		v.poll("poll", params.Poll, pollStart(params.PublishAt))
+		v.contentWarning("content_warning", params.ContentWarning)
+		v.visibility("visibility", params.Visibility)
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating Chirp", err)
+		respondWithAPIError(w, err)
 		return
 	}
+	if params.Poll != nil {
+		maxDuration, err := cfg.maxPollDuration(r.Context(), principal.UserID)
+		if err != nil {
+			respondWithDBError(w, err, "User not found")
+			return
+		}
+		if params.Poll.ClosesAt.After(pollStart(params.PublishAt).Add(maxDuration)) {
+			v := &validation{}
+			msg := fmt.Sprintf("must be at most %d days after publishing, %d with Chirpy Red", maxPollDays, maxRedPollDays)
+			v.add("poll.closes_at", CodeValidationFailed, msg)
+			respondWithAPIError(w, v.err())
+			return
+		}
+	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp(chirp))
+	// only the author's own uploads that are not shown yet can be attached
+	for _, id := range params.MediaIDs {
+		md, err := cfg.db.GetMediaByID(r.Context(), id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithDBError(w, err, "Error getting the media")
+			return
+		}
+		if err != nil || md.UserID != principal.UserID || md.ChirpID.Valid {
+			v := &validation{}
+			v.add("media_ids", CodeValidationFailed, "must be your own uploads that are not attached to a chirp yet")
+			respondWithAPIError(w, v.err())
+			return
+		}
+	}
+
+	// create Chirp
+	publishAt := sql.NullTime{}
+	if params.PublishAt != nil {
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+	// the media and the poll are stored with the chirp, there is no chirp with half of them
+	create := database.PostChirpParams{
+		MediaIds:       params.MediaIDs,
+		UserID:         principal.UserID,
+		Body:           moderate(params.Body),
+		PublishAt:      publishAt,
+		ContentWarning: nullWarning(params.ContentWarning),
+		Sensitive:      params.Sensitive,
+		Visibility:     params.Visibility,
+	}
+	if params.Poll != nil {
+		create = chirpWithPoll(create, *params.Poll)
+	}
+	chirp, err := cfg.db.PostChirp(r.Context(), create)
+	if errors.Is(err, sql.ErrNoRows) {
+		// another chirp took one of the uploads since they were checked
+		respondWithError(w, http.StatusConflict, CodeConflict, "Media was attached to another chirp", nil)
+		return
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error creating Chirp")
+		return
+	}
+
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+	// scheduled chirps are announced once the scheduler publishes them
+	if !chirp.PublishAt.Valid {
+		cfg.publish(r.Context(), events.ChirpCreated, chirp.UserID, response)
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
 }
 
 func (cfg *apiConfig) chirpGetHandler(w http.ResponseWriter, r *http.Request) {
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Not a valid chirp id", err)
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
 		return
 	}
 
 	// get Chirp by ID
 	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp(chirp))
+	respondWithJSON(w, http.StatusOK, response)
 }
 
 func (cfg *apiConfig) chirpListHandler(w http.ResponseWriter, r *http.Request) {
@@ -115,32 +326,75 @@ func (cfg *apiConfig) chirpListHandler(w http.ResponseWriter, r *http.Request) {
 	if author == "" { // get all chirps
 		completeList, err := cfg.db.GetChirpList(r.Context())
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error getting Chirp list", err)
+			respondWithDBError(w, err, "Error getting Chirp list")
 			return
 		}
 		chirpList = append(chirpList, completeList...)
 	} else { // filter chirps from author
 		author_id, err := uuid.Parse(author)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "Error invalid user id", err)
+			respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Error invalid user id", err)
 			return
 		}
 		authorList, err := cfg.db.GetChirpsByAuthor(r.Context(), author_id)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error getting Chirp list from user", err)
+			respondWithDBError(w, err, "Error getting Chirp list from user")
 			return
 		}
 		chirpList = append(chirpList, authorList...)
 	}
 
+	// leave out scheduled and unlisted chirps of others, and followers-only chirps of users the user does not follow
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	following, err := cfg.followedUsers(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting followed users")
+		return
+	}
+	chirpList = slices.DeleteFunc(chirpList, func(c database.Chirp) bool {
+		return !listedFor(c, principal.UserID, following)
+	})
+
+	// leave out the authors the user blocked or muted
+	if ok {
+		hidden, err := cfg.hiddenUsers(r.Context(), principal.UserID)
+		if err != nil {
+			respondWithDBError(w, err, "Error getting blocked users")
+			return
+		}
+		chirpList = slices.DeleteFunc(chirpList, func(c database.Chirp) bool {
+			return hidden[c.UserID]
+		})
+
+		// and the chirps with a warning if the user wants them hidden, except their own
+		setting, err := cfg.sensitiveContentSetting(r.Context(), principal.UserID)
+		if err != nil {
+			respondWithDBError(w, err, "User not found")
+			return
+		}
+		if setting == "hide" {
+			chirpList = slices.DeleteFunc(chirpList, func(c database.Chirp) bool {
+				return hasWarning(c) && c.UserID != principal.UserID
+			})
+		}
+	}
+
 	// fill the response list
 	if r.URL.Query().Get("sort") == "desc" {
 		slices.Reverse(chirpList)
 	}
+	if author != "" {
+		err := cfg.pinnedFirst(r.Context(), chirpList)
+		if err != nil {
+			respondWithDBError(w, err, "Error getting pinned Chirps")
+			return
+		}
+	}
 
-	chirps := []Chirp{}
-	for _, c := range chirpList {
-		chirps = append(chirps, Chirp(c))
+	chirps, err := cfg.makeChirps(r.Context(), chirpList, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, chirps)
@@ -149,43 +403,160 @@ func (cfg *apiConfig) chirpListHandler(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) chirpDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Not a valid chirp id", err)
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
 		return
 	}
 
-	// check log in status
-	token, err := auth.GetBearerToken(r.Header)
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// check chirp exists
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
-		log.Printf("Token bearer: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error getting the token bearer", err)
+		respondWithDBError(w, err, "Chirp not found")
 		return
 	}
-	tokenUser, err := auth.ValidateJWT(token, cfg.secret)
+	// chirps the user cannot see do not exist for them
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
 	if err != nil {
-		log.Printf("Invalid token: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error validating the token", err)
+		respondWithDBError(w, err, "Error checking followers")
 		return
 	}
-
-	// check chirp exists
-	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
 		return
 	}
 
 	// check authorship
-	if chirp.UserID != tokenUser {
-		w.WriteHeader(http.StatusForbidden)
+	if chirp.UserID != principal.UserID {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "Only the author can delete a chirp", nil)
 		return
 	}
 
 	// delete Chirp
-	err = cfg.db.DeleteChirpByID(r.Context(), chirpID)
+	err = cfg.deleteChirp(r.Context(), chirp)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting Chirp", err)
+		respondWithDBError(w, err, "Error deleting Chirp")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// deleteChirp deletes the chirp with its images and tells the subscribers, scheduled chirps were never announced
+func (cfg *apiConfig) deleteChirp(ctx context.Context, chirp database.Chirp) error {
+	// the media rows go with the chirp, their files are deleted after it
+	response, err := cfg.makeChirp(ctx, chirp, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	err = cfg.db.DeleteChirpByID(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	cfg.deleteChirpBlobs(ctx, response)
+	if !chirp.PublishAt.Valid {
+		cfg.publish(ctx, events.ChirpDeleted, chirp.UserID, response)
+	}
+	return nil
+}
+
+// scheduledChirpsHandler lists the chirps of the logged in user that are not published yet, the next one first
+func (cfg *apiConfig) scheduledChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	scheduled, err := cfg.db.GetScheduledChirps(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting scheduled Chirps")
+		return
+	}
+	chirps, err := cfg.makeChirps(r.Context(), scheduled, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// scheduledChirpCancelHandler deletes a scheduled chirp, unlike chirpDeleteHandler it fails once the chirp is published
+func (cfg *apiConfig) scheduledChirpCancelHandler(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.ownScheduledChirp(w, r, r.URL.Query().Get("chirp_id"))
+	if !ok {
+		return
+	}
+	response, err := cfg.makeChirp(r.Context(), chirp, uuid.Nil)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+
+	// the scheduler may publish the chirp right before this, then nothing is deleted
+	n, err := cfg.db.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error canceling Chirp")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, CodeConflict, "Chirp is already published, delete it instead", nil)
+		return
+	}
+	cfg.deleteChirpBlobs(r.Context(), response)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scheduledChirpPublishHandler publishes a scheduled chirp right away and announces it like the scheduler does
+func (cfg *apiConfig) scheduledChirpPublishHandler(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.ownScheduledChirp(w, r, r.PathValue("chirpID"))
+	if !ok {
+		return
+	}
+
+	// the scheduler may publish the chirp right before this, then it is announced only once
+	chirp, err := cfg.db.PublishScheduledChirp(r.Context(), database.PublishScheduledChirpParams{
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, CodeConflict, "Chirp is already published", err)
+		return
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error publishing Chirp")
+		return
+	}
+
+	response, err := cfg.makeChirp(r.Context(), chirp, chirp.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+	cfg.publish(r.Context(), events.ChirpCreated, chirp.UserID, response)
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ownScheduledChirp gets the chirp with the given id for the scheduled chirp actions, chirps of others do not
+// exist for the user. If there is none it responds and returns false
+func (cfg *apiConfig) ownScheduledChirp(w http.ResponseWriter, r *http.Request, id string) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return database.Chirp{}, false
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return database.Chirp{}, false
+	}
+	if chirp.UserID != principal.UserID {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// Draft is a chirp that is still being written, only its author sees it
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+func makeDraft(d database.Draft) Draft {
+	return Draft{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		Body:      d.Body,
+	}
+}
+
+type draftParameters struct {
+	// drafts may be empty or too long for a chirp, that is checked when they are published
+	Body string `json:"body"`
+}
+
+func (cfg *apiConfig) draftCreateHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	params := draftParameters{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.maxLength("body", params.Body, maxDraftLength)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	draft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: principal.UserID,
+		Body:   params.Body,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error saving the draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, makeDraft(draft))
+}
+
+// draftListHandler lists the drafts of the logged in user, the last edited first
+func (cfg *apiConfig) draftListHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	list, err := cfg.db.GetDraftsForUser(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting drafts")
+		return
+	}
+	drafts := []Draft{}
+	for _, d := range list {
+		drafts = append(drafts, makeDraft(d))
+	}
+
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) draftUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid draft id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	params := draftParameters{}
+	err = decodeJSON(w, r, &params, func(v *validation) {
+		v.maxLength("body", params.Body, maxDraftLength)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// drafts of other users do not exist for the user
+	draft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draftID,
+		UserID: principal.UserID,
+		Body:   params.Body,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Draft not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, makeDraft(draft))
+}
+
+func (cfg *apiConfig) draftDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid draft id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	n, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: principal.UserID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error deleting the draft")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Draft not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// draftPublishHandler turns a draft into a chirp, the draft is checked and cleaned like the body of a new chirp
+func (cfg *apiConfig) draftPublishHandler(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid draft id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	draft, err := cfg.db.GetDraftByID(r.Context(), database.GetDraftByIDParams{
+		ID:     draftID,
+		UserID: principal.UserID,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Draft not found")
+		return
+	}
+	v := &validation{}
+	v.chirpBody("body", draft.Body)
+	if err := v.err(); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// the chirp is created and the draft deleted at once, unless the draft changed since it was checked
+	chirp, err := cfg.db.PublishDraft(r.Context(), database.PublishDraftParams{
+		ID:        draft.ID,
+		UserID:    principal.UserID,
+		DraftBody: draft.Body,
+		Body:      moderate(draft.Body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, CodeConflict, "Draft was changed or published meanwhile, try again", err)
+		return
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error publishing the draft")
+		return
+	}
+
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+	cfg.publish(r.Context(), events.ChirpCreated, chirp.UserID, response)
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// who a chirp is shown to
+const (
+	visibilityPublic = "public"
+	// unlisted chirps can be opened by id but are left out of lists and streams
+	visibilityUnlisted = "unlisted"
+	// followers-only chirps are only shown to their author and the author's followers
+	visibilityFollowers = "followers"
+)
+
+// followedUsers returns the users the user follows, nobody for uuid.Nil
+func (cfg *apiConfig) followedUsers(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]bool, error) {
+	following := map[uuid.UUID]bool{}
+	if userID == uuid.Nil {
+		return following, nil
+	}
+	ids, err := cfg.db.GetFollowedUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		following[id] = true
+	}
+	return following, nil
+}
+
+func (cfg *apiConfig) followHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserFollowed, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		// following would show them the followers-only chirps of the user who blocked them
+		blocked, err := cfg.db.HasBlocked(ctx, database.HasBlockedParams{UserID: targetID, BlockedID: userID})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return newAPIError(http.StatusForbidden, CodeForbidden, "You cannot follow this user", nil)
+		}
+		n, err := cfg.db.FollowUser(ctx, database.FollowUserParams{UserID: userID, FollowedID: targetID})
+		if err != nil {
+			return err
+		}
+		// only a new follow is news, not a retry
+		if n > 0 {
+			cfg.notify(ctx, targetID, notificationFollow, userID, uuid.Nil)
+		}
+		return nil
+	})
+}
+
+func (cfg *apiConfig) unfollowHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.changeRelation(w, r, events.UserUnfollowed, func(ctx context.Context, userID, targetID uuid.UUID) error {
+		_, err := cfg.db.UnfollowUser(ctx, database.UnfollowUserParams{UserID: userID, FollowedID: targetID})
+		return err
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/blob"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/media"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxImageBytes  = 5 << 20
+	maxAvatarBytes = 2 << 20
+	maxImagePixels = 40_000_000
+	maxImageSide   = 2048
+	thumbnailSide  = 320
+	avatarSide     = 400
+	// mediaPath is where blobs are served, followed by their key
+	mediaPath = "/media/"
+)
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func makeMedia(m database.Medium) Media {
+	return Media{
+		ID:           m.ID,
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+		Size:         m.Size,
+		URL:          mediaPath + m.BlobKey,
+		ThumbnailURL: mediaPath + m.ThumbnailKey,
+	}
+}
+
+// readUpload returns the "file" part of a multipart/form-data request
+func readUpload(w http.ResponseWriter, r *http.Request, maxBytes int64) ([]byte, error) {
+	tooLarge := fmt.Sprintf("Image must be at most %d MB", maxBytes>>20)
+	// room for the multipart headers and boundaries
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64<<10)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, newAPIError(http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "Request must be multipart/form-data", err)
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			v := &validation{}
+			v.add("file", CodeRequired, "must contain an image")
+			return nil, v.err()
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, newAPIError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, tooLarge, err)
+		}
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, CodeValidationFailed, "Error reading the multipart form", err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if errors.As(err, &maxBytesErr) || int64(len(data)) > maxBytes {
+			return nil, newAPIError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, tooLarge, err)
+		}
+		if err != nil {
+			return nil, newAPIError(http.StatusBadRequest, CodeValidationFailed, "Error reading the upload", err)
+		}
+		return data, nil
+	}
+}
+
+// processImage is media.Process with its errors shown to the client
+func processImage(data []byte, opts media.Options) (media.Image, error) {
+	img, err := media.Process(data, opts)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		return img, newAPIError(http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "Image must be a JPEG, PNG or GIF", err)
+	case errors.Is(err, media.ErrInvalidImage):
+		return img, newAPIError(http.StatusBadRequest, CodeInvalidImage, "Image could not be read", err)
+	case errors.Is(err, media.ErrTooManyPixels):
+		msg := fmt.Sprintf("Image must be at most %d megapixels", maxImagePixels/1_000_000)
+		return img, newAPIError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, msg, err)
+	case err != nil:
+		return img, newAPIError(http.StatusInternalServerError, CodeInternal, "Error processing the image", err)
+	}
+	return img, nil
+}
+
+// deleteBlobs removes blobs that are no longer referenced, failures only leave unused files behind
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		err := cfg.blobs.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting blob %s: %s", key, err)
+		}
+	}
+}
+
+// mediaUploadHandler stores an image, it is shown once a chirp is created with its id
+func (cfg *apiConfig) mediaUploadHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	data, err := readUpload(w, r, maxImageBytes)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+	img, err := processImage(data, media.Options{
+		MaxPixels:     maxImagePixels,
+		MaxSide:       maxImageSide,
+		ThumbnailSide: thumbnailSide,
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// keys are random, so a URL always shows the same image and can be cached forever
+	name := "media/" + uuid.NewString()
+	key := name + media.Ext(img.ContentType)
+	thumbnailKey := name + "-thumb" + media.Ext(img.ThumbnailContentType)
+	err = cfg.blobs.Put(r.Context(), key, img.Data, img.ContentType)
+	if err == nil {
+		err = cfg.blobs.Put(r.Context(), thumbnailKey, img.Thumbnail, img.ThumbnailContentType)
+	}
+	if err != nil {
+		cfg.deleteBlobs(r.Context(), key)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Error storing the image", err)
+		return
+	}
+
+	md, err := cfg.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		UserID:       principal.UserID,
+		ContentType:  img.ContentType,
+		Size:         int64(len(img.Data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		BlobKey:      key,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		cfg.deleteBlobs(r.Context(), key, thumbnailKey)
+		respondWithDBError(w, err, "Error saving the upload")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, makeMedia(md))
+}
+
+// avatarHandler replaces the avatar of the logged in user with a square version of the upload
+func (cfg *apiConfig) avatarHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	data, err := readUpload(w, r, maxAvatarBytes)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+	img, err := processImage(data, media.Options{
+		MaxPixels: maxImagePixels,
+		MaxSide:   avatarSide,
+		Square:    true,
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	key := "avatars/" + uuid.NewString() + media.Ext(img.ContentType)
+	err = cfg.blobs.Put(r.Context(), key, img.Data, img.ContentType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Error storing the image", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		cfg.deleteBlobs(r.Context(), key)
+		respondWithDBError(w, err, "User not found")
+		return
+	}
+	updated, err := cfg.db.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   mediaPath + key,
+	})
+	if err != nil {
+		cfg.deleteBlobs(r.Context(), key)
+		respondWithDBError(w, err, "Error updating the avatar")
+		return
+	}
+	cfg.deleteUploadedAvatar(r.Context(), user.AvatarUrl)
+
+	respondWithJSON(w, http.StatusOK, MakeUserSafe(updated))
+}
+
+// deleteChirpBlobs removes the images of a deleted chirp
+func (cfg *apiConfig) deleteChirpBlobs(ctx context.Context, chirp Chirp) {
+	for _, m := range chirp.Media {
+		cfg.deleteBlobs(ctx, strings.TrimPrefix(m.URL, mediaPath), strings.TrimPrefix(m.ThumbnailURL, mediaPath))
+	}
+}
+
+// deleteUploadedAvatar removes the blob of an avatar URL that was replaced, if it was uploaded
+func (cfg *apiConfig) deleteUploadedAvatar(ctx context.Context, avatarURL string) {
+	if key, ok := strings.CutPrefix(avatarURL, mediaPath); ok && strings.HasPrefix(key, "avatars/") {
+		cfg.deleteBlobs(ctx, key)
+	}
+}
+
+// mediaFileHandler serves uploads from the blob store
+func (cfg *apiConfig) mediaFileHandler(w http.ResponseWriter, r *http.Request) {
+	body, info, err := cfg.blobs.Get(r.Context(), r.PathValue("key"))
+	if errors.Is(err, blob.ErrNotFound) || errors.Is(err, blob.ErrInvalidKey) {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "File not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Error reading the file", err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = io.Copy(w, body)
+	if err != nil {
+		log.Printf("Error sending %s: %s", r.URL.Path, err)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+// most members of a conversation, including the user who started it
+const maxConversationMembers = 10
+
+type Conversation struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time of the latest message
+	UpdatedAt   time.Time            `json:"updated_at"`
+	IsGroup     bool                 `json:"is_group"`
+	Members     []ConversationMember `json:"members"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+type ConversationMember struct {
+	UserID   uuid.UUID `json:"user_id"`
+	JoinedAt time.Time `json:"joined_at"`
+	// LastReadAt is when the member last read the conversation, every older message is read
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	// ReadBy lists the other members who read the message
+	ReadBy []uuid.UUID `json:"read_by"`
+}
+
+type messagesResponse struct {
+	Messages []Message `json:"messages"`
+	// NextCursor gets the next page, it is left out on the last page
+	NextCursor *uuid.UUID `json:"next_cursor,omitempty"`
+}
+
+// makeMessage fills in the read receipts from the members of the conversation
+func makeMessage(msg database.Message, members []database.ConversationMember) Message {
+	message := Message{
+		ID:             msg.ID,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: msg.ConversationID,
+		SenderID:       msg.SenderID,
+		Body:           msg.Body,
+		ReadBy:         []uuid.UUID{},
+	}
+	for _, m := range members {
+		if m.UserID != msg.SenderID && m.LastReadAt.Valid && !m.LastReadAt.Time.Before(msg.CreatedAt) {
+			message.ReadBy = append(message.ReadBy, m.UserID)
+		}
+	}
+	return message
+}
+
+// makeConversation returns the conversation as seen by the member with userID
+func (cfg *apiConfig) makeConversation(ctx context.Context, c database.Conversation, userID uuid.UUID) (Conversation, error) {
+	conversations, err := cfg.makeConversations(ctx, []database.Conversation{c}, userID)
+	if err != nil {
+		return Conversation{}, err
+	}
+	return conversations[0], nil
+}
+
+// makeConversations is makeConversation for a list, the members and unread counts are read for all of them at once
+func (cfg *apiConfig) makeConversations(ctx context.Context, list []database.Conversation, userID uuid.UUID) ([]Conversation, error) {
+	ids := make([]uuid.UUID, 0, len(list))
+	for _, c := range list {
+		ids = append(ids, c.ID)
+	}
+	all, err := cfg.db.GetMembersForConversations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	members := map[uuid.UUID][]ConversationMember{}
+	for _, m := range all {
+		member := ConversationMember{UserID: m.UserID, JoinedAt: m.JoinedAt}
+		if m.LastReadAt.Valid {
+			member.LastReadAt = &m.LastReadAt.Time
+		}
+		members[m.ConversationID] = append(members[m.ConversationID], member)
+	}
+	counts, err := cfg.db.CountUnreadMessagesForConversations(ctx, database.CountUnreadMessagesForConversationsParams{
+		ConversationIds: ids,
+		UserID:          userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	unread := map[uuid.UUID]int64{}
+	for _, c := range counts {
+		unread[c.ConversationID] = c.Unread
+	}
+
+	conversations := make([]Conversation, 0, len(list))
+	for _, c := range list {
+		conversations = append(conversations, Conversation{
+			ID:          c.ID,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			IsGroup:     !c.DirectKey.Valid,
+			Members:     append([]ConversationMember{}, members[c.ID]...),
+			UnreadCount: unread[c.ID],
+		})
+	}
+	return conversations, nil
+}
+
+// directKey names the one-to-one conversation of two users, it is the same in both directions
+func directKey(a, b uuid.UUID) sql.NullString {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return sql.NullString{String: a.String() + ":" + b.String(), Valid: true}
+}
+
+// memberConversation returns the conversation in the path if the user is one of its members,
+// for everyone else it does not exist
+func (cfg *apiConfig) memberConversation(r *http.Request, userID uuid.UUID) (database.Conversation, []database.ConversationMember, error) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		return database.Conversation{}, nil, newAPIError(http.StatusBadRequest, CodeInvalidID, "Not a valid conversation id", err)
+	}
+	members, err := cfg.db.GetConversationMembers(r.Context(), conversationID)
+	if err != nil {
+		return database.Conversation{}, nil, translateDBError(err, "Error getting conversation members")
+	}
+	if !slices.ContainsFunc(members, func(m database.ConversationMember) bool { return m.UserID == userID }) {
+		return database.Conversation{}, nil, newAPIError(http.StatusNotFound, CodeNotFound, "Conversation not found", nil)
+	}
+	c, err := cfg.db.GetConversationByID(r.Context(), conversationID)
+	if err != nil {
+		return database.Conversation{}, nil, translateDBError(err, "Conversation not found")
+	}
+	return c, members, nil
+}
+
+func (cfg *apiConfig) conversationCreateHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		// UserIDs are the other members, the user starting the conversation is always one
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request
+	params := parameters{}
+	var others []uuid.UUID
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		for _, id := range params.UserIDs {
+			if id != principal.UserID && !slices.Contains(others, id) {
+				others = append(others, id)
+			}
+		}
+		if len(others) == 0 {
+			v.add("user_ids", CodeRequired, "must contain another user")
+		}
+		if len(others) >= maxConversationMembers {
+			v.add("user_ids", CodeValidationFailed, fmt.Sprintf("must not have more than %d users", maxConversationMembers-1))
+		}
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	for _, id := range others {
+		_, err = cfg.db.GetUserByID(r.Context(), id)
+		if err != nil {
+			respondWithDBError(w, err, "User not found")
+			return
+		}
+	}
+	blocked, err := cfg.blockedByAny(r.Context(), others, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking blocked users")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "You cannot message this user", nil)
+		return
+	}
+
+	// two users only ever have one conversation of their own, starting it again returns it
+	key := sql.NullString{}
+	if len(others) == 1 {
+		key = directKey(principal.UserID, others[0])
+	}
+
+	// create Conversation with its members
+	started, err := cfg.db.StartConversation(r.Context(), database.StartConversationParams{
+		DirectKey: key,
+		MemberIds: append([]uuid.UUID{principal.UserID}, others...),
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error creating conversation")
+		return
+	}
+	c := database.Conversation{
+		ID:        started.ID,
+		CreatedAt: started.CreatedAt,
+		UpdatedAt: started.UpdatedAt,
+		DirectKey: started.DirectKey,
+	}
+	status := http.StatusOK
+	if started.Created {
+		status = http.StatusCreated
+	}
+
+	cfg.respondWithConversation(w, r, status, c, principal.UserID)
+}
+
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, r *http.Request, code int, c database.Conversation, userID uuid.UUID) {
+	conversation, err := cfg.makeConversation(r.Context(), c, userID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting conversation")
+		return
+	}
+	respondWithJSON(w, code, conversation)
+}
+
+func (cfg *apiConfig) conversationListHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	conversations, err := cfg.db.GetConversationsForUser(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting conversations")
+		return
+	}
+
+	resp, err := cfg.makeConversations(r.Context(), conversations, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting conversations")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) conversationGetHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	c, _, err := cfg.memberConversation(r, principal.UserID)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	cfg.respondWithConversation(w, r, http.StatusOK, c, principal.UserID)
+}
+
+func (cfg *apiConfig) conversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	c, members, err := cfg.memberConversation(r, principal.UserID)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// mark every message as read
+	params := database.MarkConversationReadParams{
+		ConversationID: c.ID,
+		UserID:         principal.UserID,
+	}
+	err = cfg.db.MarkConversationRead(r.Context(), params)
+	if err != nil {
+		respondWithDBError(w, err, "Error marking conversation as read")
+		return
+	}
+	member, err := cfg.db.GetConversationMember(r.Context(), database.GetConversationMemberParams(params))
+	if err != nil {
+		respondWithDBError(w, err, "Error getting conversation member")
+		return
+	}
+	// tell the other members, so they can show the read receipts
+	cfg.publishToMembers(r.Context(), events.ConversationRead, members, principal.UserID, conversationRead{
+		ConversationID: c.ID,
+		UserID:         principal.UserID,
+		LastReadAt:     member.LastReadAt.Time,
+	})
+
+	cfg.respondWithConversation(w, r, http.StatusOK, c, principal.UserID)
+}
+
+// publishToMembers sends the event to every member of the conversation except the user
+func (cfg *apiConfig) publishToMembers(ctx context.Context, typ events.Type, members []database.ConversationMember, except uuid.UUID, data any) {
+	for _, m := range members {
+		if m.UserID != except {
+			cfg.publish(ctx, typ, m.UserID, data)
+		}
+	}
+}
+
+func (cfg *apiConfig) messageListHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	v := &validation{}
+	limit := v.pageLimit("limit", r.URL.Query().Get("limit"))
+	cursor := v.cursor("cursor", r.URL.Query().Get("cursor"))
+	if err := v.err(); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	c, members, err := cfg.memberConversation(r, principal.UserID)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// get one more to know if there is a next page
+	var messages []database.Message
+	if cursor == uuid.Nil {
+		messages, err = cfg.db.GetMessages(r.Context(), database.GetMessagesParams{
+			ConversationID: c.ID,
+			Limit:          limit + 1,
+		})
+	} else {
+		messages, err = cfg.db.GetMessagesBefore(r.Context(), database.GetMessagesBeforeParams{
+			ConversationID: c.ID,
+			ID:             cursor,
+			Limit:          limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error getting messages")
+		return
+	}
+
+	resp := messagesResponse{Messages: []Message{}}
+	if len(messages) > int(limit) {
+		messages = messages[:limit]
+		resp.NextCursor = &messages[limit-1].ID
+	}
+	for _, msg := range messages {
+		resp.Messages = append(resp.Messages, makeMessage(msg, members))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) messageCreateHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request
+	params := parameters{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.messageBody("body", params.Body)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	c, members, err := cfg.memberConversation(r, principal.UserID)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+	var memberIDs []uuid.UUID
+	for _, m := range members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+	blocked, err := cfg.blockedByAny(r.Context(), memberIDs, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking blocked users")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "A member of the conversation blocked you", nil)
+		return
+	}
+
+	// create Message
+	msg, err := cfg.db.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: c.ID,
+		SenderID:       principal.UserID,
+		Body:           moderate(params.Body),
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error creating message")
+		return
+	}
+	err = cfg.db.TouchConversation(r.Context(), c.ID)
+	if err != nil {
+		respondWithDBError(w, err, "Error updating conversation")
+		return
+	}
+
+	message := makeMessage(msg, nil)
+	cfg.publishToMembers(r.Context(), events.MessageCreated, members, principal.UserID, message)
+
+	respondWithJSON(w, http.StatusCreated, message)
+}
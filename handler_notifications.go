@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+const (
+	notificationMention   = "mention"
+	notificationFollow    = "follow"
+	notificationChirpyRed = "chirpy_red"
+
+	// most notifications marked as read by id in one request
+	maxMarkReadIDs = 100
+	// most users notified about being mentioned in one chirp
+	maxMentions = 10
+)
+
+// mentionPattern finds @handle in a chirp, the @ of an email address is no mention
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// getNotificationTypes lists every notification type users can turn off
+func getNotificationTypes() []string {
+	return []string{
+		notificationMention,
+		notificationFollow,
+		notificationChirpyRed,
+	}
+}
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+func makeNotification(n database.Notification) Notification {
+	notification := Notification{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+	}
+	if n.ActorID.Valid {
+		notification.ActorID = &n.ActorID.UUID
+	}
+	if n.ChirpID.Valid {
+		notification.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		notification.ReadAt = &n.ReadAt.Time
+	}
+	return notification
+}
+
+type notificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`
+	// NextCursor gets the next page, it is left out on the last page
+	NextCursor *uuid.UUID `json:"next_cursor,omitempty"`
+}
+
+type markReadResponse struct {
+	Marked      int64 `json:"marked"`
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// createNotifications turns the event into notifications, it runs on the server process
+// that published the event so every notification is only created once
+func (cfg *apiConfig) createNotifications(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.UserUpgraded:
+		cfg.notify(ctx, e.UserID, notificationChirpyRed, uuid.Nil, uuid.Nil)
+	case events.ChirpCreated:
+		cfg.notifyMentions(ctx, e)
+	}
+}
+
+// mentionedHandles returns the handles mentioned in the body, each once and at most maxMentions
+func mentionedHandles(body string) []string {
+	var handles []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(m[1])
+		if len(handle) < minHandleLength || len(handle) > maxHandleLength || slices.Contains(handles, handle) {
+			continue
+		}
+		handles = append(handles, handle)
+		if len(handles) == maxMentions {
+			break
+		}
+	}
+	return handles
+}
+
+// notifyMentions notifies the users mentioned in a new chirp who may see it, the author is not notified
+func (cfg *apiConfig) notifyMentions(ctx context.Context, e events.Event) {
+	var chirp Chirp
+	err := json.Unmarshal(e.Data, &chirp)
+	if err != nil {
+		log.Printf("Error decoding chirp for mentions: %s", err)
+		return
+	}
+	for _, handle := range mentionedHandles(chirp.Body) {
+		user, err := cfg.db.GetUserByHandle(ctx, handle)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error getting mentioned user: %s", err)
+			continue
+		}
+		if user.ID == chirp.UserID {
+			continue
+		}
+		visible, err := cfg.canSee(ctx, database.Chirp{UserID: chirp.UserID, Visibility: chirp.Visibility}, user.ID)
+		if err != nil {
+			log.Printf("Error checking chirp visibility: %s", err)
+			continue
+		}
+		if visible {
+			cfg.notify(ctx, user.ID, notificationMention, chirp.UserID, chirp.ID)
+		}
+	}
+}
+
+// notify creates a notification for the user unless they turned its type off or blocked the actor,
+// actorID and chirpID are uuid.Nil if the notification has none
+func (cfg *apiConfig) notify(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) {
+	if actorID != uuid.Nil {
+		blocked, err := cfg.db.HasBlocked(ctx, database.HasBlockedParams{UserID: userID, BlockedID: actorID})
+		if err != nil {
+			log.Printf("Error checking blocked users: %s", err)
+			return
+		}
+		if blocked {
+			return
+		}
+	}
+
+	enabled, err := cfg.getPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting notification preferences: %s", err)
+		return
+	}
+	if !enabled[typ] {
+		return
+	}
+
+	n, err := cfg.db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		Type:    typ,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+	if err != nil {
+		log.Printf("Error creating notification: %s", err)
+		return
+	}
+	cfg.publish(ctx, events.NotificationCreated, userID, makeNotification(n))
+}
+
+func (cfg *apiConfig) notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	v := &validation{}
+	limit := v.pageLimit("limit", r.URL.Query().Get("limit"))
+	cursor := v.cursor("cursor", r.URL.Query().Get("cursor"))
+	if err := v.err(); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// get one more to know if there is a next page
+	var notifications []database.Notification
+	var err error
+	if cursor == uuid.Nil {
+		notifications, err = cfg.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+			UserID: principal.UserID,
+			Limit:  limit + 1,
+		})
+	} else {
+		notifications, err = cfg.db.GetNotificationsBefore(r.Context(), database.GetNotificationsBeforeParams{
+			UserID: principal.UserID,
+			ID:     cursor,
+			Limit:  limit + 1,
+		})
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error getting notifications")
+		return
+	}
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error counting notifications")
+		return
+	}
+
+	resp := notificationsResponse{
+		Notifications: []Notification{},
+		UnreadCount:   unread,
+	}
+	if len(notifications) > int(limit) {
+		notifications = notifications[:limit]
+		resp.NextCursor = &notifications[limit-1].ID
+	}
+	for _, n := range notifications {
+		resp.Notifications = append(resp.Notifications, makeNotification(n))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) notificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IDs  []uuid.UUID `json:"ids"`
+		UpTo uuid.UUID   `json:"up_to"`
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request
+	params := parameters{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		if (len(params.IDs) == 0) == (params.UpTo == uuid.Nil) {
+			v.add("ids", CodeRequired, "either ids or up_to must be set")
+		}
+		if len(params.IDs) > maxMarkReadIDs {
+			v.add("ids", CodeValidationFailed, fmt.Sprintf("must not have more than %d ids", maxMarkReadIDs))
+		}
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	// mark as read
+	var marked int64
+	if params.UpTo != uuid.Nil {
+		marked, err = cfg.db.MarkNotificationsReadUpTo(r.Context(), database.MarkNotificationsReadUpToParams{
+			UserID: principal.UserID,
+			ID:     params.UpTo,
+		})
+	}
+	for _, id := range params.IDs {
+		var n int64
+		n, err = cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+			ID:     id,
+			UserID: principal.UserID,
+		})
+		if err != nil {
+			break
+		}
+		marked += n
+	}
+	if err != nil {
+		respondWithDBError(w, err, "Error marking notifications as read")
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error counting notifications")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, markReadResponse{Marked: marked, UnreadCount: unread})
+}
+
+// getPreferences returns whether each notification type is on for the user, all are on by default
+func (cfg *apiConfig) getPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	prefs, err := cfg.db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := map[string]bool{}
+	for _, typ := range getNotificationTypes() {
+		enabled[typ] = true
+	}
+	for _, p := range prefs {
+		if _, ok := enabled[p.Type]; ok {
+			enabled[p.Type] = p.Enabled
+		}
+	}
+	return enabled, nil
+}
+
+func (cfg *apiConfig) notificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	enabled, err := cfg.getPreferences(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting notification preferences")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, enabled)
+}
+
+func (cfg *apiConfig) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request, types that are left out keep their setting
+	params := map[string]bool{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		for typ := range params {
+			if !slices.Contains(getNotificationTypes(), typ) {
+				v.add(typ, CodeValidationFailed, "unknown notification type")
+			}
+		}
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	for typ, on := range params {
+		err = cfg.db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  principal.UserID,
+			Type:    typ,
+			Enabled: on,
+		})
+		if err != nil {
+			respondWithDBError(w, err, "Error saving notification preferences")
+			return
+		}
+	}
+
+	enabled, err := cfg.getPreferences(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting notification preferences")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, enabled)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxPins    = 3
+	maxRedPins = 6
+)
+
+// pinnedFirst moves the pinned chirps of a list from one author to its front, the last pinned first,
+// the other chirps keep their order
+func (cfg *apiConfig) pinnedFirst(ctx context.Context, chirps []database.Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids, err := cfg.db.GetPinnedChirpIDs(ctx, chirps[0].UserID)
+	if err != nil {
+		return err
+	}
+	rank := func(c database.Chirp) int {
+		if i := slices.Index(ids, c.ID); i >= 0 {
+			return i
+		}
+		return len(ids)
+	}
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		return rank(a) - rank(b)
+	})
+	return nil
+}
+
+// pinHandler pins a chirp of the logged in user to their profile, pinning it again changes nothing
+func (cfg *apiConfig) pinHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	// chirps the user cannot see do not exist for them
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+	if chirp.UserID != principal.UserID {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "Only the author can pin a chirp", nil)
+		return
+	}
+	if chirp.PublishAt.Valid {
+		respondWithError(w, http.StatusConflict, CodeConflict, "Chirp is not published yet", nil)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "User not found")
+		return
+	}
+	limit := int64(maxPins)
+	if user.IsChirpyRed {
+		limit = maxRedPins
+	}
+	// the pins are counted when the chirp is pinned, so concurrent requests cannot pin more
+	n, err := cfg.db.PinChirp(r.Context(), database.PinChirpParams{ChirpID: chirpID, UserID: principal.UserID, MaxCount: limit})
+	if err != nil {
+		respondWithDBError(w, err, "Error pinning the Chirp")
+		return
+	}
+	if n == 0 {
+		// either the chirp is pinned already, which changes nothing, or there is no pin left
+		pinned, err := cfg.db.IsPinned(r.Context(), chirpID)
+		if err != nil {
+			respondWithDBError(w, err, "Error getting pinned Chirps")
+			return
+		}
+		if !pinned {
+			msg := fmt.Sprintf("You can pin at most %d chirps, %d with Chirpy Red", maxPins, maxRedPins)
+			respondWithError(w, http.StatusConflict, CodeTooManyPins, msg, nil)
+			return
+		}
+	}
+
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) unpinHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	// chirps the user cannot see do not exist for them
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+	if chirp.UserID != principal.UserID {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "Only the author can unpin a chirp", nil)
+		return
+	}
+
+	n, err := cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{ChirpID: chirpID, UserID: principal.UserID})
+	if err != nil {
+		respondWithDBError(w, err, "Error unpinning the Chirp")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp is not pinned", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
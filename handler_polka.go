@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/events"
 )
 
 func (cfg *apiConfig) polkaHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,22 +21,20 @@ func (cfg *apiConfig) polkaHandler(w http.ResponseWriter, r *http.Request) {
 	key, err := auth.GetAPIKey(r.Header)
 	if err != nil {
 		log.Printf("API key: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error no valid API key found", err)
+		respondWithError(w, http.StatusUnauthorized, CodeInvalidAPIKey, "Error no valid API key found", err)
 		return
 	}
 	if key != cfg.polkaKey {
 		log.Printf("API key: %s", key)
-		respondWithError(w, http.StatusUnauthorized, "Error wrong API key", err)
+		respondWithError(w, http.StatusUnauthorized, CodeInvalidAPIKey, "Error wrong API key", err)
 		return
 	}
 
 	// Decode Request
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = decodeWebhookJSON(w, r, &params)
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
+		respondWithAPIError(w, err)
 		return
 	}
 
@@ -51,9 +49,13 @@ func (cfg *apiConfig) polkaHandler(w http.ResponseWriter, r *http.Request) {
 	err = cfg.db.UpgradeUserToRed(r.Context(), params.Data.UserID)
 	if err != nil {
 		log.Printf("Error upgrading the user: %s", err)
-		respondWithError(w, http.StatusNotFound, "Error: User could not be upgraded", err)
+		respondWithDBError(w, err, "Error: User could not be upgraded")
 		return
 	}
+	user, err := cfg.db.GetUserByID(r.Context(), params.Data.UserID)
+	if err == nil {
+		cfg.publish(r.Context(), events.UserUpgraded, user.ID, MakeUserSafe(user))
+	}
 
 	respondWithJSON(w, http.StatusNoContent, "")
 }
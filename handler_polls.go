@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+type Poll struct {
+	Options        []PollOption `json:"options"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	ClosesAt       time.Time    `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	// VoterCount and the votes of the options are null until the viewer voted or the poll closed
+	VoterCount *int64 `json:"voter_count"`
+	// Choices are the positions of the options the viewer voted for, empty if they did not vote
+	Choices []int32 `json:"choices"`
+}
+
+type PollOption struct {
+	Position int32  `json:"position"`
+	Text     string `json:"text"`
+	Votes    *int64 `json:"votes"`
+}
+
+// pollParameters is the poll of a new chirp
+type pollParameters struct {
+	Options        []string  `json:"options"`
+	ClosesAt       time.Time `json:"closes_at"`
+	MultipleChoice bool      `json:"multiple_choice"`
+}
+
+// makePolls returns the polls of the chirps as the viewer sees them by chirp id, chirps without a poll are left out
+func (cfg *apiConfig) makePolls(ctx context.Context, chirpIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]*Poll, error) {
+	polls := map[uuid.UUID]*Poll{}
+	stored, err := cfg.db.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil || len(stored) == 0 {
+		return polls, err
+	}
+	results, err := cfg.db.GetPollResultsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	options := map[uuid.UUID][]database.GetPollResultsForChirpsRow{}
+	for _, r := range results {
+		options[r.ChirpID] = append(options[r.ChirpID], r)
+	}
+	choices := map[uuid.UUID]int32{}
+	if viewerID != uuid.Nil {
+		votes, err := cfg.db.GetPollVotesForChirps(ctx, database.GetPollVotesForChirpsParams{ChirpIds: chirpIDs, UserID: viewerID})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range votes {
+			choices[v.ChirpID] = v.Choices
+		}
+	}
+	for _, p := range stored {
+		polls[p.ChirpID] = makePoll(p, options[p.ChirpID], choices[p.ChirpID])
+	}
+	return polls, nil
+}
+
+// makePoll returns the poll as a viewer sees it who voted for the choices, 0 if they did not vote
+func makePoll(p database.GetPollsForChirpsRow, results []database.GetPollResultsForChirpsRow, choices int32) *Poll {
+	poll := &Poll{
+		Options:        []PollOption{},
+		MultipleChoice: p.MultipleChoice,
+		ClosesAt:       p.ClosesAt,
+		Closed:         !time.Now().Before(p.ClosesAt),
+		Choices:        []int32{},
+	}
+	for _, r := range results {
+		if choices&(1<<r.Position) != 0 {
+			poll.Choices = append(poll.Choices, r.Position)
+		}
+	}
+
+	// the counts would sway the vote, so they are shown to those who voted and once it is over
+	showResults := poll.Closed || len(poll.Choices) > 0
+	if showResults {
+		poll.VoterCount = &p.VoterCount
+	}
+	for _, r := range results {
+		option := PollOption{Position: r.Position, Text: r.Text}
+		if showResults {
+			option.Votes = &r.Votes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	return poll
+}
+
+// maxPollDuration is how long the polls of a user may run, Chirpy Red members get longer
+func (cfg *apiConfig) maxPollDuration(ctx context.Context, userID uuid.UUID) (time.Duration, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.IsChirpyRed {
+		return maxRedPollDays * 24 * time.Hour, nil
+	}
+	return maxPollDays * 24 * time.Hour, nil
+}
+
+// chirpWithPoll returns what creates the new chirp together with its poll, the options are moderated
+func chirpWithPoll(chirp database.PostChirpParams, params pollParameters) database.PostChirpParams {
+	options := []string{}
+	for _, text := range params.Options {
+		options = append(options, moderate(text))
+	}
+	chirp.PollClosesAt = sql.NullTime{Time: params.ClosesAt.UTC(), Valid: true}
+	chirp.PollMultipleChoice = params.MultipleChoice
+	chirp.PollOptions = options
+	return chirp
+}
+
+// pollVoteHandler records the vote of the logged in user, every user votes once and cannot change it
+func (cfg *apiConfig) pollVoteHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		// positions of the chosen options
+		Choices []int32 `json:"choices"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	params := parameters{}
+	// the choices are checked against the poll below
+	err = decodeJSON(w, r, &params, nil)
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+	poll, err := cfg.db.GetPoll(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp has no poll")
+		return
+	}
+	if chirp.PublishAt.Valid {
+		respondWithError(w, http.StatusConflict, CodeConflict, "Poll opens when the chirp is published", nil)
+		return
+	}
+	if !time.Now().Before(poll.ClosesAt) {
+		respondWithError(w, http.StatusConflict, CodePollClosed, "Poll is closed", nil)
+		return
+	}
+	blocked, err := cfg.db.HasBlocked(r.Context(), database.HasBlockedParams{UserID: chirp.UserID, BlockedID: principal.UserID})
+	if err != nil {
+		respondWithDBError(w, err, "Error checking blocked users")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "The author blocked you", nil)
+		return
+	}
+
+	options, err := cfg.db.GetPollResults(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting the poll options")
+		return
+	}
+	v := &validation{}
+	v.pollChoices("choices", params.Choices, len(options), poll.MultipleChoice)
+	if err := v.err(); err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+	var choices int32
+	for _, c := range params.Choices {
+		choices |= 1 << c
+	}
+
+	// the primary key keeps a second vote out, also when both arrive at once
+	_, err = cfg.db.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		ChirpID: chirpID,
+		UserID:  principal.UserID,
+		Choices: choices,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error saving the vote")
+		return
+	}
+
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp poll")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
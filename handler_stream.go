@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// events kept for clients that reconnect with Last-Event-ID
+	eventHistorySize = 1000
+	// events buffered for a stream client before it is disconnected as too slow
+	streamBufferSize = 64
+	// a comment is sent this often so proxies do not close an idle stream
+	streamHeartbeatInterval = 15 * time.Second
+	// a client that does not read a single event in this time is disconnected
+	streamWriteTimeout = 10 * time.Second
+	// browsers wait this long before reconnecting
+	streamRetry = 3 * time.Second
+)
+
+// chirpVisibility returns the visibility of the chirp of a chirp event
+func chirpVisibility(e events.Event) string {
+	var c struct {
+		Visibility string `json:"visibility"`
+	}
+	json.Unmarshal(e.Data, &c)
+	return c.Visibility
+}
+
+func (cfg *apiConfig) chirpStreamHandler(w http.ResponseWriter, r *http.Request) {
+	// filter chirps from author
+	authorID := uuid.Nil
+	if author := r.URL.Query().Get("author_id"); author != "" {
+		var err error
+		authorID, err = uuid.Parse(author)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Error invalid user id", err)
+			return
+		}
+	}
+
+	// resume after the last event the client got
+	var lastEventID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		var err error
+		lastEventID, err = strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, CodeValidationFailed, "Last-Event-ID is not an event id", err)
+			return
+		}
+	}
+
+	sub := cfg.events.Subscribe(lastEventID)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// write sends one message, it fails if the client does not read it in time
+	write := func(format string, args ...any) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	err := write("retry: %d\n\n", streamRetry.Milliseconds())
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = write(": heartbeat\n\n")
+		case e, ok := <-sub.Events():
+			if !ok {
+				// dropped by the bus for falling behind, the client resumes with Last-Event-ID
+				return
+			}
+			if e.Type != events.ChirpCreated && e.Type != events.ChirpDeleted {
+				continue
+			}
+			if authorID != uuid.Nil && e.UserID != authorID {
+				continue
+			}
+			// anyone can read the stream, so it only has public chirps
+			if chirpVisibility(e) != visibilityPublic {
+				continue
+			}
+			err = write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
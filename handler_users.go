@@ -1,13 +1,16 @@
 package main
 
 import (
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/zelieen/Chirpy/internal/auth"
 	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
 
 	"github.com/google/uuid"
 )
@@ -18,31 +21,86 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Role        string    `json:"role"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	// SensitiveContent is how chirps with a warning are shown: collapse, expand or hide
+	SensitiveContent string `json:"sensitive_content"`
 }
 
 func MakeUserSafe(u database.User) User {
 	return User{
+		ID:               u.ID,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+		Email:            u.Email,
+		IsChirpyRed:      u.IsChirpyRed,
+		Role:             u.Role,
+		Handle:           nullString(u.Handle),
+		DisplayName:      u.DisplayName,
+		Bio:              u.Bio,
+		AvatarURL:        u.AvatarUrl,
+		SensitiveContent: u.SensitiveContent,
+	}
+}
+
+// Profile is what everybody can see of a user, it never contains the email
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func makeProfile(u database.User) Profile {
+	return Profile{
 		ID:          u.ID,
 		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Email:       u.Email,
+		Handle:      nullString(u.Handle),
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarUrl,
 		IsChirpyRed: u.IsChirpyRed,
 	}
 }
 
+// nullString turns NULL into a JSON null
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+type loginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshResponse struct {
+	AccessToken string `json:"token"`
+}
+
 func (cfg *apiConfig) userHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
-		Password string `json:password`
+		Password string `json:"password"`
 	}
 
-	// Decode Request
-	decoder := json.NewDecoder(r.Body)
+	// Decode and validate Request
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.email("email", params.Email)
+		v.password("password", params.Password)
+	})
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
+		respondWithAPIError(w, err)
 		return
 	}
 
@@ -50,7 +108,7 @@ func (cfg *apiConfig) userHandler(w http.ResponseWriter, r *http.Request) {
 	hash, err := auth.HashPassword(params.Password)
 	if err != nil {
 		log.Printf("Error during hashing: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error handling password", err)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Error handling password", err)
 		return
 	}
 
@@ -61,7 +119,7 @@ func (cfg *apiConfig) userHandler(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		log.Printf("Error creating user: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error while creating user", err)
+		respondWithDBError(w, err, "Error while creating user")
 		return
 	}
 
@@ -73,20 +131,16 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
 	const Expiry int = 3600 // this is declared in refreshHandler as well
 
-	// Decode Request
-	decoder := json.NewDecoder(r.Body)
+	// Decode and validate Request
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		v.required("email", params.Email)
+		v.required("password", params.Password)
+	})
 	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
+		respondWithAPIError(w, err)
 		return
 	}
 
@@ -94,7 +148,7 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := cfg.db.GetUserByEMail(r.Context(), params.Email)
 	if err != nil {
 		log.Printf("Error fetching user: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		respondWithError(w, http.StatusUnauthorized, CodeInvalidCredentials, "Incorrect email or password", err)
 		return
 	}
 
@@ -102,15 +156,15 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
 		log.Printf("Password did not match: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		respondWithError(w, http.StatusUnauthorized, CodeInvalidCredentials, "Incorrect email or password", err)
 		return
 	}
 
 	// make login token
-	newToken, err := auth.MakeJWT(user.ID, cfg.secret, (time.Duration(Expiry) * time.Second))
+	newToken, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.secret, (time.Duration(Expiry) * time.Second))
 	if err != nil {
 		log.Printf("Could not create token: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Login failed", err)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Login failed", err)
 		return
 	}
 
@@ -118,7 +172,7 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
 		log.Printf("Could not make refresh token: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Login failed", err)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Login failed", err)
 		return
 	}
 
@@ -129,11 +183,11 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		log.Printf("Could not store refresh token: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Login failed", err)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Login failed", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
+	respondWithJSON(w, http.StatusOK, loginResponse{
 		User:         MakeUserSafe(user),
 		Token:        newToken,
 		RefreshToken: refreshToken,
@@ -142,15 +196,11 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 	const Expiry int = 3600 // this is declared in loginHandler as well
-
-	type response struct {
-		AccessToken string `json:"token"`
-	}
 	// check log in status
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Token bearer: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error getting the token bearer", err)
+		respondUnauthorized(w, CodeMissingToken, "Error getting the token bearer", err)
 		return
 	}
 
@@ -158,29 +208,37 @@ func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 	fullRefToken, err := cfg.db.GetRefreshToken(r.Context(), refreshToken)
 	if err != nil {
 		log.Printf("Database: %s\n", err)
-		respondWithError(w, http.StatusUnauthorized, "Error getting the refresh token", err)
+		respondUnauthorized(w, CodeInvalidToken, "Error getting the refresh token", err)
 		return
 	}
 	if fullRefToken.RevokedAt.Valid {
 		log.Printf("No valid token")
-		respondWithError(w, http.StatusUnauthorized, "Refresh token was revoked and is no longer valid", err)
+		respondUnauthorized(w, CodeInvalidToken, "Refresh token was revoked and is no longer valid", errors.New("refresh token revoked"))
 		return
 	}
 	if fullRefToken.ExpiresAt.Compare(time.Now()) < 1 {
 		log.Printf("Expires at: %s\n", fullRefToken.ExpiresAt)
-		respondWithError(w, http.StatusUnauthorized, "Refresh token has expired", err)
+		respondUnauthorized(w, CodeInvalidToken, "Refresh token has expired", errors.New("refresh token expired"))
+		return
+	}
+
+	// the role is read again so that role changes apply on the next refresh
+	user, err := cfg.db.GetUserByID(r.Context(), fullRefToken.UserID)
+	if err != nil {
+		log.Printf("Error fetching user: %s", err)
+		respondUnauthorized(w, CodeInvalidToken, "Error getting the user of the refresh token", err)
 		return
 	}
 
 	// make login token
-	newToken, err := auth.MakeJWT(fullRefToken.UserID, cfg.secret, (time.Duration(Expiry) * time.Second))
+	newToken, err := auth.MakeJWT(user.ID, auth.Role(user.Role), cfg.secret, (time.Duration(Expiry) * time.Second))
 	if err != nil {
 		log.Printf("Could not create access token: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Refresh failed", err)
+		respondWithError(w, http.StatusInternalServerError, CodeInternal, "Refresh failed", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{AccessToken: newToken})
+	respondWithJSON(w, http.StatusOK, refreshResponse{AccessToken: newToken})
 }
 
 func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
@@ -188,7 +246,7 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Token bearer: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error getting the token bearer", err)
+		respondUnauthorized(w, CodeMissingToken, "Error getting the token bearer", err)
 		return
 	}
 
@@ -196,61 +254,161 @@ func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
 	err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
 	if err != nil {
 		log.Printf("Token from database: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Could not revoke token", err)
+		respondWithDBError(w, err, "Could not revoke token")
 		return
 	}
+	token, err := cfg.db.GetRefreshToken(r.Context(), refreshToken)
+	if err == nil {
+		cfg.publish(r.Context(), events.SessionRevoked, token.UserID, sessionRevoked{
+			UserID:    token.UserID,
+			RevokedAt: token.RevokedAt.Time,
+		})
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// updateUserHandler changes the fields that are in the request and keeps the others,
+// an empty handle removes the handle
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email            *string `json:"email"`
+		Password         *string `json:"password"`
+		Handle           *string `json:"handle"`
+		DisplayName      *string `json:"display_name"`
+		Bio              *string `json:"bio"`
+		AvatarURL        *string `json:"avatar_url"`
+		SensitiveContent *string `json:"sensitive_content"`
 	}
 
-	// check log in status
-	token, err := auth.GetBearerToken(r.Header)
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// Decode and validate Request
+	params := parameters{}
+	err := decodeJSON(w, r, &params, func(v *validation) {
+		if params.Email != nil {
+			v.email("email", *params.Email)
+		}
+		if params.Password != nil {
+			v.password("password", *params.Password)
+		}
+		if params.Handle != nil && *params.Handle != "" {
+			v.handle("handle", *params.Handle)
+		}
+		if params.DisplayName != nil {
+			v.maxLength("display_name", *params.DisplayName, maxDisplayName)
+		}
+		if params.Bio != nil {
+			v.maxLength("bio", *params.Bio, maxBioLength)
+		}
+		if params.AvatarURL != nil {
+			v.httpURL("avatar_url", *params.AvatarURL)
+		}
+		if params.SensitiveContent != nil {
+			v.sensitiveContent("sensitive_content", *params.SensitiveContent)
+		}
+	})
 	if err != nil {
-		log.Printf("Token bearer: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error getting the token bearer", err)
+		respondWithAPIError(w, err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+
+	user, err := cfg.db.GetUserByID(r.Context(), principal.UserID)
 	if err != nil {
-		log.Printf("Invalid token: %s", err)
-		respondWithError(w, http.StatusUnauthorized, "Error validating the token", err)
+		log.Printf("Error fetching user: %s", err)
+		respondWithDBError(w, err, "User not found")
 		return
 	}
 
-	// Decode Request
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
-		return
+	// Change the credentials
+	if params.Email != nil || params.Password != nil {
+		credentials := database.UpdateUserCredentialsParams{
+			ID:             user.ID,
+			Email:          user.Email,
+			HashedPassword: user.HashedPassword,
+		}
+		if params.Email != nil {
+			credentials.Email = *params.Email
+		}
+		if params.Password != nil {
+			credentials.HashedPassword, err = auth.HashPassword(*params.Password)
+			if err != nil {
+				log.Printf("Error during hashing: %s", err)
+				respondWithError(w, http.StatusInternalServerError, CodeInternal, "Error handling password", err)
+				return
+			}
+		}
+		user, err = cfg.db.UpdateUserCredentials(r.Context(), credentials)
+		if err != nil {
+			log.Printf("Error during updating: %s", err)
+			respondWithDBError(w, err, "Error updating the user credentials")
+			return
+		}
 	}
 
-	// Hash the password
-	hash, err := auth.HashPassword(params.Password)
-	if err != nil {
-		log.Printf("Error during hashing: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error handling password", err)
-		return
+	// Change the profile
+	if params.Handle != nil || params.DisplayName != nil || params.Bio != nil || params.AvatarURL != nil {
+		profile := database.UpdateUserProfileParams{
+			ID:          user.ID,
+			Handle:      user.Handle,
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			AvatarUrl:   user.AvatarUrl,
+		}
+		if params.Handle != nil {
+			profile.Handle = sql.NullString{String: *params.Handle, Valid: *params.Handle != ""}
+		}
+		if params.DisplayName != nil {
+			profile.DisplayName = *params.DisplayName
+		}
+		if params.Bio != nil {
+			profile.Bio = *params.Bio
+		}
+		if params.AvatarURL != nil {
+			profile.AvatarUrl = *params.AvatarURL
+		}
+		oldAvatarURL := user.AvatarUrl
+		user, err = cfg.db.UpdateUserProfile(r.Context(), profile)
+		if err != nil {
+			log.Printf("Error during updating: %s", err)
+			respondWithDBError(w, err, "Error updating the profile")
+			return
+		}
+		if user.AvatarUrl != oldAvatarURL {
+			cfg.deleteUploadedAvatar(r.Context(), oldAvatarURL)
+		}
 	}
 
-	// Change user in database
-	updatedUser, err := cfg.db.UpdateUserCredentials(r.Context(), database.UpdateUserCredentialsParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hash,
-	})
+	// Change the preferences
+	if params.SensitiveContent != nil {
+		user, err = cfg.db.UpdateUserSensitiveContent(r.Context(), database.UpdateUserSensitiveContentParams{
+			ID:               user.ID,
+			SensitiveContent: *params.SensitiveContent,
+		})
+		if err != nil {
+			log.Printf("Error during updating: %s", err)
+			respondWithDBError(w, err, "Error updating the preferences")
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, MakeUserSafe(user))
+}
+
+// profileHandler shows the public profile of a user, the path is either the user id or the handle
+func (cfg *apiConfig) profileHandler(w http.ResponseWriter, r *http.Request) {
+	handleOrID := r.PathValue("handleOrID")
+
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(handleOrID); parseErr == nil {
+		user, err = cfg.db.GetUserByID(r.Context(), id)
+	} else {
+		user, err = cfg.db.GetUserByHandle(r.Context(), strings.TrimPrefix(handleOrID, "@"))
+	}
 	if err != nil {
-		log.Printf("Error during updating: %s", err)
-		respondWithError(w, http.StatusInternalServerError, "Error updating the user credentials", err)
+		respondWithDBError(w, err, "User not found")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, MakeUserSafe(updatedUser))
+	respondWithJSON(w, http.StatusOK, makeProfile(user))
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// hasWarning reports whether the chirp has a content warning or is marked sensitive
+func hasWarning(c database.Chirp) bool {
+	return c.ContentWarning.Valid || c.Sensitive
+}
+
+// nullWarning turns a missing or blank warning into NULL and moderates the others
+func nullWarning(value *string) sql.NullString {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: moderate(*value), Valid: true}
+}
+
+// sensitiveContentSetting returns how the user wants chirps with a warning shown,
+// nobody (uuid.Nil) gets the default
+func (cfg *apiConfig) sensitiveContentSetting(ctx context.Context, userID uuid.UUID) (string, error) {
+	if userID == uuid.Nil {
+		return "collapse", nil
+	}
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.SensitiveContent, nil
+}
+
+// collapsedFor reports whether clients should only show the warning of the chirp to a viewer
+// with the sensitive_content setting
+func collapsedFor(c database.Chirp, setting string) bool {
+	return hasWarning(c) && setting != "expand"
+}
+
+// chirpWarningHandler lets moderators set or remove the warning of any chirp
+func (cfg *apiConfig) chirpWarningHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		// null or blank removes the warning
+		ContentWarning *string `json:"content_warning"`
+		Sensitive      bool    `json:"sensitive"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, CodeInvalidID, "Not a valid chirp id", err)
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if !principal.HasRole(auth.RoleModerator) {
+		respondWithError(w, http.StatusForbidden, CodeForbidden, "Only moderators can change the warning of a chirp", nil)
+		return
+	}
+
+	params := parameters{}
+	err = decodeJSON(w, r, &params, func(v *validation) {
+		v.contentWarning("content_warning", params.ContentWarning)
+	})
+	if err != nil {
+		respondWithAPIError(w, err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithDBError(w, err, "Chirp not found")
+		return
+	}
+	visible, err := cfg.canSee(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error checking followers")
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, CodeNotFound, "Chirp not found", nil)
+		return
+	}
+
+	chirp, err = cfg.db.SetChirpWarning(r.Context(), database.SetChirpWarningParams{
+		ID:             chirpID,
+		ContentWarning: nullWarning(params.ContentWarning),
+		Sensitive:      params.Sensitive,
+	})
+	if err != nil {
+		respondWithDBError(w, err, "Error changing the warning")
+		return
+	}
+	response, err := cfg.makeChirp(r.Context(), chirp, principal.UserID)
+	if err != nil {
+		respondWithDBError(w, err, "Error getting Chirp media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
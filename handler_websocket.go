@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// the server pings this often, a client that does not answer within wsPongWait is disconnected
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	// a client that does not read a single message in this time is disconnected
+	wsWriteTimeout = 10 * time.Second
+	// largest message a client may send
+	wsMaxMessageBytes  = 4096
+	wsMaxSubscriptions = 20
+)
+
+// channels a client can subscribe to
+const (
+	wsChannelTimeline      = "timeline"
+	wsChannelAuthor        = "author"
+	wsChannelNotifications = "notifications"
+)
+
+// wsUpgrader keeps the default CheckOrigin, which refuses browser handshakes from other origins.
+// It is not what protects the accounts: the handshake needs the access token in the Authorization
+// header, and browsers never add it on their own, so other sites cannot connect in a user's name.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsClientMessage is a message from the client, the fields used depend on the type
+type wsClientMessage struct {
+	// subscribe, unsubscribe or auth
+	Type string `json:"type"`
+	// ID names a subscription, it is chosen by the client
+	ID       string `json:"id"`
+	Channel  string `json:"channel"`
+	AuthorID string `json:"author_id"`
+	// Token is a new access token for the connection
+	Token string `json:"token"`
+	// invalid is set when the message is not valid JSON
+	invalid bool
+}
+
+type wsServerMessage struct {
+	// subscribed, unsubscribed, authenticated, event or error
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	Event     events.Type     `json:"event,omitempty"`
+	EventID   uint64          `json:"event_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Code      ErrorCode       `json:"code,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// wsSubscription picks the events for one subscription of a client
+type wsSubscription struct {
+	channel  string
+	authorID uuid.UUID
+}
+
+// matches reports whether the event belongs to the subscription, the timeline only has the chirps
+// of the user and of the users they follow
+func (s wsSubscription) matches(e events.Event, userID uuid.UUID, following map[uuid.UUID]bool) bool {
+	switch s.channel {
+	case wsChannelTimeline:
+		return isChirpEvent(e) && (e.UserID == userID || following[e.UserID])
+	case wsChannelAuthor:
+		return isChirpEvent(e) && e.UserID == s.authorID
+	case wsChannelNotifications:
+		return !isChirpEvent(e) && e.UserID == userID
+	}
+	return false
+}
+
+func isChirpEvent(e events.Event) bool {
+	return e.Type == events.ChirpCreated || e.Type == events.ChirpDeleted
+}
+
+// allSessionsRevoked reports whether a session.revoked event ends every session of the user,
+// not only the one that logged out
+func allSessionsRevoked(e events.Event) bool {
+	var data sessionRevoked
+	err := json.Unmarshal(e.Data, &data)
+	return err == nil && data.All
+}
+
+func isRelationEvent(e events.Event) bool {
+	switch e.Type {
+	case events.UserBlocked, events.UserUnblocked, events.UserMuted, events.UserUnmuted, events.UserFollowed, events.UserUnfollowed:
+		return true
+	}
+	return false
+}
+
+// wsClosing closes the connection with a close message to the client
+type wsClosing struct {
+	code   int
+	reason string
+}
+
+func (c *wsClosing) Error() string {
+	return c.reason
+}
+
+// wsConn is a client connection. The goroutine of the handler owns its state and does all
+// writes, a second goroutine reads the client messages and hands them over.
+type wsConn struct {
+	ctx           context.Context
+	cfg           *apiConfig
+	conn          *websocket.Conn
+	principal     auth.Principal
+	expiry        *time.Timer
+	subscriptions map[string]wsSubscription
+	// hidden are the users the user blocked or muted, their chirps are not sent
+	hidden map[uuid.UUID]bool
+	// following are the users the user follows, their followers-only chirps are sent
+	following map[uuid.UUID]bool
+}
+
+func (cfg *apiConfig) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	// the upgrader responds with an error itself
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConn{
+		ctx:           r.Context(),
+		cfg:           cfg,
+		conn:          conn,
+		principal:     principal,
+		subscriptions: map[string]wsSubscription{},
+	}
+	err = c.run()
+
+	var closing *wsClosing
+	if errors.As(err, &closing) {
+		msg := websocket.FormatCloseMessage(closing.code, closing.reason)
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+	}
+}
+
+// run serves the connection until it fails or is closed
+func (c *wsConn) run() error {
+	sub := c.cfg.events.Subscribe(0)
+	defer sub.Close()
+	err := c.loadRelations()
+	if err != nil {
+		return err
+	}
+
+	incoming := make(chan wsClientMessage)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go c.read(incoming, readErr, done)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	c.expiry = time.NewTimer(time.Until(c.principal.ExpiresAt))
+	defer c.expiry.Stop()
+
+	for {
+		var err error
+		select {
+		case err = <-readErr:
+			return err
+		case msg := <-incoming:
+			err = c.handle(msg)
+		case e, ok := <-sub.Events():
+			if !ok {
+				return &wsClosing{code: websocket.CloseTryAgainLater, reason: "client is too slow"}
+			}
+			err = c.dispatch(e)
+		case <-ping.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		case <-c.expiry.C:
+			return &wsClosing{code: websocket.ClosePolicyViolation, reason: "access token expired"}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// read passes client messages to incoming until reading fails or run is done
+func (c *wsConn) read(incoming chan<- wsClientMessage, readErr chan<- error, done <-chan struct{}) {
+	c.conn.SetReadLimit(wsMaxMessageBytes)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		var msg wsClientMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			msg = wsClientMessage{invalid: true}
+		}
+		select {
+		case incoming <- msg:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *wsConn) write(msg wsServerMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsConn) writeError(id string, code ErrorCode, message string) error {
+	return c.write(wsServerMessage{Type: "error", ID: id, Code: code, Message: message})
+}
+
+func (c *wsConn) loadRelations() error {
+	hidden, err := c.cfg.hiddenUsers(c.ctx, c.principal.UserID)
+	if err != nil {
+		return err
+	}
+	following, err := c.cfg.followedUsers(c.ctx, c.principal.UserID)
+	if err != nil {
+		return err
+	}
+	c.hidden = hidden
+	c.following = following
+	return nil
+}
+
+// shows reports whether the chirp of a chirp event is sent to the user, like GET /api/chirps
+// it leaves out hidden users and unlisted chirps, and followers-only chirps of users they do not follow
+func (c *wsConn) shows(e events.Event) bool {
+	if e.UserID == c.principal.UserID {
+		return true
+	}
+	if c.hidden[e.UserID] {
+		return false
+	}
+	switch chirpVisibility(e) {
+	case visibilityUnlisted:
+		return false
+	case visibilityFollowers:
+		return c.following[e.UserID]
+	}
+	return true
+}
+
+// dispatch sends the event once for every subscription it matches
+func (c *wsConn) dispatch(e events.Event) error {
+	if e.Type == events.SessionRevoked && e.UserID == c.principal.UserID && allSessionsRevoked(e) {
+		return &wsClosing{code: websocket.ClosePolicyViolation, reason: "sessions revoked"}
+	}
+	if isRelationEvent(e) && e.UserID == c.principal.UserID {
+		err := c.loadRelations()
+		if err != nil {
+			return err
+		}
+	}
+	if isChirpEvent(e) && !c.shows(e) {
+		return nil
+	}
+	for id, s := range c.subscriptions {
+		if !s.matches(e, c.principal.UserID, c.following) {
+			continue
+		}
+		err := c.write(wsServerMessage{Type: "event", ID: id, Event: e.Type, EventID: e.ID, Data: e.Data})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *wsConn) handle(msg wsClientMessage) error {
+	if msg.invalid {
+		return c.writeError("", CodeInvalidJSON, "Message is not valid JSON")
+	}
+	switch msg.Type {
+	case "subscribe":
+		return c.subscribe(msg)
+	case "unsubscribe":
+		if _, ok := c.subscriptions[msg.ID]; !ok {
+			return c.writeError(msg.ID, CodeNotFound, "No subscription with this id")
+		}
+		delete(c.subscriptions, msg.ID)
+		return c.write(wsServerMessage{Type: "unsubscribed", ID: msg.ID})
+	case "auth":
+		return c.reauthenticate(msg.Token)
+	default:
+		return c.writeError(msg.ID, CodeValidationFailed, "Unknown message type")
+	}
+}
+
+func (c *wsConn) subscribe(msg wsClientMessage) error {
+	if msg.ID == "" {
+		return c.writeError("", CodeRequired, "A subscription needs an id")
+	}
+	if _, ok := c.subscriptions[msg.ID]; ok {
+		return c.writeError(msg.ID, CodeConflict, "Subscription id is already used")
+	}
+	if len(c.subscriptions) >= wsMaxSubscriptions {
+		return c.writeError(msg.ID, CodeValidationFailed, "Too many subscriptions")
+	}
+
+	s := wsSubscription{channel: msg.Channel}
+	switch msg.Channel {
+	case wsChannelTimeline, wsChannelNotifications:
+	case wsChannelAuthor:
+		authorID, err := uuid.Parse(msg.AuthorID)
+		if err != nil {
+			return c.writeError(msg.ID, CodeInvalidID, "Error invalid user id")
+		}
+		s.authorID = authorID
+	default:
+		return c.writeError(msg.ID, CodeValidationFailed, "Unknown channel")
+	}
+	c.subscriptions[msg.ID] = s
+	return c.write(wsServerMessage{Type: "subscribed", ID: msg.ID})
+}
+
+// reauthenticate replaces the access token of the connection before it expires,
+// the new token has to belong to the same user
+func (c *wsConn) reauthenticate(token string) error {
+	claims, err := auth.ValidateJWTClaims(token, c.cfg.secret)
+	if err != nil {
+		return c.writeError("", CodeInvalidToken, "Invalid access token")
+	}
+	principal, err := auth.NewPrincipal(claims)
+	if err != nil {
+		return c.writeError("", CodeInvalidToken, "Invalid access token")
+	}
+	if principal.UserID != c.principal.UserID {
+		return &wsClosing{code: websocket.ClosePolicyViolation, reason: "access token of another user"}
+	}
+
+	c.principal = principal
+	c.expiry.Reset(time.Until(principal.ExpiresAt))
+	return c.write(wsServerMessage{Type: "authenticated", ExpiresAt: &principal.ExpiresAt})
+}
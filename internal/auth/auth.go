@@ -24,12 +24,57 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	Token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+// Role is the access level of a user, every role includes the permissions of the roles below it
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+func getRoleLevels() map[Role]int {
+	return map[Role]int{
+		RoleUser:      1,
+		RoleModerator: 2,
+		RoleAdmin:     3,
+	}
+}
+
+func ParseRole(role string) (Role, error) {
+	r := Role(role)
+	if _, ok := getRoleLevels()[r]; !ok {
+		return "", fmt.Errorf("error: unknown role: '%s'", role)
+	}
+	return r, nil
+}
+
+// Includes reports whether r grants at least the permissions of required
+func (r Role) Includes(required Role) bool {
+	levels := getRoleLevels()
+	level, ok := levels[r]
+	if !ok {
+		return false
+	}
+	return level >= levels[required]
+}
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Role   Role   `json:"role"`
+	Scopes string `json:"scope,omitempty"`
+}
+
+func MakeJWT(userID uuid.UUID, role Role, tokenSecret string, expiresIn time.Duration) (string, error) {
+	Token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+		Role: role,
 	})
 	signedJWT, err := Token.SignedString([]byte(tokenSecret))
 	if err != nil {
@@ -39,17 +84,27 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	}, jwt.WithLeeway(5*time.Second))
+	claims, err := ValidateJWTClaims(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
-	id, err := token.Claims.GetSubject()
+	return uuid.Parse(claims.Subject)
+}
+
+// ValidateJWTClaims checks the token like ValidateJWT but returns all of its claims
+func ValidateJWTClaims(tokenString, tokenSecret string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithLeeway(5*time.Second))
 	if err != nil {
-		return uuid.UUID{}, err
+		return nil, err
+	}
+	// tokens issued before roles existed carry no role claim
+	if claims.Role == "" {
+		claims.Role = RoleUser
 	}
-	return uuid.Parse(id)
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
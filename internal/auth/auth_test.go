@@ -69,8 +69,8 @@ func TestJWTs(t *testing.T) {
 	userID2 := uuid.New()
 	duration1 := time.Duration(1 * time.Hour)
 	duration2 := time.Duration(1 * time.Nanosecond)
-	JWT1, _ := MakeJWT(userID1, secret1, duration1)
-	JWT2, _ := MakeJWT(userID2, secret2, duration2)
+	JWT1, _ := MakeJWT(userID1, RoleUser, secret1, duration1)
+	JWT2, _ := MakeJWT(userID2, RoleUser, secret2, duration2)
 
 	time.Sleep(time.Duration(5 * time.Second)) // Because token expiration is checked with leeway of 5 seconds
 
@@ -134,6 +134,76 @@ func TestJWTs(t *testing.T) {
 	}
 }
 
+func TestJWTRoleClaim(t *testing.T) {
+	secret := "testSecret"
+	userID := uuid.New()
+
+	tests := []struct {
+		name string
+		role Role
+		want Role
+	}{
+		{
+			name: "Admin role",
+			role: RoleAdmin,
+			want: RoleAdmin,
+		},
+		{
+			name: "Moderator role",
+			role: RoleModerator,
+			want: RoleModerator,
+		},
+		{
+			name: "Missing role defaults to user",
+			role: "",
+			want: RoleUser,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := MakeJWT(userID, tt.role, secret, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+			claims, err := ValidateJWTClaims(token, secret)
+			if err != nil {
+				t.Fatalf("ValidateJWTClaims() error = %v", err)
+			}
+			if claims.Role != tt.want {
+				t.Errorf("ValidateJWTClaims() role = %v, want %v", claims.Role, tt.want)
+			}
+			if claims.Subject != userID.String() {
+				t.Errorf("ValidateJWTClaims() subject = %v, want %v", claims.Subject, userID)
+			}
+		})
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		required Role
+		want     bool
+	}{
+		{"Admin includes moderator", RoleAdmin, RoleModerator, true},
+		{"Admin includes user", RoleAdmin, RoleUser, true},
+		{"Moderator includes user", RoleModerator, RoleUser, true},
+		{"Moderator excludes admin", RoleModerator, RoleAdmin, false},
+		{"User excludes admin", RoleUser, RoleAdmin, false},
+		{"Unknown role excludes user", Role("guest"), RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.Includes(tt.required); got != tt.want {
+				t.Errorf("Role(%v).Includes(%v) = %v, want %v", tt.role, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBearerToken(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID  uuid.UUID
+	Roles   []Role
+	TokenID string
+	Scopes  []string
+	// ExpiresAt is when the access token expires, zero if it does not
+	ExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+func NewPrincipal(claims *Claims) (Principal, error) {
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Principal{}, err
+	}
+	p := Principal{
+		UserID:  userID,
+		Roles:   []Role{claims.Role},
+		TokenID: claims.ID,
+		Scopes:  strings.Fields(claims.Scopes),
+	}
+	if claims.ExpiresAt != nil {
+		p.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return p, nil
+}
+
+// HasRole reports whether any of the principal's roles includes the required one
+func (p Principal) HasRole(required Role) bool {
+	for _, r := range p.Roles {
+		if r.Includes(required) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
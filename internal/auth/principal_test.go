@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPrincipalFromToken(t *testing.T) {
+	secret := "testSecret"
+	userID := uuid.New()
+	token, _ := MakeJWT(userID, RoleModerator, secret, time.Hour)
+
+	claims, err := ValidateJWTClaims(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateJWTClaims() error = %v", err)
+	}
+	principal, err := NewPrincipal(claims)
+	if err != nil {
+		t.Fatalf("NewPrincipal() error = %v", err)
+	}
+
+	if principal.UserID != userID {
+		t.Errorf("NewPrincipal() user = %v, want %v", principal.UserID, userID)
+	}
+	if principal.TokenID == "" {
+		t.Errorf("NewPrincipal() token id is empty")
+	}
+	if until := time.Until(principal.ExpiresAt); until <= 0 || until > time.Hour {
+		t.Errorf("NewPrincipal() expires at %v, want in an hour", principal.ExpiresAt)
+	}
+	if !principal.HasRole(RoleUser) || principal.HasRole(RoleAdmin) {
+		t.Errorf("NewPrincipal() roles = %v, want moderator", principal.Roles)
+	}
+
+	ctx := ContextWithPrincipal(context.Background(), principal)
+	got, ok := PrincipalFromContext(ctx)
+	if !ok || got.UserID != userID {
+		t.Errorf("PrincipalFromContext() = %v, %v", got, ok)
+	}
+	if _, ok := PrincipalFromContext(context.Background()); ok {
+		t.Errorf("PrincipalFromContext() found a principal in an empty context")
+	}
+}
@@ -0,0 +1,41 @@
+// Package blob keeps uploaded files like avatars and chirp images,
+// on the local disk or in an S3 compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for a key that was never put or was deleted
+var ErrNotFound = errors.New("blob: not found")
+
+// ErrInvalidKey is returned for keys that are not a clean relative path
+var ErrInvalidKey = errors.New("blob: invalid key")
+
+// Info describes a stored blob
+type Info struct {
+	ContentType string
+	Size        int64
+}
+
+// BlobStore stores blobs under keys like "media/<id>.jpg". Keys are never reused,
+// putting a key again replaces the blob.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the content of the blob, the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete removes the blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could leave the directory or bucket prefix, e.g. "../x"
+func checkKey(key string) error {
+	if !fs.ValidPath(key) || key == "." || strings.Contains(key, `\`) {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -7,29 +7,47 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	PublishAt      sql.NullTime
+	ContentWarning sql.NullString
+	Sensitive      bool
+	Visibility     string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.PublishAt,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +55,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PublishAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
 	)
 	return i, err
 }
@@ -51,8 +73,28 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1
+AND user_id = $2
+AND publish_at IS NOT NULL
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirps
 WHERE id = $1
 `
 
@@ -65,12 +107,16 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PublishAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getChirpList = `-- name: GetChirpList :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirps
 ORDER BY created_at ASC
 `
 
@@ -89,6 +135,10 @@ func (q *Queries) GetChirpList(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PublishAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -104,7 +154,7 @@ func (q *Queries) GetChirpList(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC
 `
@@ -124,6 +174,49 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PublishAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirps
+WHERE id = ANY($1::uuid[])
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PublishAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +230,247 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getScheduledChirps = `-- name: GetScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirps
+WHERE user_id = $1
+AND publish_at IS NOT NULL
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PublishAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const postChirp = `-- name: PostChirp :one
+WITH available AS (
+    -- the uploads stay locked until the chirp holding them is stored
+    SELECT id FROM media
+    WHERE id = ANY($1::uuid[])
+    AND user_id = $2::uuid
+    AND chirp_id IS NULL
+    FOR UPDATE
+), chirp AS (
+    INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility)
+    SELECT
+        gen_random_uuid(),
+        NOW(),
+        NOW(),
+        $3::text,
+        $2::uuid,
+        $4::timestamp,
+        $5::text,
+        $6::boolean,
+        $7::text
+    -- nothing is stored if one of the uploads is missing or attached to another chirp
+    WHERE (SELECT COUNT(*) FROM available) = COALESCE(CARDINALITY($1::uuid[]), 0)
+    RETURNING *
+), attached AS (
+    -- the uploads are stored at their position in the list, counting from 0
+    UPDATE media
+    SET chirp_id = chirp.id,
+    position = (ARRAY_POSITION($1::uuid[], media.id) - 1)::integer
+    FROM chirp
+    WHERE media.id IN (SELECT id FROM available)
+), poll AS (
+    INSERT INTO polls (chirp_id, created_at, closes_at, multiple_choice)
+    SELECT chirp.id, NOW(), $8::timestamp, $9::boolean
+    FROM chirp
+    WHERE $8::timestamp IS NOT NULL
+    RETURNING chirp_id
+), options AS (
+    -- the options are stored at their position in the list, counting from 0
+    INSERT INTO poll_options (chirp_id, position, text)
+    SELECT poll.chirp_id, (o.n - 1)::integer, o.text
+    FROM poll, UNNEST($10::text[]) WITH ORDINALITY AS o(text, n)
+)
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirp
+`
+
+type PostChirpParams struct {
+	MediaIds           []uuid.UUID
+	UserID             uuid.UUID
+	Body               string
+	PublishAt          sql.NullTime
+	ContentWarning     sql.NullString
+	Sensitive          bool
+	Visibility         string
+	PollClosesAt       sql.NullTime
+	PollMultipleChoice bool
+	PollOptions        []string
+}
+
+func (q *Queries) PostChirp(ctx context.Context, arg PostChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, postChirp,
+		pq.Array(arg.MediaIds),
+		arg.UserID,
+		arg.Body,
+		arg.PublishAt,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.Visibility,
+		arg.PollClosesAt,
+		arg.PollMultipleChoice,
+		pq.Array(arg.PollOptions),
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps
+SET publish_at = NULL,
+created_at = NOW(),
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE publish_at <= $1::timestamp
+    ORDER BY publish_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility
+`
+
+type PublishDueChirpsParams struct {
+	Now      time.Time
+	MaxCount int32
+}
+
+func (q *Queries) PublishDueChirps(ctx context.Context, arg PublishDueChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, arg.Now, arg.MaxCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PublishAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishScheduledChirp = `-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET publish_at = NULL,
+created_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND publish_at IS NOT NULL
+RETURNING id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility
+`
+
+type PublishScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) PublishScheduledChirp(ctx context.Context, arg PublishScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishScheduledChirp, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const setChirpWarning = `-- name: SetChirpWarning :one
+UPDATE chirps
+SET updated_at = NOW(),
+content_warning = $2,
+sensitive = $3
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility
+`
+
+type SetChirpWarningParams struct {
+	ID             uuid.UUID
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+func (q *Queries) SetChirpWarning(ctx context.Context, arg SetChirpWarningParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, setChirpWarning, arg.ID, arg.ContentWarning, arg.Sensitive)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PublishAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
@@ -0,0 +1,161 @@
+// Package events delivers events like a new chirp to everyone listening for them,
+// e.g. the live chirp stream.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	ChirpCreated   Type = "chirp.created"
+	ChirpDeleted   Type = "chirp.deleted"
+	UserUpgraded   Type = "user.upgraded"
+	SessionRevoked Type = "session.revoked"
+	// the UserID of a notification is the user it is for
+	NotificationCreated Type = "notification.created"
+	// the UserID of message events is the member they are sent to
+	MessageCreated   Type = "message.created"
+	ConversationRead Type = "conversation.read"
+	// the UserID of block, mute and follow events is the user who blocked, muted or followed someone
+	UserBlocked    Type = "user.blocked"
+	UserUnblocked  Type = "user.unblocked"
+	UserMuted      Type = "user.muted"
+	UserUnmuted    Type = "user.unmuted"
+	UserFollowed   Type = "user.followed"
+	UserUnfollowed Type = "user.unfollowed"
+)
+
+type Event struct {
+	// ID is set by the bus and increases with every event, 0 means not published yet
+	ID   uint64
+	Type Type
+	// UserID is the user the event is about, e.g. the author of a chirp
+	UserID uuid.UUID
+	// Data is the JSON sent to clients, e.g. the chirp
+	Data json.RawMessage
+}
+
+// NewEvent encodes data as JSON for the event
+func NewEvent(typ Type, userID uuid.UUID, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("error encoding %s event: %w", typ, err)
+	}
+	return Event{Type: typ, UserID: userID, Data: raw}, nil
+}
+
+// Bus passes published events to its subscribers
+type Bus interface {
+	// Publish sends the event to the subscribers, its ID is set on delivery
+	Publish(ctx context.Context, e Event) error
+	// Subscribe starts receiving events. With an afterID other than 0 the kept events
+	// after it are received first, older ones are lost.
+	Subscribe(afterID uint64) *Subscription
+}
+
+// Local is a Bus for the subscribers in this process.
+// It keeps the latest events so a subscriber can resume where it stopped after a reconnect.
+// Publish never waits for subscribers: one that does not keep up is dropped and its channel closed.
+type Local struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event // oldest first
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+var _ Bus = (*Local)(nil)
+
+// NewLocal creates a bus that keeps historySize events for resuming and buffers bufferSize
+// events for each subscriber before dropping it
+func NewLocal(historySize, bufferSize int) *Local {
+	return &Local{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish gives the event the next ID and sends it to every subscriber
+func (b *Local) Publish(ctx context.Context, e Event) error {
+	b.deliver(e)
+	return nil
+}
+
+func (b *Local) deliver(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			// too slow, it can resume from the history with a new subscription
+			b.remove(sub)
+		}
+	}
+	return e
+}
+
+func (b *Local) Subscribe(afterID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	// an ID above lastID is from before a restart, nothing can be resumed
+	if afterID > 0 && afterID <= b.lastID {
+		for _, e := range b.history {
+			if e.ID > afterID {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	sub := &Subscription{bus: b, ch: make(chan Event, b.bufferSize+len(replay))}
+	for _, e := range replay {
+		sub.ch <- e
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// remove unregisters the subscription, the caller holds b.mu
+func (b *Local) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+type Subscription struct {
+	bus *Local
+	ch  chan Event
+}
+
+// Events returns the channel of events, it is closed after Close or when the subscriber fell behind
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close stops the subscription, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
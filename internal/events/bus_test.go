@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func receive(t *testing.T, sub *Subscription, n int) []Event {
+	t.Helper()
+	var got []Event
+	for range n {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("subscription closed after %d events, want %d", len(got), n)
+			}
+			got = append(got, e)
+		default:
+			t.Fatalf("got %d events, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestLocalPublish(t *testing.T) {
+	b := NewLocal(10, 10)
+	first := b.Subscribe(0)
+	second := b.Subscribe(0)
+	userID := uuid.New()
+
+	e, err := NewEvent(ChirpCreated, userID, map[string]string{"body": "hello"})
+	if err != nil {
+		t.Fatalf("NewEvent() error = %v", err)
+	}
+	err = b.Publish(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	for _, sub := range []*Subscription{first, second} {
+		got := receive(t, sub, 1)[0]
+		if got.ID != 1 || got.Type != ChirpCreated || got.UserID != userID || string(got.Data) != `{"body":"hello"}` {
+			t.Errorf("received %+v", got)
+		}
+	}
+
+	second.Close()
+	second.Close()
+	if _, ok := <-second.Events(); ok {
+		t.Errorf("closed subscription still receives events")
+	}
+	b.deliver(Event{Type: ChirpDeleted})
+	if got := receive(t, first, 1)[0]; got.ID != 2 {
+		t.Errorf("ID = %d, want 2", got.ID)
+	}
+}
+
+func TestLocalResume(t *testing.T) {
+	b := NewLocal(3, 10)
+	for range 5 {
+		b.deliver(Event{Type: ChirpCreated})
+	}
+
+	tests := []struct {
+		name    string
+		afterID uint64
+		wantIDs []uint64
+	}{
+		{"new subscriber", 0, nil},
+		{"within history", 3, []uint64{4, 5}},
+		{"before history", 1, []uint64{3, 4, 5}},
+		{"up to date", 5, nil},
+		{"from before a restart", 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := b.Subscribe(tt.afterID)
+			defer sub.Close()
+			got := receive(t, sub, len(tt.wantIDs))
+			for i, e := range got {
+				if e.ID != tt.wantIDs[i] {
+					t.Errorf("event %d has ID %d, want %d", i, e.ID, tt.wantIDs[i])
+				}
+			}
+			if len(sub.Events()) != 0 {
+				t.Errorf("%d more events than expected", len(sub.Events()))
+			}
+		})
+	}
+}
+
+func TestLocalDropsSlowSubscriber(t *testing.T) {
+	b := NewLocal(10, 2)
+	slow := b.Subscribe(0)
+	fast := b.Subscribe(0)
+
+	for range 3 {
+		b.deliver(Event{Type: ChirpCreated})
+		receive(t, fast, 1)
+	}
+
+	receive(t, slow, 2)
+	if _, ok := <-slow.Events(); ok {
+		t.Errorf("slow subscription was not closed")
+	}
+	slow.Close()
+
+	// the dropped subscriber resumes after the last event it got
+	resumed := b.Subscribe(2)
+	defer resumed.Close()
+	if got := receive(t, resumed, 1)[0]; got.ID != 3 {
+		t.Errorf("resumed at ID %d, want 3", got.ID)
+	}
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PostgresChannel is the channel events are sent on with NOTIFY
+const PostgresChannel = "chirpy_events"
+
+// Postgres is a Bus for several server processes on the same database.
+// Events are published with NOTIFY, every process LISTENs for them and passes them on
+// to its own subscribers, including the process that published them.
+// Event IDs are given by each process, so a subscriber can only resume on the same process.
+type Postgres struct {
+	local    *Local
+	db       *sql.DB
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+var _ Bus = (*Postgres)(nil)
+
+// maxNotifyPayload is the size Postgres limits NOTIFY payloads to, they have to be shorter
+const maxNotifyPayload = 8000
+
+// payloadLifetime is how long the payloads of large events are kept for the listeners to read
+const payloadLifetime = time.Minute
+
+// loadTimeout bounds reading the payload of a large event, the listener waits for it
+const loadTimeout = 5 * time.Second
+
+// notification is the NOTIFY payload. Events that do not fit are stored in the event_payloads
+// table and only their PayloadID is sent.
+type notification struct {
+	Type      Type            `json:"type,omitempty"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	PayloadID *uuid.UUID      `json:"payload_id,omitempty"`
+}
+
+// encodeNotification returns the payload of the event and whether it can be sent with NOTIFY
+func encodeNotification(e Event) ([]byte, bool, error) {
+	payload, err := json.Marshal(notification{Type: e.Type, UserID: e.UserID, Data: e.Data})
+	if err != nil {
+		return nil, false, fmt.Errorf("error encoding %s event: %w", e.Type, err)
+	}
+	return payload, len(payload) < maxNotifyPayload, nil
+}
+
+// NewPostgres listens for events on the database at dbURL and delivers them to the subscribers of local
+func NewPostgres(dbURL string, local *Local) (*Postgres, error) {
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the database: %w", err)
+	}
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v", err)
+		}
+	})
+	err = listener.Listen(PostgresChannel)
+	if err != nil {
+		listener.Close()
+		db.Close()
+		return nil, fmt.Errorf("error listening for events: %w", err)
+	}
+
+	p := &Postgres{
+		local:    local,
+		db:       db,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go p.listen()
+	return p, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, e Event) error {
+	payload, fits, err := encodeNotification(e)
+	if err != nil {
+		return err
+	}
+	if !fits {
+		payload, err = p.storePayload(ctx, e, payload)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", PostgresChannel, string(payload))
+	if err != nil {
+		return fmt.Errorf("error publishing %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// storePayload keeps the payload of an event that is too large for NOTIFY and returns
+// the notification pointing to it, payloads that were read long ago are deleted on the way
+func (p *Postgres) storePayload(ctx context.Context, e Event, payload []byte) ([]byte, error) {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM event_payloads WHERE created_at < $1", time.Now().UTC().Add(-payloadLifetime))
+	if err != nil {
+		return nil, fmt.Errorf("error deleting old event payloads: %w", err)
+	}
+	id := uuid.New()
+	_, err = p.db.ExecContext(ctx, "INSERT INTO event_payloads (id, payload, created_at) VALUES ($1, $2, $3)", id, string(payload), time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error storing %s event: %w", e.Type, err)
+	}
+	return json.Marshal(notification{UserID: e.UserID, PayloadID: &id})
+}
+
+// loadPayload reads the notification of a large event from the event_payloads table
+func (p *Postgres) loadPayload(id uuid.UUID) (notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	var payload string
+	err := p.db.QueryRowContext(ctx, "SELECT payload FROM event_payloads WHERE id = $1", id).Scan(&payload)
+	if err != nil {
+		return notification{}, fmt.Errorf("error reading event payload %s: %w", id, err)
+	}
+	var msg notification
+	err = json.Unmarshal([]byte(payload), &msg)
+	return msg, err
+}
+
+func (p *Postgres) Subscribe(afterID uint64) *Subscription {
+	return p.local.Subscribe(afterID)
+}
+
+// Close stops listening, subscribers get no more events
+func (p *Postgres) Close() error {
+	close(p.done)
+	err := p.listener.Close()
+	p.db.Close()
+	return err
+}
+
+func (p *Postgres) listen() {
+	// the listener only notices a broken connection when it is used
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ping.C:
+			go p.listener.Ping()
+		case n, ok := <-p.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				log.Println("event listener reconnected, events sent in between are lost")
+				continue
+			}
+			var msg notification
+			err := json.Unmarshal([]byte(n.Extra), &msg)
+			if err != nil {
+				log.Printf("error decoding event %q: %v", n.Extra, err)
+				continue
+			}
+			if msg.PayloadID != nil {
+				msg, err = p.loadPayload(*msg.PayloadID)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			}
+			p.local.deliver(Event{Type: msg.Type, UserID: msg.UserID, Data: msg.Data})
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestPostgres starts n server processes on the database in TEST_DB_URL
+func newTestPostgres(t *testing.T, n int) ([]*Postgres, []*Subscription) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	var subs []*Subscription
+	var buses []*Postgres
+	for range n {
+		p, err := NewPostgres(dbURL, NewLocal(10, 10))
+		if err != nil {
+			t.Fatalf("NewPostgres() error = %v", err)
+		}
+		t.Cleanup(func() { p.Close() })
+		buses = append(buses, p)
+		subs = append(subs, p.Subscribe(0))
+	}
+	return buses, subs
+}
+
+// expectFanOut checks that every subscriber received e
+func expectFanOut(t *testing.T, subs []*Subscription, e Event) {
+	for i, sub := range subs {
+		select {
+		case got := <-sub.Events():
+			if got.Type != e.Type || got.UserID != e.UserID || string(got.Data) != string(e.Data) || got.ID == 0 {
+				t.Errorf("process %d received %+v", i, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("process %d received no event", i)
+		}
+	}
+}
+
+// TestPostgresFanOut needs a Postgres database in TEST_DB_URL
+func TestPostgresFanOut(t *testing.T) {
+	// two server processes on the same database
+	buses, subs := newTestPostgres(t, 2)
+
+	userID := uuid.New()
+	e, err := NewEvent(UserUpgraded, userID, map[string]string{"id": userID.String()})
+	if err != nil {
+		t.Fatalf("NewEvent() error = %v", err)
+	}
+	err = buses[0].Publish(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	expectFanOut(t, subs, e)
+}
+
+// TestPostgresLargeEvent needs a Postgres database in TEST_DB_URL
+func TestPostgresLargeEvent(t *testing.T) {
+	buses, subs := newTestPostgres(t, 2)
+
+	userID := uuid.New()
+	e, err := NewEvent(ChirpCreated, userID, map[string]string{"body": strings.Repeat("a", maxNotifyPayload)})
+	if err != nil {
+		t.Fatalf("NewEvent() error = %v", err)
+	}
+	err = buses[0].Publish(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	expectFanOut(t, subs, e)
+}
+
+func TestEncodeNotification(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name     string
+		body     string
+		wantFits bool
+	}{
+		{"small", "hello", true},
+		{"too large", strings.Repeat("a", maxNotifyPayload), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEvent(ChirpCreated, userID, map[string]string{"body": tt.body})
+			if err != nil {
+				t.Fatalf("NewEvent() error = %v", err)
+			}
+			payload, fits, err := encodeNotification(e)
+			if err != nil {
+				t.Fatalf("encodeNotification() error = %v", err)
+			}
+			if fits != tt.wantFits {
+				t.Errorf("encodeNotification() fits = %v for %d bytes, want %v", fits, len(payload), tt.wantFits)
+			}
+			var got notification
+			err = json.Unmarshal(payload, &got)
+			if err != nil {
+				t.Fatalf("payload is not JSON: %v", err)
+			}
+			if got.Type != e.Type || got.UserID != userID || string(got.Data) != string(e.Data) || got.PayloadID != nil {
+				t.Errorf("payload = %+v, want the event", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package media turns uploaded images into what is stored and served:
+// the type is sniffed, the size checked, metadata like EXIF is stripped and a thumbnail is made.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("media: only JPEG, PNG and GIF images are supported")
+	ErrInvalidImage    = errors.New("media: not a valid image")
+	ErrTooManyPixels   = errors.New("media: image has too many pixels")
+)
+
+const jpegQuality = 85
+
+// Options says what Process makes of an upload
+type Options struct {
+	// MaxPixels rejects larger images before they are decoded
+	MaxPixels int
+	// MaxSide is the longer side of the stored image, larger images are scaled down
+	MaxSide int
+	// Square crops the image to its centered square, e.g. for avatars
+	Square bool
+	// ThumbnailSide is the longer side of the thumbnail, 0 makes none
+	ThumbnailSide int
+}
+
+// Image is a processed upload, it contains no metadata of the original file
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	// Thumbnail is nil if no thumbnail was asked for
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Ext returns the file extension for the content type, e.g. ".png"
+func Ext(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
+
+// Process checks and re-encodes an uploaded image. Everything is decoded and
+// encoded again, which drops all metadata; the EXIF orientation of JPEGs is applied first.
+func Process(data []byte, opts Options) (Image, error) {
+	contentType := http.DetectContentType(data)
+	if Ext(contentType) == "" {
+		return Image{}, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	if config.Width*config.Height > opts.MaxPixels {
+		return Image{}, ErrTooManyPixels
+	}
+
+	// animated GIFs keep their frames as long as they need no scaling
+	if contentType == "image/gif" && !opts.Square && max(config.Width, config.Height) <= opts.MaxSide {
+		return processGIF(data, opts)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	rgba := toRGBA(img)
+	if contentType == "image/jpeg" {
+		rgba = orient(rgba, exifOrientation(data))
+	}
+	if opts.Square {
+		rgba = cropSquare(rgba)
+	}
+	w, h := fit(rgba.Bounds().Dx(), rgba.Bounds().Dy(), opts.MaxSide)
+	rgba = resize(rgba, w, h)
+
+	// a scaled GIF is only its first frame and becomes a PNG
+	if contentType == "image/gif" {
+		contentType = "image/png"
+	}
+	result := Image{ContentType: contentType, Width: w, Height: h}
+	result.Data, err = encode(rgba, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+	if opts.ThumbnailSide > 0 {
+		tw, th := fit(w, h, opts.ThumbnailSide)
+		result.ThumbnailContentType = contentType
+		result.Thumbnail, err = encode(resize(rgba, tw, th), contentType)
+		if err != nil {
+			return Image{}, err
+		}
+	}
+	return result, nil
+}
+
+func processGIF(data []byte, opts Options) (Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	var buf bytes.Buffer
+	err = gif.EncodeAll(&buf, g)
+	if err != nil {
+		return Image{}, err
+	}
+	result := Image{
+		ContentType: "image/gif",
+		Data:        buf.Bytes(),
+		Width:       g.Config.Width,
+		Height:      g.Config.Height,
+	}
+	if opts.ThumbnailSide > 0 {
+		// the first frame drawn on the full canvas
+		canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+		draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+		tw, th := fit(g.Config.Width, g.Config.Height, opts.ThumbnailSide)
+		result.ThumbnailContentType = "image/png"
+		result.Thumbnail, err = encode(resize(canvas, tw, th), "image/png")
+		if err != nil {
+			return Image{}, err
+		}
+	}
+	return result, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// fit scales w x h down to fit into a square of side maxSide, keeping the aspect ratio
+func fit(w, h, maxSide int) (int, int) {
+	if w <= maxSide && h <= maxSide {
+		return w, h
+	}
+	if w >= h {
+		return maxSide, max(1, h*maxSide/w)
+	}
+	return max(1, w*maxSide/h), maxSide
+}
+
+func cropSquare(src *image.RGBA) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	side := min(w, h)
+	x0, y0 := (w-side)/2, (h-side)/2
+	return toRGBA(src.SubImage(image.Rect(x0, y0, x0+side, y0+side)))
+}
+
+// resize scales down by averaging the source pixels that fall into each target pixel
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == w && sh == h {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range 4 {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range 4 {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,160 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var testOptions = Options{MaxPixels: 1 << 20, MaxSide: 64, ThumbnailSide: 16}
+
+// halves returns a w x h image, red on the left and blue on the right
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// withExif inserts an EXIF segment with the orientation and a private note after the start of the JPEG
+func withExif(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1) // entries
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1) // count
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // padding, next IFD
+	tiff = append(tiff, "GPS 52.52N 13.40E"...)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessJPEGStripsExifAndTurnsUpright(t *testing.T) {
+	upload := withExif(t, encodeJPEG(t, halves(40, 20)), 6)
+	if exifOrientation(upload) != 6 {
+		t.Fatalf("exifOrientation() = %d, want 6", exifOrientation(upload))
+	}
+
+	got, err := Process(upload, testOptions)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if bytes.Contains(got.Data, []byte("Exif")) || bytes.Contains(got.Data, []byte("GPS")) {
+		t.Errorf("Process() kept the EXIF data")
+	}
+	if got.ContentType != "image/jpeg" || got.Width != 20 || got.Height != 40 {
+		t.Errorf("Process() = %s %dx%d, want image/jpeg 20x40", got.ContentType, got.Width, got.Height)
+	}
+
+	// turned clockwise, the red left half is on top now
+	img, err := jpeg.Decode(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("Decoding the result: %v", err)
+	}
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Errorf("top of the upright image is not red")
+	}
+}
+
+func TestProcessScalesAndMakesThumbnails(t *testing.T) {
+	got, err := Process(encodePNG(t, halves(200, 100)), testOptions)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if got.ContentType != "image/png" || got.Width != 64 || got.Height != 32 {
+		t.Errorf("Process() = %s %dx%d, want image/png 64x32", got.ContentType, got.Width, got.Height)
+	}
+	thumb, err := png.DecodeConfig(bytes.NewReader(got.Thumbnail))
+	if err != nil || thumb.Width != 16 || thumb.Height != 8 {
+		t.Errorf("thumbnail = %+v, %v, want 16x8", thumb, err)
+	}
+
+	square := testOptions
+	square.Square = true
+	square.ThumbnailSide = 0
+	got, err = Process(encodePNG(t, halves(200, 100)), square)
+	if err != nil || got.Width != 64 || got.Height != 64 || got.Thumbnail != nil {
+		t.Errorf("Process() square = %dx%d thumbnail %v, %v", got.Width, got.Height, got.Thumbnail != nil, err)
+	}
+}
+
+func TestProcessKeepsAnimatedGIFs(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for range 3 {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 10, 10), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Process(buf.Bytes(), testOptions)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(got.Data))
+	if err != nil || len(decoded.Image) != 3 || got.ThumbnailContentType != "image/png" {
+		t.Errorf("Process() GIF = %d frames, thumbnail %s, %v", len(decoded.Image), got.ThumbnailContentType, err)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		opts Options
+		want error
+	}{
+		{"Text", []byte("hello, not an image"), testOptions, ErrUnsupportedType},
+		{"Broken PNG", []byte("\x89PNG\r\n\x1a\nbroken"), testOptions, ErrInvalidImage},
+		{"Too many pixels", encodePNG(t, halves(20, 20)), Options{MaxPixels: 399, MaxSide: 64}, ErrTooManyPixels},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(tt.data, tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Process() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes of violated constraints, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+	CheckViolation      = "23514"
+)
+
+// ConstraintError is a violated constraint, Code is the matching Postgres error code
+type ConstraintError struct {
+	Code       string
+	Constraint string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("constraint %s violated (%s)", e.Constraint, e.Code)
+}
+
+// AsConstraintError finds a violated constraint in err, no matter which store returned it
+func AsConstraintError(err error) (*ConstraintError, bool) {
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return constraintErr, true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "23" {
+		return &ConstraintError{Code: string(pqErr.Code), Constraint: pqErr.Constraint}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,1805 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// refreshTokenLifetime matches "NOW() + interval '60 days'" in sql/queries/tokens.sql
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// Memory is a Store that keeps everything in memory, it is lost when the process ends.
+// It follows the Postgres schema: missing rows return sql.ErrNoRows, deleting a user
+// deletes everything that references them, and violated constraints return a *ConstraintError.
+type Memory struct {
+	mu                      sync.RWMutex
+	users                   map[uuid.UUID]database.User
+	chirps                  []database.Chirp // in order of creation
+	refreshTokens           map[string]database.RefreshToken
+	notifications           []database.Notification // in order of creation
+	notificationPreferences map[uuid.UUID]map[string]database.NotificationPreference
+	conversations           []database.Conversation // in order of creation
+	conversationMembers     []database.ConversationMember
+	messages                []database.Message // in order of creation
+	blocks                  map[uuid.UUID]map[uuid.UUID]database.UserBlock
+	mutes                   map[uuid.UUID]map[uuid.UUID]database.UserMute
+	follows                 map[uuid.UUID]map[uuid.UUID]database.UserFollow
+	media                   []database.Medium // in order of creation
+	drafts                  []database.Draft  // in order of creation
+	polls                   []database.Poll
+	pollOptions             []database.PollOption
+	pollVotes               []database.PollVote
+	collections             []database.Collection // in order of creation
+	bookmarks               []database.Bookmark   // in order of creation
+	pins                    []database.Pin        // in order of creation
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:                   map[uuid.UUID]database.User{},
+		refreshTokens:           map[string]database.RefreshToken{},
+		notificationPreferences: map[uuid.UUID]map[string]database.NotificationPreference{},
+		blocks:                  map[uuid.UUID]map[uuid.UUID]database.UserBlock{},
+		mutes:                   map[uuid.UUID]map[uuid.UUID]database.UserMute{},
+		follows:                 map[uuid.UUID]map[uuid.UUID]database.UserFollow{},
+	}
+}
+
+// now returns the time like Postgres stores it in a TIMESTAMP column
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func getValidRoles() []string {
+	return []string{"user", "moderator", "admin"}
+}
+
+func getValidSensitiveContent() []string {
+	return []string{"collapse", "expand", "hide"}
+}
+
+func getValidVisibilities() []string {
+	return []string{"public", "unlisted", "followers"}
+}
+
+// users
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, &ConstraintError{Code: UniqueViolation, Constraint: "users_email_key"}
+	}
+	t := now()
+	user := database.User{
+		ID:               uuid.New(),
+		CreatedAt:        t,
+		UpdatedAt:        t,
+		Email:            arg.Email,
+		HashedPassword:   arg.HashedPassword,
+		IsChirpyRed:      false,
+		Role:             "user",
+		SensitiveContent: "collapse",
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+// emailTaken reports whether a user other than except already has the email
+func (m *Memory) emailTaken(email string, except uuid.UUID) bool {
+	for _, u := range m.users {
+		if u.Email == email && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTaken reports whether a user other than except already has the handle, ignoring case
+func (m *Memory) handleTaken(handle string, except uuid.UUID) bool {
+	for _, u := range m.users {
+		if u.Handle.Valid && strings.EqualFold(u.Handle.String, handle) && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) DeleteAllUsers(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = map[uuid.UUID]database.User{}
+	m.chirps = nil
+	m.refreshTokens = map[string]database.RefreshToken{}
+	m.notifications = nil
+	m.notificationPreferences = map[uuid.UUID]map[string]database.NotificationPreference{}
+	// conversations do not reference users and stay, like in Postgres
+	m.conversationMembers = nil
+	m.messages = nil
+	m.blocks = map[uuid.UUID]map[uuid.UUID]database.UserBlock{}
+	m.mutes = map[uuid.UUID]map[uuid.UUID]database.UserMute{}
+	m.follows = map[uuid.UUID]map[uuid.UUID]database.UserFollow{}
+	m.media = nil
+	m.drafts = nil
+	m.polls = nil
+	m.pollOptions = nil
+	m.pollVotes = nil
+	m.collections = nil
+	m.bookmarks = nil
+	m.pins = nil
+	return nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.users, id)
+	m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool {
+		if c.UserID == id {
+			m.deleteChirpReferences(c.ID)
+			return true
+		}
+		return false
+	})
+	for token, rt := range m.refreshTokens {
+		if rt.UserID == id {
+			delete(m.refreshTokens, token)
+		}
+	}
+	m.notifications = slices.DeleteFunc(m.notifications, func(n database.Notification) bool {
+		return n.UserID == id || (n.ActorID.Valid && n.ActorID.UUID == id)
+	})
+	delete(m.notificationPreferences, id)
+	m.conversationMembers = slices.DeleteFunc(m.conversationMembers, func(cm database.ConversationMember) bool {
+		return cm.UserID == id
+	})
+	m.messages = slices.DeleteFunc(m.messages, func(msg database.Message) bool {
+		return msg.SenderID == id
+	})
+	delete(m.blocks, id)
+	for _, blocked := range m.blocks {
+		delete(blocked, id)
+	}
+	delete(m.mutes, id)
+	for _, muted := range m.mutes {
+		delete(muted, id)
+	}
+	delete(m.follows, id)
+	for _, followed := range m.follows {
+		delete(followed, id)
+	}
+	m.media = slices.DeleteFunc(m.media, func(md database.Medium) bool {
+		return md.UserID == id
+	})
+	m.drafts = slices.DeleteFunc(m.drafts, func(d database.Draft) bool {
+		return d.UserID == id
+	})
+	m.pollVotes = slices.DeleteFunc(m.pollVotes, func(v database.PollVote) bool {
+		return v.UserID == id
+	})
+	m.bookmarks = slices.DeleteFunc(m.bookmarks, func(b database.Bookmark) bool {
+		return b.UserID == id
+	})
+	m.collections = slices.DeleteFunc(m.collections, func(c database.Collection) bool {
+		return c.UserID == id
+	})
+	m.pins = slices.DeleteFunc(m.pins, func(p database.Pin) bool {
+		return p.UserID == id
+	})
+	return nil
+}
+
+// updateUser applies change to the user with id, like an UPDATE it does nothing if there is no such user
+func (m *Memory) updateUser(id uuid.UUID, change func(u *database.User) error) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	err := change(&user)
+	if err != nil {
+		return database.User{}, err
+	}
+	user.UpdatedAt = now()
+	m.users[id] = user
+	return user, nil
+}
+
+func (m *Memory) DowngradeUserFromRed(ctx context.Context, id uuid.UUID) error {
+	_, err := m.updateUser(id, func(u *database.User) error {
+		u.IsChirpyRed = false
+		return nil
+	})
+	return ignoreNoRows(err)
+}
+
+func (m *Memory) GetUserByEMail(ctx context.Context, email string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByHandle(ctx context.Context, handle string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Handle.Valid && strings.EqualFold(u.Handle.String, handle) {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *Memory) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		if !slices.Contains(getValidRoles(), arg.Role) {
+			return &ConstraintError{Code: CheckViolation, Constraint: "users_role_check"}
+		}
+		u.Role = arg.Role
+		return nil
+	})
+}
+
+func (m *Memory) UpdateUserCredentials(ctx context.Context, arg database.UpdateUserCredentialsParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		if m.emailTaken(arg.Email, u.ID) {
+			return &ConstraintError{Code: UniqueViolation, Constraint: "users_email_key"}
+		}
+		u.Email = arg.Email
+		u.HashedPassword = arg.HashedPassword
+		return nil
+	})
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		u.HashedPassword = arg.HashedPassword
+		return nil
+	})
+}
+
+func (m *Memory) UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		if arg.Handle.Valid && m.handleTaken(arg.Handle.String, u.ID) {
+			return &ConstraintError{Code: UniqueViolation, Constraint: "users_handle_key"}
+		}
+		u.Handle = arg.Handle
+		u.DisplayName = arg.DisplayName
+		u.Bio = arg.Bio
+		u.AvatarUrl = arg.AvatarUrl
+		return nil
+	})
+}
+
+func (m *Memory) UpdateUserSensitiveContent(ctx context.Context, arg database.UpdateUserSensitiveContentParams) (database.User, error) {
+	return m.updateUser(arg.ID, func(u *database.User) error {
+		if !slices.Contains(getValidSensitiveContent(), arg.SensitiveContent) {
+			return &ConstraintError{Code: CheckViolation, Constraint: "users_sensitive_content_check"}
+		}
+		u.SensitiveContent = arg.SensitiveContent
+		return nil
+	})
+}
+
+func (m *Memory) UpgradeUserToRed(ctx context.Context, id uuid.UUID) error {
+	_, err := m.updateUser(id, func(u *database.User) error {
+		u.IsChirpyRed = true
+		return nil
+	})
+	return ignoreNoRows(err)
+}
+
+// ignoreNoRows is for :exec queries, which do not fail when no row matched
+func ignoreNoRows(err error) error {
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// chirps
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertChirp(arg)
+}
+
+// insertChirp stores a new chirp, the caller holds m.mu
+func (m *Memory) insertChirp(arg database.CreateChirpParams) (database.Chirp, error) {
+	if !slices.Contains(getValidVisibilities(), arg.Visibility) {
+		return database.Chirp{}, &ConstraintError{Code: CheckViolation, Constraint: "chirps_visibility_check"}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Chirp{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "chirps_user_id_fkey"}
+	}
+	t := now()
+	chirp := database.Chirp{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Body:           arg.Body,
+		UserID:         arg.UserID,
+		PublishAt:      arg.PublishAt,
+		ContentWarning: arg.ContentWarning,
+		Sensitive:      arg.Sensitive,
+		Visibility:     arg.Visibility,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool {
+		return c.ID == id
+	})
+	m.deleteChirpReferences(id)
+	return nil
+}
+
+// deleteChirpReferences deletes the rows that reference the chirp, the caller holds m.mu
+func (m *Memory) deleteChirpReferences(chirpID uuid.UUID) {
+	m.notifications = slices.DeleteFunc(m.notifications, func(n database.Notification) bool {
+		return n.ChirpID.Valid && n.ChirpID.UUID == chirpID
+	})
+	m.media = slices.DeleteFunc(m.media, func(md database.Medium) bool {
+		return md.ChirpID.Valid && md.ChirpID.UUID == chirpID
+	})
+	m.polls = slices.DeleteFunc(m.polls, func(p database.Poll) bool {
+		return p.ChirpID == chirpID
+	})
+	m.pollOptions = slices.DeleteFunc(m.pollOptions, func(o database.PollOption) bool {
+		return o.ChirpID == chirpID
+	})
+	m.pollVotes = slices.DeleteFunc(m.pollVotes, func(v database.PollVote) bool {
+		return v.ChirpID == chirpID
+	})
+	m.bookmarks = slices.DeleteFunc(m.bookmarks, func(b database.Bookmark) bool {
+		return b.ChirpID == chirpID
+	})
+	m.pins = slices.DeleteFunc(m.pins, func(p database.Pin) bool {
+		return p.ChirpID == chirpID
+	})
+}
+
+// chirpExists reports whether there is a chirp with the id, the caller holds m.mu
+func (m *Memory) chirpExists(id uuid.UUID) bool {
+	return slices.ContainsFunc(m.chirps, func(c database.Chirp) bool {
+		return c.ID == id
+	})
+}
+
+func (m *Memory) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, c := range m.chirps {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetChirpList(ctx context.Context) ([]database.Chirp, error) {
+	return m.filterChirps(func(c database.Chirp) bool {
+		return true
+	}), nil
+}
+
+func (m *Memory) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return m.filterChirps(func(c database.Chirp) bool {
+		return c.UserID == userID
+	}), nil
+}
+
+func (m *Memory) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Chirp, error) {
+	return m.filterChirps(func(c database.Chirp) bool {
+		return slices.Contains(ids, c.ID)
+	}), nil
+}
+
+// PostChirp stores the chirp with its uploads and poll, nothing is stored and sql.ErrNoRows
+// is returned when one of the uploads is missing or attached to a chirp already
+func (m *Memory) PostChirp(ctx context.Context, arg database.PostChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var media []int
+	for _, id := range arg.MediaIds {
+		i := slices.IndexFunc(m.media, func(md database.Medium) bool {
+			return md.ID == id && md.UserID == arg.UserID && !md.ChirpID.Valid
+		})
+		if i < 0 || slices.Contains(media, i) {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+		media = append(media, i)
+	}
+	chirp, err := m.insertChirp(database.CreateChirpParams{
+		Body:           arg.Body,
+		UserID:         arg.UserID,
+		PublishAt:      arg.PublishAt,
+		ContentWarning: arg.ContentWarning,
+		Sensitive:      arg.Sensitive,
+		Visibility:     arg.Visibility,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	for position, i := range media {
+		m.media[i].ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		m.media[i].Position = int32(position)
+	}
+	if arg.PollClosesAt.Valid {
+		m.polls = append(m.polls, database.Poll{
+			ChirpID:        chirp.ID,
+			CreatedAt:      chirp.CreatedAt,
+			ClosesAt:       arg.PollClosesAt.Time,
+			MultipleChoice: arg.PollMultipleChoice,
+		})
+		for i, text := range arg.PollOptions {
+			m.pollOptions = append(m.pollOptions, database.PollOption{ChirpID: chirp.ID, Position: int32(i), Text: text})
+		}
+	}
+	return chirp, nil
+}
+
+func (m *Memory) SetChirpWarning(ctx context.Context, arg database.SetChirpWarningParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.chirps {
+		if c.ID == arg.ID {
+			m.chirps[i].ContentWarning = arg.ContentWarning
+			m.chirps[i].Sensitive = arg.Sensitive
+			m.chirps[i].UpdatedAt = now()
+			return m.chirps[i], nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+// filterChirps returns the matching chirps ordered by created_at, like the sqlc queries it returns nil for no rows
+func (m *Memory) filterChirps(match func(c database.Chirp) bool) []database.Chirp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.Chirp
+	for _, c := range m.chirps {
+		if match(c) {
+			items = append(items, c)
+		}
+	}
+	slices.SortStableFunc(items, func(a, b database.Chirp) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return items
+}
+
+// scheduled chirps
+
+func (m *Memory) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool {
+		if c.ID == arg.ID && c.UserID == arg.UserID && c.PublishAt.Valid {
+			n++
+			return true
+		}
+		return false
+	})
+	if n > 0 {
+		m.deleteChirpReferences(arg.ID)
+	}
+	return n, nil
+}
+
+func (m *Memory) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	items := m.filterChirps(func(c database.Chirp) bool {
+		return c.UserID == userID && c.PublishAt.Valid
+	})
+	slices.SortStableFunc(items, func(a, b database.Chirp) int {
+		return a.PublishAt.Time.Compare(b.PublishAt.Time)
+	})
+	return items, nil
+}
+
+func (m *Memory) PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []int
+	for i, c := range m.chirps {
+		if c.PublishAt.Valid && !c.PublishAt.Time.After(arg.Now) {
+			due = append(due, i)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b int) int {
+		return m.chirps[a].PublishAt.Time.Compare(m.chirps[b].PublishAt.Time)
+	})
+
+	var items []database.Chirp
+	t := now()
+	for _, i := range due[:min(len(due), int(arg.MaxCount))] {
+		m.chirps[i].PublishAt = sql.NullTime{}
+		m.chirps[i].CreatedAt = t
+		m.chirps[i].UpdatedAt = t
+		items = append(items, m.chirps[i])
+	}
+	return items, nil
+}
+
+func (m *Memory) PublishScheduledChirp(ctx context.Context, arg database.PublishScheduledChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.chirps {
+		if c.ID == arg.ID && c.UserID == arg.UserID && c.PublishAt.Valid {
+			t := now()
+			m.chirps[i].PublishAt = sql.NullTime{}
+			m.chirps[i].CreatedAt = t
+			m.chirps[i].UpdatedAt = t
+			return m.chirps[i], nil
+		}
+	}
+	return database.Chirp{}, sql.ErrNoRows
+}
+
+// drafts
+
+func (m *Memory) CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Draft{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "drafts_user_id_fkey"}
+	}
+	t := now()
+	draft := database.Draft{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+	}
+	m.drafts = append(m.drafts, draft)
+	return draft, nil
+}
+
+func (m *Memory) DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	m.drafts = slices.DeleteFunc(m.drafts, func(d database.Draft) bool {
+		if d.ID == arg.ID && d.UserID == arg.UserID {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, nil
+}
+
+func (m *Memory) GetDraftByID(ctx context.Context, arg database.GetDraftByIDParams) (database.Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.drafts {
+		if d.ID == arg.ID && d.UserID == arg.UserID {
+			return d, nil
+		}
+	}
+	return database.Draft{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetDraftsForUser(ctx context.Context, userID uuid.UUID) ([]database.Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.Draft
+	for _, d := range m.drafts {
+		if d.UserID == userID {
+			items = append(items, d)
+		}
+	}
+	// newest first, drafts updated at the same time by id like in Postgres
+	slices.SortFunc(items, func(a, b database.Draft) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	return items, nil
+}
+
+func (m *Memory) PublishDraft(ctx context.Context, arg database.PublishDraftParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.drafts, func(d database.Draft) bool {
+		return d.ID == arg.ID && d.UserID == arg.UserID && d.Body == arg.DraftBody
+	})
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	m.drafts = slices.Delete(m.drafts, i, i+1)
+	t := now()
+	chirp := database.Chirp{
+		ID:         uuid.New(),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		Body:       arg.Body,
+		UserID:     arg.UserID,
+		Visibility: "public",
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, d := range m.drafts {
+		if d.ID == arg.ID && d.UserID == arg.UserID {
+			m.drafts[i].Body = arg.Body
+			m.drafts[i].UpdatedAt = now()
+			return m.drafts[i], nil
+		}
+	}
+	return database.Draft{}, sql.ErrNoRows
+}
+
+// polls
+
+// pollExists reports whether the chirp has a poll, the caller holds m.mu
+func (m *Memory) pollExists(chirpID uuid.UUID) bool {
+	return slices.ContainsFunc(m.polls, func(p database.Poll) bool {
+		return p.ChirpID == chirpID
+	})
+}
+
+func (m *Memory) CreatePollVote(ctx context.Context, arg database.CreatePollVoteParams) (database.PollVote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.pollExists(arg.ChirpID) {
+		return database.PollVote{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "poll_votes_chirp_id_fkey"}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.PollVote{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "poll_votes_user_id_fkey"}
+	}
+	if slices.ContainsFunc(m.pollVotes, func(v database.PollVote) bool {
+		return v.ChirpID == arg.ChirpID && v.UserID == arg.UserID
+	}) {
+		return database.PollVote{}, &ConstraintError{Code: UniqueViolation, Constraint: "poll_votes_pkey"}
+	}
+	vote := database.PollVote{
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+		CreatedAt: now(),
+		Choices:   arg.Choices,
+	}
+	m.pollVotes = append(m.pollVotes, vote)
+	return vote, nil
+}
+
+func (m *Memory) GetPoll(ctx context.Context, chirpID uuid.UUID) (database.GetPollRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, p := range m.polls {
+		if p.ChirpID != chirpID {
+			continue
+		}
+		row := database.GetPollRow{
+			ChirpID:        p.ChirpID,
+			CreatedAt:      p.CreatedAt,
+			ClosesAt:       p.ClosesAt,
+			MultipleChoice: p.MultipleChoice,
+		}
+		for _, v := range m.pollVotes {
+			if v.ChirpID == chirpID {
+				row.VoterCount++
+			}
+		}
+		return row, nil
+	}
+	return database.GetPollRow{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetPollResults(ctx context.Context, chirpID uuid.UUID) ([]database.GetPollResultsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.GetPollResultsRow
+	for _, o := range m.pollOptions {
+		if o.ChirpID != chirpID {
+			continue
+		}
+		row := database.GetPollResultsRow{Position: o.Position, Text: o.Text}
+		for _, v := range m.pollVotes {
+			if v.ChirpID == chirpID && v.Choices&(1<<o.Position) != 0 {
+				row.Votes++
+			}
+		}
+		items = append(items, row)
+	}
+	slices.SortFunc(items, func(a, b database.GetPollResultsRow) int {
+		return int(a.Position - b.Position)
+	})
+	return items, nil
+}
+
+func (m *Memory) GetPollsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollsForChirpsRow, error) {
+	var items []database.GetPollsForChirpsRow
+	for _, id := range chirpIds {
+		p, err := m.GetPoll(ctx, id)
+		if err == nil {
+			items = append(items, database.GetPollsForChirpsRow(p))
+		}
+	}
+	return items, nil
+}
+
+func (m *Memory) GetPollResultsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollResultsForChirpsRow, error) {
+	var items []database.GetPollResultsForChirpsRow
+	for _, id := range chirpIds {
+		results, _ := m.GetPollResults(ctx, id)
+		for _, r := range results {
+			items = append(items, database.GetPollResultsForChirpsRow{ChirpID: id, Position: r.Position, Text: r.Text, Votes: r.Votes})
+		}
+	}
+	slices.SortStableFunc(items, func(a, b database.GetPollResultsForChirpsRow) int {
+		return bytes.Compare(a.ChirpID[:], b.ChirpID[:])
+	})
+	return items, nil
+}
+
+func (m *Memory) GetPollVotesForChirps(ctx context.Context, arg database.GetPollVotesForChirpsParams) ([]database.PollVote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.PollVote
+	for _, v := range m.pollVotes {
+		if v.UserID == arg.UserID && slices.Contains(arg.ChirpIds, v.ChirpID) {
+			items = append(items, v)
+		}
+	}
+	return items, nil
+}
+
+// bookmarks
+
+// collectionNameTaken reports whether the user has a collection other than except with the name, ignoring case
+func (m *Memory) collectionNameTaken(userID uuid.UUID, name string, except uuid.UUID) bool {
+	return slices.ContainsFunc(m.collections, func(c database.Collection) bool {
+		return c.UserID == userID && strings.EqualFold(c.Name, name) && c.ID != except
+	})
+}
+
+func (m *Memory) CreateCollection(ctx context.Context, arg database.CreateCollectionParams) (database.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Collection{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "collections_user_id_fkey"}
+	}
+	if m.collectionNameTaken(arg.UserID, arg.Name, uuid.Nil) {
+		return database.Collection{}, &ConstraintError{Code: UniqueViolation, Constraint: "collections_user_id_name_key"}
+	}
+	t := now()
+	collection := database.Collection{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+	}
+	m.collections = append(m.collections, collection)
+	return collection, nil
+}
+
+func (m *Memory) DeleteBookmark(ctx context.Context, arg database.DeleteBookmarkParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	m.bookmarks = slices.DeleteFunc(m.bookmarks, func(b database.Bookmark) bool {
+		if b.UserID == arg.UserID && b.ChirpID == arg.ChirpID {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, nil
+}
+
+func (m *Memory) DeleteCollection(ctx context.Context, arg database.DeleteCollectionParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	m.collections = slices.DeleteFunc(m.collections, func(c database.Collection) bool {
+		if c.ID == arg.ID && c.UserID == arg.UserID {
+			n++
+			return true
+		}
+		return false
+	})
+	if n > 0 {
+		for i, b := range m.bookmarks {
+			if b.CollectionID.Valid && b.CollectionID.UUID == arg.ID {
+				m.bookmarks[i].CollectionID = uuid.NullUUID{}
+			}
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) GetBookmarks(ctx context.Context, arg database.GetBookmarksParams) ([]database.Bookmark, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterBookmarks(arg.UserID, arg.CollectionID, arg.MaxCount, func(i int) bool {
+		return true
+	}), nil
+}
+
+func (m *Memory) GetBookmarksBefore(ctx context.Context, arg database.GetBookmarksBeforeParams) ([]database.Bookmark, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cursor := slices.IndexFunc(m.bookmarks, func(b database.Bookmark) bool {
+		return b.ID == arg.ID
+	})
+	if cursor < 0 {
+		return nil, nil
+	}
+	return m.filterBookmarks(arg.UserID, arg.CollectionID, arg.MaxCount, func(i int) bool {
+		return m.compareBookmarks(i, cursor) < 0
+	}), nil
+}
+
+// filterBookmarks returns up to limit matching bookmarks of the user newest first, only those
+// in the collection if it is valid. The caller holds m.mu.
+func (m *Memory) filterBookmarks(userID uuid.UUID, collectionID uuid.NullUUID, limit int32, match func(i int) bool) []database.Bookmark {
+	var indexes []int
+	for i, b := range m.bookmarks {
+		if b.UserID == userID && (!collectionID.Valid || b.CollectionID == collectionID) && match(i) {
+			indexes = append(indexes, i)
+		}
+	}
+	slices.SortFunc(indexes, func(a, b int) int {
+		return m.compareBookmarks(b, a)
+	})
+
+	var items []database.Bookmark
+	for _, i := range indexes[:min(len(indexes), int(limit))] {
+		items = append(items, m.bookmarks[i])
+	}
+	return items
+}
+
+// compareBookmarks orders the bookmarks at index a and b by created_at,
+// those created at the same time in order of creation
+func (m *Memory) compareBookmarks(a, b int) int {
+	if c := m.bookmarks[a].CreatedAt.Compare(m.bookmarks[b].CreatedAt); c != 0 {
+		return c
+	}
+	return a - b
+}
+
+func (m *Memory) GetCollectionByID(ctx context.Context, arg database.GetCollectionByIDParams) (database.Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, c := range m.collections {
+		if c.ID == arg.ID && c.UserID == arg.UserID {
+			return c, nil
+		}
+	}
+	return database.Collection{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetCollectionsForUser(ctx context.Context, userID uuid.UUID) ([]database.Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.Collection
+	for _, c := range m.collections {
+		if c.UserID == userID {
+			items = append(items, c)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.Collection) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	return items, nil
+}
+
+func (m *Memory) SaveBookmark(ctx context.Context, arg database.SaveBookmarkParams) (database.Bookmark, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Bookmark{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "bookmarks_user_id_fkey"}
+	}
+	if !m.chirpExists(arg.ChirpID) {
+		return database.Bookmark{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "bookmarks_chirp_id_fkey"}
+	}
+	if arg.CollectionID.Valid && !slices.ContainsFunc(m.collections, func(c database.Collection) bool {
+		return c.ID == arg.CollectionID.UUID
+	}) {
+		return database.Bookmark{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "bookmarks_collection_id_fkey"}
+	}
+
+	// saving a chirp again only changes its collection
+	for i, b := range m.bookmarks {
+		if b.UserID == arg.UserID && b.ChirpID == arg.ChirpID {
+			m.bookmarks[i].CollectionID = arg.CollectionID
+			return m.bookmarks[i], nil
+		}
+	}
+	bookmark := database.Bookmark{
+		ID:           uuid.New(),
+		CreatedAt:    now(),
+		UserID:       arg.UserID,
+		ChirpID:      arg.ChirpID,
+		CollectionID: arg.CollectionID,
+	}
+	m.bookmarks = append(m.bookmarks, bookmark)
+	return bookmark, nil
+}
+
+func (m *Memory) UpdateCollection(ctx context.Context, arg database.UpdateCollectionParams) (database.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.collections {
+		if c.ID != arg.ID || c.UserID != arg.UserID {
+			continue
+		}
+		if m.collectionNameTaken(arg.UserID, arg.Name, arg.ID) {
+			return database.Collection{}, &ConstraintError{Code: UniqueViolation, Constraint: "collections_user_id_name_key"}
+		}
+		m.collections[i].Name = arg.Name
+		m.collections[i].UpdatedAt = now()
+		return m.collections[i], nil
+	}
+	return database.Collection{}, sql.ErrNoRows
+}
+
+// pins
+
+// GetPinnedChirpIDs returns the chirps the user pinned, the last pinned first
+func (m *Memory) GetPinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []uuid.UUID
+	for _, p := range slices.Backward(m.pins) {
+		if p.UserID == userID {
+			items = append(items, p.ChirpID)
+		}
+	}
+	return items, nil
+}
+
+func (m *Memory) GetPinsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Pin, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.Pin
+	for _, p := range m.pins {
+		if slices.Contains(chirpIds, p.ChirpID) {
+			items = append(items, p)
+		}
+	}
+	return items, nil
+}
+
+func (m *Memory) IsPinned(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.ContainsFunc(m.pins, func(p database.Pin) bool {
+		return p.ChirpID == chirpID
+	}), nil
+}
+
+func (m *Memory) PinChirp(ctx context.Context, arg database.PinChirpParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, p := range m.pins {
+		if p.UserID == arg.UserID {
+			count++
+		}
+	}
+	if count >= arg.MaxCount {
+		return 0, nil
+	}
+	if !m.chirpExists(arg.ChirpID) {
+		return 0, &ConstraintError{Code: ForeignKeyViolation, Constraint: "pins_chirp_id_fkey"}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return 0, &ConstraintError{Code: ForeignKeyViolation, Constraint: "pins_user_id_fkey"}
+	}
+	if slices.ContainsFunc(m.pins, func(p database.Pin) bool {
+		return p.ChirpID == arg.ChirpID
+	}) {
+		return 0, nil
+	}
+	m.pins = append(m.pins, database.Pin{
+		ChirpID:   arg.ChirpID,
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+	})
+	return 1, nil
+}
+
+func (m *Memory) UnpinChirp(ctx context.Context, arg database.UnpinChirpParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	m.pins = slices.DeleteFunc(m.pins, func(p database.Pin) bool {
+		if p.ChirpID == arg.ChirpID && p.UserID == arg.UserID {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, nil
+}
+
+// refresh tokens
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.refreshTokens[arg.Token]; ok {
+		return &ConstraintError{Code: UniqueViolation, Constraint: "refresh_tokens_pkey"}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return &ConstraintError{Code: ForeignKeyViolation, Constraint: "refresh_tokens_user_id_fkey"}
+	}
+	t := now()
+	m.refreshTokens[arg.Token] = database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		ExpiresAt: t.Add(refreshTokenLifetime),
+	}
+	return nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rt, ok := m.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return rt, nil
+}
+
+func (m *Memory) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	t := now()
+	for token, rt := range m.refreshTokens {
+		if rt.UserID == userID && !rt.RevokedAt.Valid {
+			rt.UpdatedAt = t
+			rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+			m.refreshTokens[token] = rt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rt, ok := m.refreshTokens[token]
+	if !ok {
+		return nil
+	}
+	t := now()
+	rt.UpdatedAt = t
+	rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+	m.refreshTokens[token] = rt
+	return nil
+}
+
+// notifications
+
+func (m *Memory) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Notification{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "notifications_user_id_fkey"}
+	}
+	if _, ok := m.users[arg.ActorID.UUID]; arg.ActorID.Valid && !ok {
+		return database.Notification{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "notifications_actor_id_fkey"}
+	}
+	if arg.ChirpID.Valid && !m.chirpExists(arg.ChirpID.UUID) {
+		return database.Notification{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "notifications_chirp_id_fkey"}
+	}
+	n := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		ActorID:   arg.ActorID,
+		ChirpID:   arg.ChirpID,
+	}
+	m.notifications = append(m.notifications, n)
+	return n, nil
+}
+
+func (m *Memory) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.NotificationPreference
+	for _, p := range m.notificationPreferences[userID] {
+		items = append(items, p)
+	}
+	slices.SortFunc(items, func(a, b database.NotificationPreference) int {
+		return strings.Compare(a.Type, b.Type)
+	})
+	return items, nil
+}
+
+func (m *Memory) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterNotifications(arg.UserID, arg.Limit, func(i int) bool {
+		return true
+	}), nil
+}
+
+func (m *Memory) GetNotificationsBefore(ctx context.Context, arg database.GetNotificationsBeforeParams) ([]database.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cursor := m.notificationIndex(arg.ID)
+	if cursor < 0 {
+		return nil, nil
+	}
+	return m.filterNotifications(arg.UserID, arg.Limit, func(i int) bool {
+		return m.compareNotifications(i, cursor) < 0
+	}), nil
+}
+
+// filterNotifications returns up to limit matching notifications of the user newest first,
+// the caller holds m.mu
+func (m *Memory) filterNotifications(userID uuid.UUID, limit int32, match func(i int) bool) []database.Notification {
+	var indexes []int
+	for i, n := range m.notifications {
+		if n.UserID == userID && match(i) {
+			indexes = append(indexes, i)
+		}
+	}
+	slices.SortFunc(indexes, func(a, b int) int {
+		return m.compareNotifications(b, a)
+	})
+
+	var items []database.Notification
+	for _, i := range indexes[:min(len(indexes), int(limit))] {
+		items = append(items, m.notifications[i])
+	}
+	return items
+}
+
+// compareNotifications orders the notifications at index a and b by created_at,
+// those created at the same time in order of creation
+func (m *Memory) compareNotifications(a, b int) int {
+	if c := m.notifications[a].CreatedAt.Compare(m.notifications[b].CreatedAt); c != 0 {
+		return c
+	}
+	return a - b
+}
+
+// notificationIndex returns the index of the notification with the id or -1, the caller holds m.mu
+func (m *Memory) notificationIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.notifications, func(n database.Notification) bool {
+		return n.ID == id
+	})
+}
+
+func (m *Memory) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.notificationIndex(arg.ID)
+	if i < 0 || m.notifications[i].UserID != arg.UserID || m.notifications[i].ReadAt.Valid {
+		return 0, nil
+	}
+	m.notifications[i].ReadAt = sql.NullTime{Time: now(), Valid: true}
+	return 1, nil
+}
+
+func (m *Memory) MarkNotificationsReadUpTo(ctx context.Context, arg database.MarkNotificationsReadUpToParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cursor := m.notificationIndex(arg.ID)
+	if cursor < 0 || m.notifications[cursor].UserID != arg.UserID {
+		return 0, nil
+	}
+	var marked int64
+	t := now()
+	for i, n := range m.notifications {
+		if n.UserID == arg.UserID && !n.ReadAt.Valid && m.compareNotifications(i, cursor) <= 0 {
+			m.notifications[i].ReadAt = sql.NullTime{Time: t, Valid: true}
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (m *Memory) SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return &ConstraintError{Code: ForeignKeyViolation, Constraint: "notification_preferences_user_id_fkey"}
+	}
+	if m.notificationPreferences[arg.UserID] == nil {
+		m.notificationPreferences[arg.UserID] = map[string]database.NotificationPreference{}
+	}
+	m.notificationPreferences[arg.UserID][arg.Type] = database.NotificationPreference{
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		Enabled:   arg.Enabled,
+		UpdatedAt: now(),
+	}
+	return nil
+}
+
+// direct messages
+
+// CountUnreadMessagesForConversations counts the messages of others the user has not read yet,
+// conversations without unread messages are left out
+func (m *Memory) CountUnreadMessagesForConversations(ctx context.Context, arg database.CountUnreadMessagesForConversationsParams) ([]database.CountUnreadMessagesForConversationsRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.CountUnreadMessagesForConversationsRow
+	for _, id := range arg.ConversationIds {
+		i := m.conversationMemberIndex(id, arg.UserID)
+		if i < 0 {
+			continue
+		}
+		lastRead := m.conversationMembers[i].LastReadAt
+		var count int64
+		for _, msg := range m.messages {
+			if msg.ConversationID == id && msg.SenderID != arg.UserID &&
+				(!lastRead.Valid || msg.CreatedAt.After(lastRead.Time)) {
+				count++
+			}
+		}
+		if count > 0 {
+			items = append(items, database.CountUnreadMessagesForConversationsRow{ConversationID: id, Unread: count})
+		}
+	}
+	return items, nil
+}
+
+func (m *Memory) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conversationIndex(arg.ConversationID) < 0 {
+		return database.Message{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "messages_conversation_id_fkey"}
+	}
+	if _, ok := m.users[arg.SenderID]; !ok {
+		return database.Message{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "messages_sender_id_fkey"}
+	}
+	msg := database.Message{
+		ID:             uuid.New(),
+		CreatedAt:      now(),
+		ConversationID: arg.ConversationID,
+		SenderID:       arg.SenderID,
+		Body:           arg.Body,
+	}
+	m.messages = append(m.messages, msg)
+	return msg, nil
+}
+
+func (m *Memory) GetConversationByID(ctx context.Context, id uuid.UUID) (database.Conversation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.conversationIndex(id)
+	if i < 0 {
+		return database.Conversation{}, sql.ErrNoRows
+	}
+	return m.conversations[i], nil
+}
+
+func (m *Memory) GetConversationMember(ctx context.Context, arg database.GetConversationMemberParams) (database.ConversationMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.conversationMemberIndex(arg.ConversationID, arg.UserID)
+	if i < 0 {
+		return database.ConversationMember{}, sql.ErrNoRows
+	}
+	return m.conversationMembers[i], nil
+}
+
+func (m *Memory) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.ConversationMember
+	for _, cm := range m.conversationMembers {
+		if cm.ConversationID == conversationID {
+			items = append(items, cm)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.ConversationMember) int {
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.UserID[:], b.UserID[:])
+	})
+	return items, nil
+}
+
+func (m *Memory) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.Conversation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var indexes []int
+	for i, c := range m.conversations {
+		if m.conversationMemberIndex(c.ID, userID) >= 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	// newest activity first, those updated at the same time newest first
+	slices.SortFunc(indexes, func(a, b int) int {
+		if c := m.conversations[b].UpdatedAt.Compare(m.conversations[a].UpdatedAt); c != 0 {
+			return c
+		}
+		return b - a
+	})
+
+	var items []database.Conversation
+	for _, i := range indexes {
+		items = append(items, m.conversations[i])
+	}
+	return items, nil
+}
+
+// conversationIndex returns the index of the conversation with the id or -1, the caller holds m.mu
+func (m *Memory) conversationIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.conversations, func(c database.Conversation) bool {
+		return c.ID == id
+	})
+}
+
+// conversationMemberIndex returns the index of the membership or -1, the caller holds m.mu
+func (m *Memory) conversationMemberIndex(conversationID, userID uuid.UUID) int {
+	return slices.IndexFunc(m.conversationMembers, func(cm database.ConversationMember) bool {
+		return cm.ConversationID == conversationID && cm.UserID == userID
+	})
+}
+
+func (m *Memory) GetMembersForConversations(ctx context.Context, conversationIds []uuid.UUID) ([]database.ConversationMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.ConversationMember
+	for _, cm := range m.conversationMembers {
+		if slices.Contains(conversationIds, cm.ConversationID) {
+			items = append(items, cm)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.ConversationMember) int {
+		if c := bytes.Compare(a.ConversationID[:], b.ConversationID[:]); c != 0 {
+			return c
+		}
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.UserID[:], b.UserID[:])
+	})
+	return items, nil
+}
+
+func (m *Memory) GetMessages(ctx context.Context, arg database.GetMessagesParams) ([]database.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterMessages(arg.ConversationID, arg.Limit, func(i int) bool {
+		return true
+	}), nil
+}
+
+func (m *Memory) GetMessagesBefore(ctx context.Context, arg database.GetMessagesBeforeParams) ([]database.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cursor := slices.IndexFunc(m.messages, func(msg database.Message) bool {
+		return msg.ID == arg.ID
+	})
+	if cursor < 0 {
+		return nil, nil
+	}
+	return m.filterMessages(arg.ConversationID, arg.Limit, func(i int) bool {
+		return m.compareMessages(i, cursor) < 0
+	}), nil
+}
+
+// filterMessages returns up to limit matching messages of the conversation newest first,
+// the caller holds m.mu
+func (m *Memory) filterMessages(conversationID uuid.UUID, limit int32, match func(i int) bool) []database.Message {
+	var indexes []int
+	for i, msg := range m.messages {
+		if msg.ConversationID == conversationID && match(i) {
+			indexes = append(indexes, i)
+		}
+	}
+	slices.SortFunc(indexes, func(a, b int) int {
+		return m.compareMessages(b, a)
+	})
+
+	var items []database.Message
+	for _, i := range indexes[:min(len(indexes), int(limit))] {
+		items = append(items, m.messages[i])
+	}
+	return items
+}
+
+// compareMessages orders the messages at index a and b by created_at,
+// those created at the same time in order of creation
+func (m *Memory) compareMessages(a, b int) int {
+	if c := m.messages[a].CreatedAt.Compare(m.messages[b].CreatedAt); c != 0 {
+		return c
+	}
+	return a - b
+}
+
+func (m *Memory) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.conversationMemberIndex(arg.ConversationID, arg.UserID)
+	if i >= 0 {
+		m.conversationMembers[i].LastReadAt = sql.NullTime{Time: now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *Memory) StartConversation(ctx context.Context, arg database.StartConversationParams) (database.StartConversationRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if arg.DirectKey.Valid {
+		i := slices.IndexFunc(m.conversations, func(c database.Conversation) bool {
+			return c.DirectKey == arg.DirectKey
+		})
+		if i >= 0 {
+			c := m.conversations[i]
+			return database.StartConversationRow{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, DirectKey: c.DirectKey}, nil
+		}
+	}
+	for _, id := range arg.MemberIds {
+		if _, ok := m.users[id]; !ok {
+			return database.StartConversationRow{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "conversation_members_user_id_fkey"}
+		}
+	}
+
+	t := now()
+	c := database.Conversation{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		DirectKey: arg.DirectKey,
+	}
+	m.conversations = append(m.conversations, c)
+	for _, id := range arg.MemberIds {
+		if m.conversationMemberIndex(c.ID, id) >= 0 {
+			continue
+		}
+		m.conversationMembers = append(m.conversationMembers, database.ConversationMember{
+			ConversationID: c.ID,
+			UserID:         id,
+			JoinedAt:       t,
+		})
+	}
+	return database.StartConversationRow{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, DirectKey: c.DirectKey, Created: true}, nil
+}
+
+func (m *Memory) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.conversationIndex(id)
+	if i >= 0 {
+		m.conversations[i].UpdatedAt = now()
+	}
+	return nil
+}
+
+// blocks and mutes
+
+func (m *Memory) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.checkUserPair("user_blocks", arg.UserID, "blocked_id", arg.BlockedID)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.blocks[arg.UserID][arg.BlockedID]; ok {
+		return nil
+	}
+	if m.blocks[arg.UserID] == nil {
+		m.blocks[arg.UserID] = map[uuid.UUID]database.UserBlock{}
+	}
+	m.blocks[arg.UserID][arg.BlockedID] = database.UserBlock{
+		UserID:    arg.UserID,
+		BlockedID: arg.BlockedID,
+		CreatedAt: now(),
+	}
+	return nil
+}
+
+// checkUserPair checks the constraints of a table in which one user acts on another,
+// like Postgres it checks the CHECK constraint before the foreign keys. The caller holds m.mu.
+func (m *Memory) checkUserPair(table string, userID uuid.UUID, targetColumn string, targetID uuid.UUID) error {
+	if userID == targetID {
+		return &ConstraintError{Code: CheckViolation, Constraint: table + "_check"}
+	}
+	if _, ok := m.users[userID]; !ok {
+		return &ConstraintError{Code: ForeignKeyViolation, Constraint: table + "_user_id_fkey"}
+	}
+	if _, ok := m.users[targetID]; !ok {
+		return &ConstraintError{Code: ForeignKeyViolation, Constraint: table + "_" + targetColumn + "_fkey"}
+	}
+	return nil
+}
+
+func (m *Memory) GetHiddenUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []uuid.UUID
+	for id := range m.blocks[userID] {
+		items = append(items, id)
+	}
+	for id := range m.mutes[userID] {
+		if _, ok := m.blocks[userID][id]; !ok {
+			items = append(items, id)
+		}
+	}
+	return items, nil
+}
+
+func (m *Memory) HasBlocked(ctx context.Context, arg database.HasBlockedParams) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.blocks[arg.UserID][arg.BlockedID]
+	return ok, nil
+}
+
+func (m *Memory) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.checkUserPair("user_mutes", arg.UserID, "muted_id", arg.MutedID)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.mutes[arg.UserID][arg.MutedID]; ok {
+		return nil
+	}
+	if m.mutes[arg.UserID] == nil {
+		m.mutes[arg.UserID] = map[uuid.UUID]database.UserMute{}
+	}
+	m.mutes[arg.UserID][arg.MutedID] = database.UserMute{
+		UserID:    arg.UserID,
+		MutedID:   arg.MutedID,
+		CreatedAt: now(),
+	}
+	return nil
+}
+
+func (m *Memory) UnblockUser(ctx context.Context, arg database.UnblockUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocks[arg.UserID], arg.BlockedID)
+	return nil
+}
+
+func (m *Memory) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.mutes[arg.UserID], arg.MutedID)
+	return nil
+}
+
+// follows
+
+func (m *Memory) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.checkUserPair("user_follows", arg.UserID, "followed_id", arg.FollowedID)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := m.follows[arg.UserID][arg.FollowedID]; ok {
+		return 0, nil
+	}
+	if m.follows[arg.UserID] == nil {
+		m.follows[arg.UserID] = map[uuid.UUID]database.UserFollow{}
+	}
+	m.follows[arg.UserID][arg.FollowedID] = database.UserFollow{
+		UserID:     arg.UserID,
+		FollowedID: arg.FollowedID,
+		CreatedAt:  now(),
+	}
+	return 1, nil
+}
+
+func (m *Memory) GetFollowedUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []uuid.UUID
+	for id := range m.follows[userID] {
+		items = append(items, id)
+	}
+	return items, nil
+}
+
+func (m *Memory) IsFollowing(ctx context.Context, arg database.IsFollowingParams) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.follows[arg.UserID][arg.FollowedID]
+	return ok, nil
+}
+
+func (m *Memory) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.follows[arg.UserID][arg.FollowedID]; !ok {
+		return 0, nil
+	}
+	delete(m.follows[arg.UserID], arg.FollowedID)
+	return 1, nil
+}
+
+// media
+
+func (m *Memory) CreateMedia(ctx context.Context, arg database.CreateMediaParams) (database.Medium, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Medium{}, &ConstraintError{Code: ForeignKeyViolation, Constraint: "media_user_id_fkey"}
+	}
+	md := database.Medium{
+		ID:           uuid.New(),
+		CreatedAt:    now(),
+		UserID:       arg.UserID,
+		ContentType:  arg.ContentType,
+		Size:         arg.Size,
+		Width:        arg.Width,
+		Height:       arg.Height,
+		BlobKey:      arg.BlobKey,
+		ThumbnailKey: arg.ThumbnailKey,
+	}
+	m.media = append(m.media, md)
+	return md, nil
+}
+
+func (m *Memory) GetMediaByID(ctx context.Context, id uuid.UUID) (database.Medium, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, md := range m.media {
+		if md.ID == id {
+			return md, nil
+		}
+	}
+	return database.Medium{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetMediaForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Medium, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.Medium
+	for _, md := range m.media {
+		if md.ChirpID.Valid && slices.Contains(chirpIds, md.ChirpID.UUID) {
+			items = append(items, md)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.Medium) int {
+		if c := bytes.Compare(a.ChirpID.UUID[:], b.ChirpID.UUID[:]); c != 0 {
+			return c
+		}
+		return int(a.Position - b.Position)
+	})
+	return items, nil
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/zelieen/Chirpy/internal/database"
+)
+
+// Postgres is a Store in Postgres. Most of it are the sqlc queries in internal/database,
+// the methods here need more than one statement and run them in a transaction.
+type Postgres struct {
+	*database.Queries
+	db *sql.DB
+}
+
+var _ Store = (*Postgres)(nil)
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{Queries: database.New(db), db: db}
+}
+
+// PinChirp locks the user before their pins are counted, so concurrent pins cannot exceed the limit.
+// In a single statement the count would miss the pins committed while it waited for the lock.
+func (p *Postgres) PinChirp(ctx context.Context, arg database.PinChirpParams) (int64, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	q := p.Queries.WithTx(tx)
+	_, err = q.LockUser(ctx, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := q.PinChirp(ctx, arg)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
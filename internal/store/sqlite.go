@@ -0,0 +1,769 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/sqlitedb"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is a Store in a single SQLite database file, for small installs and CI without Postgres.
+// Its queries are generated by sqlc from sql/sqlite and match the Postgres ones.
+type SQLite struct {
+	db *sql.DB
+	q  *sqlitedb.Queries
+}
+
+var _ Store = (*SQLite)(nil)
+
+// OpenSQLite opens the database file at path and applies all migrations from the
+// "-- +goose Up" sections of the .sql files in migrations that were not applied yet
+func OpenSQLite(ctx context.Context, path string, migrations fs.FS) (*SQLite, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the database: %w", err)
+	}
+	// SQLite only has a single writer, one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	err = migrateSQLite(ctx, db, migrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db, q: sqlitedb.New(db)}, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func migrateSQLite(ctx context.Context, db *sql.DB, migrations fs.FS) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations(
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+	slices.Sort(files)
+	for _, file := range files {
+		var applied int
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", file).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		content, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return err
+		}
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		up = strings.Replace(up, "-- +goose Up", "", 1)
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, up)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", file)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying migration %s: %w", file, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// "UNIQUE constraint failed: users.email" -> users, email
+var sqliteConstraintColumn = regexp.MustCompile(`constraint failed: (\w+)\.(\w+)`)
+
+// "UNIQUE constraint failed: index 'users_handle_key'" -> users_handle_key, for unique indexes on expressions
+var sqliteConstraintIndex = regexp.MustCompile(`constraint failed: index '(\w+)'`)
+
+// translateSQLiteError turns violated constraints into a *ConstraintError named like the Postgres constraint
+func translateSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	table, column := "", ""
+	if m := sqliteConstraintColumn.FindStringSubmatch(sqliteErr.Error()); m != nil {
+		table, column = m[1], m[2]
+	}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		if m := sqliteConstraintIndex.FindStringSubmatch(sqliteErr.Error()); m != nil {
+			return &ConstraintError{Code: UniqueViolation, Constraint: m[1]}
+		}
+		return &ConstraintError{Code: UniqueViolation, Constraint: table + "_" + column + "_key"}
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return &ConstraintError{Code: UniqueViolation, Constraint: table + "_pkey"}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &ConstraintError{Code: ForeignKeyViolation}
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		return &ConstraintError{Code: CheckViolation}
+	}
+	return err
+}
+
+// idList encodes ids as the JSON array that queries read with json_each, SQLite has no arrays
+func idList(ids []uuid.UUID) string {
+	list, _ := json.Marshal(ids)
+	return string(list)
+}
+
+// users
+
+func (s *SQLite) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	u, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	return database.User(u), translateSQLiteError(err)
+}
+
+func (s *SQLite) DeleteAllUsers(ctx context.Context) error {
+	return s.q.DeleteAllUsers(ctx)
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteUser(ctx, id)
+}
+
+func (s *SQLite) DowngradeUserFromRed(ctx context.Context, id uuid.UUID) error {
+	return s.q.DowngradeUserFromRed(ctx, id)
+}
+
+func (s *SQLite) GetUserByEMail(ctx context.Context, email string) (database.User, error) {
+	u, err := s.q.GetUserByEMail(ctx, email)
+	return database.User(u), err
+}
+
+func (s *SQLite) GetUserByHandle(ctx context.Context, handle string) (database.User, error) {
+	u, err := s.q.GetUserByHandle(ctx, handle)
+	return database.User(u), err
+}
+
+func (s *SQLite) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	u, err := s.q.GetUserByID(ctx, id)
+	return database.User(u), err
+}
+
+func (s *SQLite) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	u, err := s.q.SetUserRole(ctx, sqlitedb.SetUserRoleParams(arg))
+	return database.User(u), translateSQLiteError(err)
+}
+
+func (s *SQLite) UpdateUserCredentials(ctx context.Context, arg database.UpdateUserCredentialsParams) (database.User, error) {
+	u, err := s.q.UpdateUserCredentials(ctx, sqlitedb.UpdateUserCredentialsParams(arg))
+	return database.User(u), translateSQLiteError(err)
+}
+
+func (s *SQLite) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	u, err := s.q.UpdateUserPassword(ctx, sqlitedb.UpdateUserPasswordParams(arg))
+	return database.User(u), err
+}
+
+func (s *SQLite) UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error) {
+	u, err := s.q.UpdateUserProfile(ctx, sqlitedb.UpdateUserProfileParams(arg))
+	return database.User(u), translateSQLiteError(err)
+}
+
+func (s *SQLite) UpdateUserSensitiveContent(ctx context.Context, arg database.UpdateUserSensitiveContentParams) (database.User, error) {
+	u, err := s.q.UpdateUserSensitiveContent(ctx, sqlitedb.UpdateUserSensitiveContentParams(arg))
+	return database.User(u), translateSQLiteError(err)
+}
+
+func (s *SQLite) UpgradeUserToRed(ctx context.Context, id uuid.UUID) error {
+	return s.q.UpgradeUserToRed(ctx, id)
+}
+
+// chirps
+
+func (s *SQLite) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	if arg.PublishAt.Valid {
+		arg.PublishAt.Time = arg.PublishAt.Time.UTC()
+	}
+	c, err := s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams(arg))
+	return database.Chirp(c), translateSQLiteError(err)
+}
+
+func (s *SQLite) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirpByID(ctx, id)
+}
+
+func (s *SQLite) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	c, err := s.q.GetChirpByID(ctx, id)
+	return database.Chirp(c), err
+}
+
+func (s *SQLite) GetChirpList(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirpList(ctx)
+	return convertChirps(chirps), err
+}
+
+func (s *SQLite) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirpsByAuthor(ctx, userID)
+	return convertChirps(chirps), err
+}
+
+func (s *SQLite) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirpsByIDs(ctx, idList(ids))
+	return convertChirps(chirps), err
+}
+
+// PostChirp stores the chirp with its uploads and poll in one transaction, nothing is stored and
+// sql.ErrNoRows is returned when one of the uploads is missing or attached to a chirp already
+func (s *SQLite) PostChirp(ctx context.Context, arg database.PostChirpParams) (database.Chirp, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	q := s.q.WithTx(tx)
+	if arg.PublishAt.Valid {
+		arg.PublishAt.Time = arg.PublishAt.Time.UTC()
+	}
+	c, err := q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		Body:           arg.Body,
+		UserID:         arg.UserID,
+		PublishAt:      arg.PublishAt,
+		ContentWarning: arg.ContentWarning,
+		Sensitive:      arg.Sensitive,
+		Visibility:     arg.Visibility,
+	})
+	if err != nil {
+		return database.Chirp{}, translateSQLiteError(err)
+	}
+	for i, id := range arg.MediaIds {
+		n, err := q.AttachMedia(ctx, sqlitedb.AttachMediaParams{
+			ChirpID:  uuid.NullUUID{UUID: c.ID, Valid: true},
+			Position: int32(i),
+			ID:       id,
+			UserID:   arg.UserID,
+		})
+		if err != nil {
+			return database.Chirp{}, translateSQLiteError(err)
+		}
+		if n == 0 {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+	}
+	if arg.PollClosesAt.Valid {
+		_, err = q.CreatePoll(ctx, sqlitedb.CreatePollParams{
+			ChirpID:        c.ID,
+			ClosesAt:       arg.PollClosesAt.Time.UTC(),
+			MultipleChoice: arg.PollMultipleChoice,
+		})
+		if err != nil {
+			return database.Chirp{}, translateSQLiteError(err)
+		}
+		for i, text := range arg.PollOptions {
+			err = q.CreatePollOption(ctx, sqlitedb.CreatePollOptionParams{ChirpID: c.ID, Position: int32(i), Text: text})
+			if err != nil {
+				return database.Chirp{}, translateSQLiteError(err)
+			}
+		}
+	}
+	return database.Chirp(c), tx.Commit()
+}
+
+func (s *SQLite) SetChirpWarning(ctx context.Context, arg database.SetChirpWarningParams) (database.Chirp, error) {
+	c, err := s.q.SetChirpWarning(ctx, sqlitedb.SetChirpWarningParams(arg))
+	return database.Chirp(c), translateSQLiteError(err)
+}
+
+// scheduled chirps
+
+func (s *SQLite) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	return s.q.DeleteScheduledChirp(ctx, sqlitedb.DeleteScheduledChirpParams(arg))
+}
+
+func (s *SQLite) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.GetScheduledChirps(ctx, userID)
+	return convertChirps(chirps), err
+}
+
+func (s *SQLite) PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error) {
+	// UTC so the times compare as text like the ones CreateChirp stored
+	chirps, err := s.q.PublishDueChirps(ctx, sqlitedb.PublishDueChirpsParams{Now: arg.Now.UTC(), MaxCount: arg.MaxCount})
+	return convertChirps(chirps), err
+}
+
+func (s *SQLite) PublishScheduledChirp(ctx context.Context, arg database.PublishScheduledChirpParams) (database.Chirp, error) {
+	c, err := s.q.PublishScheduledChirp(ctx, sqlitedb.PublishScheduledChirpParams(arg))
+	return database.Chirp(c), err
+}
+
+// drafts
+
+func (s *SQLite) CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error) {
+	d, err := s.q.CreateDraft(ctx, sqlitedb.CreateDraftParams(arg))
+	return database.Draft(d), translateSQLiteError(err)
+}
+
+func (s *SQLite) DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (int64, error) {
+	return s.q.DeleteDraft(ctx, sqlitedb.DeleteDraftParams(arg))
+}
+
+func (s *SQLite) GetDraftByID(ctx context.Context, arg database.GetDraftByIDParams) (database.Draft, error) {
+	d, err := s.q.GetDraftByID(ctx, sqlitedb.GetDraftByIDParams(arg))
+	return database.Draft(d), err
+}
+
+func (s *SQLite) GetDraftsForUser(ctx context.Context, userID uuid.UUID) ([]database.Draft, error) {
+	drafts, err := s.q.GetDraftsForUser(ctx, userID)
+	var items []database.Draft
+	for _, d := range drafts {
+		items = append(items, database.Draft(d))
+	}
+	return items, err
+}
+
+// PublishDraft creates the chirp and deletes the draft in one transaction,
+// SQLite has no DELETE in WITH clauses like the Postgres query
+func (s *SQLite) PublishDraft(ctx context.Context, arg database.PublishDraftParams) (database.Chirp, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	q := s.q.WithTx(tx)
+	c, err := q.PublishDraft(ctx, sqlitedb.PublishDraftParams(arg))
+	if err != nil {
+		return database.Chirp{}, translateSQLiteError(err)
+	}
+	_, err = q.DeleteDraft(ctx, sqlitedb.DeleteDraftParams{ID: arg.ID, UserID: arg.UserID})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return database.Chirp(c), tx.Commit()
+}
+
+func (s *SQLite) UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error) {
+	d, err := s.q.UpdateDraft(ctx, sqlitedb.UpdateDraftParams(arg))
+	return database.Draft(d), err
+}
+
+// polls
+
+func (s *SQLite) CreatePollVote(ctx context.Context, arg database.CreatePollVoteParams) (database.PollVote, error) {
+	v, err := s.q.CreatePollVote(ctx, sqlitedb.CreatePollVoteParams(arg))
+	return database.PollVote(v), translateSQLiteError(err)
+}
+
+func (s *SQLite) GetPoll(ctx context.Context, chirpID uuid.UUID) (database.GetPollRow, error) {
+	p, err := s.q.GetPoll(ctx, chirpID)
+	return database.GetPollRow(p), err
+}
+
+func (s *SQLite) GetPollResults(ctx context.Context, chirpID uuid.UUID) ([]database.GetPollResultsRow, error) {
+	results, err := s.q.GetPollResults(ctx, chirpID)
+	var items []database.GetPollResultsRow
+	for _, r := range results {
+		items = append(items, database.GetPollResultsRow(r))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetPollsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollsForChirpsRow, error) {
+	polls, err := s.q.GetPollsForChirps(ctx, idList(chirpIds))
+	var items []database.GetPollsForChirpsRow
+	for _, p := range polls {
+		items = append(items, database.GetPollsForChirpsRow(p))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetPollResultsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollResultsForChirpsRow, error) {
+	results, err := s.q.GetPollResultsForChirps(ctx, idList(chirpIds))
+	var items []database.GetPollResultsForChirpsRow
+	for _, r := range results {
+		items = append(items, database.GetPollResultsForChirpsRow(r))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetPollVotesForChirps(ctx context.Context, arg database.GetPollVotesForChirpsParams) ([]database.PollVote, error) {
+	votes, err := s.q.GetPollVotesForChirps(ctx, sqlitedb.GetPollVotesForChirpsParams{ChirpIds: idList(arg.ChirpIds), UserID: arg.UserID})
+	var items []database.PollVote
+	for _, v := range votes {
+		items = append(items, database.PollVote(v))
+	}
+	return items, err
+}
+
+// bookmarks
+
+func (s *SQLite) CreateCollection(ctx context.Context, arg database.CreateCollectionParams) (database.Collection, error) {
+	c, err := s.q.CreateCollection(ctx, sqlitedb.CreateCollectionParams(arg))
+	return database.Collection(c), translateSQLiteError(err)
+}
+
+func (s *SQLite) DeleteBookmark(ctx context.Context, arg database.DeleteBookmarkParams) (int64, error) {
+	return s.q.DeleteBookmark(ctx, sqlitedb.DeleteBookmarkParams(arg))
+}
+
+func (s *SQLite) DeleteCollection(ctx context.Context, arg database.DeleteCollectionParams) (int64, error) {
+	return s.q.DeleteCollection(ctx, sqlitedb.DeleteCollectionParams(arg))
+}
+
+func (s *SQLite) GetBookmarks(ctx context.Context, arg database.GetBookmarksParams) ([]database.Bookmark, error) {
+	bookmarks, err := s.q.GetBookmarks(ctx, sqlitedb.GetBookmarksParams(arg))
+	return convertBookmarks(bookmarks), err
+}
+
+func (s *SQLite) GetBookmarksBefore(ctx context.Context, arg database.GetBookmarksBeforeParams) ([]database.Bookmark, error) {
+	bookmarks, err := s.q.GetBookmarksBefore(ctx, sqlitedb.GetBookmarksBeforeParams(arg))
+	return convertBookmarks(bookmarks), err
+}
+
+func (s *SQLite) GetCollectionByID(ctx context.Context, arg database.GetCollectionByIDParams) (database.Collection, error) {
+	c, err := s.q.GetCollectionByID(ctx, sqlitedb.GetCollectionByIDParams(arg))
+	return database.Collection(c), err
+}
+
+func (s *SQLite) GetCollectionsForUser(ctx context.Context, userID uuid.UUID) ([]database.Collection, error) {
+	collections, err := s.q.GetCollectionsForUser(ctx, userID)
+	var items []database.Collection
+	for _, c := range collections {
+		items = append(items, database.Collection(c))
+	}
+	return items, err
+}
+
+func (s *SQLite) SaveBookmark(ctx context.Context, arg database.SaveBookmarkParams) (database.Bookmark, error) {
+	b, err := s.q.SaveBookmark(ctx, sqlitedb.SaveBookmarkParams(arg))
+	return database.Bookmark(b), translateSQLiteError(err)
+}
+
+func (s *SQLite) UpdateCollection(ctx context.Context, arg database.UpdateCollectionParams) (database.Collection, error) {
+	c, err := s.q.UpdateCollection(ctx, sqlitedb.UpdateCollectionParams(arg))
+	return database.Collection(c), translateSQLiteError(err)
+}
+
+// pins
+
+func (s *SQLite) GetPinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetPinnedChirpIDs(ctx, userID)
+}
+
+func (s *SQLite) GetPinsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Pin, error) {
+	pins, err := s.q.GetPinsForChirps(ctx, idList(chirpIds))
+	var items []database.Pin
+	for _, p := range pins {
+		items = append(items, database.Pin(p))
+	}
+	return items, err
+}
+
+func (s *SQLite) IsPinned(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	return s.q.IsPinned(ctx, chirpID)
+}
+
+func (s *SQLite) PinChirp(ctx context.Context, arg database.PinChirpParams) (int64, error) {
+	n, err := s.q.PinChirp(ctx, sqlitedb.PinChirpParams(arg))
+	return n, translateSQLiteError(err)
+}
+
+func (s *SQLite) UnpinChirp(ctx context.Context, arg database.UnpinChirpParams) (int64, error) {
+	return s.q.UnpinChirp(ctx, sqlitedb.UnpinChirpParams(arg))
+}
+
+func convertBookmarks(bookmarks []sqlitedb.Bookmark) []database.Bookmark {
+	var items []database.Bookmark
+	for _, b := range bookmarks {
+		items = append(items, database.Bookmark(b))
+	}
+	return items
+}
+
+func convertChirps(chirps []sqlitedb.Chirp) []database.Chirp {
+	var items []database.Chirp
+	for _, c := range chirps {
+		items = append(items, database.Chirp(c))
+	}
+	return items
+}
+
+// refresh tokens
+
+func (s *SQLite) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	err := s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams(arg))
+	return translateSQLiteError(err)
+}
+
+func (s *SQLite) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	rt, err := s.q.GetRefreshToken(ctx, token)
+	return database.RefreshToken(rt), err
+}
+
+func (s *SQLite) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeAllRefreshTokensForUser(ctx, userID)
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, token string) error {
+	return s.q.RevokeRefreshToken(ctx, token)
+}
+
+// notifications
+
+func (s *SQLite) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountUnreadNotifications(ctx, userID)
+}
+
+func (s *SQLite) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	n, err := s.q.CreateNotification(ctx, sqlitedb.CreateNotificationParams(arg))
+	return database.Notification(n), translateSQLiteError(err)
+}
+
+func (s *SQLite) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	prefs, err := s.q.GetNotificationPreferences(ctx, userID)
+	var items []database.NotificationPreference
+	for _, p := range prefs {
+		items = append(items, database.NotificationPreference(p))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error) {
+	notifications, err := s.q.GetNotifications(ctx, sqlitedb.GetNotificationsParams(arg))
+	return convertNotifications(notifications), err
+}
+
+func (s *SQLite) GetNotificationsBefore(ctx context.Context, arg database.GetNotificationsBeforeParams) ([]database.Notification, error) {
+	notifications, err := s.q.GetNotificationsBefore(ctx, sqlitedb.GetNotificationsBeforeParams(arg))
+	return convertNotifications(notifications), err
+}
+
+func convertNotifications(notifications []sqlitedb.Notification) []database.Notification {
+	var items []database.Notification
+	for _, n := range notifications {
+		items = append(items, database.Notification(n))
+	}
+	return items
+}
+
+func (s *SQLite) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	return s.q.MarkNotificationRead(ctx, sqlitedb.MarkNotificationReadParams(arg))
+}
+
+func (s *SQLite) MarkNotificationsReadUpTo(ctx context.Context, arg database.MarkNotificationsReadUpToParams) (int64, error) {
+	return s.q.MarkNotificationsReadUpTo(ctx, sqlitedb.MarkNotificationsReadUpToParams(arg))
+}
+
+func (s *SQLite) SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error {
+	err := s.q.SetNotificationPreference(ctx, sqlitedb.SetNotificationPreferenceParams(arg))
+	return translateSQLiteError(err)
+}
+
+// direct messages
+
+func (s *SQLite) CountUnreadMessagesForConversations(ctx context.Context, arg database.CountUnreadMessagesForConversationsParams) ([]database.CountUnreadMessagesForConversationsRow, error) {
+	rows, err := s.q.CountUnreadMessagesForConversations(ctx, sqlitedb.CountUnreadMessagesForConversationsParams{
+		ConversationIds: idList(arg.ConversationIds),
+		UserID:          arg.UserID,
+	})
+	var items []database.CountUnreadMessagesForConversationsRow
+	for _, row := range rows {
+		items = append(items, database.CountUnreadMessagesForConversationsRow(row))
+	}
+	return items, err
+}
+
+func (s *SQLite) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	msg, err := s.q.CreateMessage(ctx, sqlitedb.CreateMessageParams(arg))
+	return database.Message(msg), translateSQLiteError(err)
+}
+
+func (s *SQLite) GetConversationByID(ctx context.Context, id uuid.UUID) (database.Conversation, error) {
+	c, err := s.q.GetConversationByID(ctx, id)
+	return database.Conversation(c), err
+}
+
+func (s *SQLite) GetConversationMember(ctx context.Context, arg database.GetConversationMemberParams) (database.ConversationMember, error) {
+	member, err := s.q.GetConversationMember(ctx, sqlitedb.GetConversationMemberParams(arg))
+	return database.ConversationMember(member), err
+}
+
+func (s *SQLite) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error) {
+	members, err := s.q.GetConversationMembers(ctx, conversationID)
+	var items []database.ConversationMember
+	for _, member := range members {
+		items = append(items, database.ConversationMember(member))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.Conversation, error) {
+	conversations, err := s.q.GetConversationsForUser(ctx, userID)
+	var items []database.Conversation
+	for _, c := range conversations {
+		items = append(items, database.Conversation(c))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetMembersForConversations(ctx context.Context, conversationIds []uuid.UUID) ([]database.ConversationMember, error) {
+	members, err := s.q.GetMembersForConversations(ctx, idList(conversationIds))
+	var items []database.ConversationMember
+	for _, member := range members {
+		items = append(items, database.ConversationMember(member))
+	}
+	return items, err
+}
+
+func (s *SQLite) GetMessages(ctx context.Context, arg database.GetMessagesParams) ([]database.Message, error) {
+	messages, err := s.q.GetMessages(ctx, sqlitedb.GetMessagesParams(arg))
+	return convertMessages(messages), err
+}
+
+func (s *SQLite) GetMessagesBefore(ctx context.Context, arg database.GetMessagesBeforeParams) ([]database.Message, error) {
+	messages, err := s.q.GetMessagesBefore(ctx, sqlitedb.GetMessagesBeforeParams(arg))
+	return convertMessages(messages), err
+}
+
+func convertMessages(messages []sqlitedb.Message) []database.Message {
+	var items []database.Message
+	for _, msg := range messages {
+		items = append(items, database.Message(msg))
+	}
+	return items
+}
+
+func (s *SQLite) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	return s.q.MarkConversationRead(ctx, sqlitedb.MarkConversationReadParams(arg))
+}
+
+// StartConversation creates the conversation and adds its members in one transaction, SQLite
+// has no INSERT in WITH clauses like the Postgres query. The store has a single connection,
+// so nobody else can create the same direct conversation in between.
+func (s *SQLite) StartConversation(ctx context.Context, arg database.StartConversationParams) (database.StartConversationRow, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.StartConversationRow{}, err
+	}
+	defer tx.Rollback()
+
+	q := s.q.WithTx(tx)
+	if arg.DirectKey.Valid {
+		c, err := q.GetConversationByDirectKey(ctx, arg.DirectKey)
+		if err == nil {
+			return database.StartConversationRow{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, DirectKey: c.DirectKey}, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return database.StartConversationRow{}, err
+		}
+	}
+	c, err := q.CreateConversation(ctx, arg.DirectKey)
+	if err != nil {
+		return database.StartConversationRow{}, translateSQLiteError(err)
+	}
+	for _, id := range arg.MemberIds {
+		err = q.AddConversationMember(ctx, sqlitedb.AddConversationMemberParams{ConversationID: c.ID, UserID: id})
+		if err != nil {
+			return database.StartConversationRow{}, translateSQLiteError(err)
+		}
+	}
+	row := database.StartConversationRow{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, DirectKey: c.DirectKey, Created: true}
+	return row, tx.Commit()
+}
+
+func (s *SQLite) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	return s.q.TouchConversation(ctx, id)
+}
+
+// blocks and mutes
+
+func (s *SQLite) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	err := s.q.BlockUser(ctx, sqlitedb.BlockUserParams(arg))
+	return translateSQLiteError(err)
+}
+
+func (s *SQLite) GetHiddenUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetHiddenUserIDs(ctx, userID)
+}
+
+func (s *SQLite) HasBlocked(ctx context.Context, arg database.HasBlockedParams) (bool, error) {
+	return s.q.HasBlocked(ctx, sqlitedb.HasBlockedParams(arg))
+}
+
+func (s *SQLite) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	err := s.q.MuteUser(ctx, sqlitedb.MuteUserParams(arg))
+	return translateSQLiteError(err)
+}
+
+func (s *SQLite) UnblockUser(ctx context.Context, arg database.UnblockUserParams) error {
+	return s.q.UnblockUser(ctx, sqlitedb.UnblockUserParams(arg))
+}
+
+func (s *SQLite) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) error {
+	return s.q.UnmuteUser(ctx, sqlitedb.UnmuteUserParams(arg))
+}
+
+// follows
+
+func (s *SQLite) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	n, err := s.q.FollowUser(ctx, sqlitedb.FollowUserParams(arg))
+	return n, translateSQLiteError(err)
+}
+
+func (s *SQLite) GetFollowedUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetFollowedUserIDs(ctx, userID)
+}
+
+func (s *SQLite) IsFollowing(ctx context.Context, arg database.IsFollowingParams) (bool, error) {
+	return s.q.IsFollowing(ctx, sqlitedb.IsFollowingParams(arg))
+}
+
+func (s *SQLite) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	return s.q.UnfollowUser(ctx, sqlitedb.UnfollowUserParams(arg))
+}
+
+// media
+
+func (s *SQLite) CreateMedia(ctx context.Context, arg database.CreateMediaParams) (database.Medium, error) {
+	m, err := s.q.CreateMedia(ctx, sqlitedb.CreateMediaParams(arg))
+	return database.Medium(m), translateSQLiteError(err)
+}
+
+func (s *SQLite) GetMediaByID(ctx context.Context, id uuid.UUID) (database.Medium, error) {
+	m, err := s.q.GetMediaByID(ctx, id)
+	return database.Medium(m), err
+}
+
+func (s *SQLite) GetMediaForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Medium, error) {
+	media, err := s.q.GetMediaForChirps(ctx, idList(chirpIds))
+	var items []database.Medium
+	for _, m := range media {
+		items = append(items, database.Medium(m))
+	}
+	return items, err
+}
@@ -0,0 +1,129 @@
+// Package store defines the storage used by the server and its implementations.
+package store
+
+import (
+	"context"
+
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// Store is everything the server reads from and writes to its database.
+// Postgres runs the sqlc generated queries, SQLite keeps everything in a file
+// and Memory keeps everything in the process.
+type Store interface {
+	// users
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	DeleteAllUsers(ctx context.Context) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	DowngradeUserFromRed(ctx context.Context, id uuid.UUID) error
+	GetUserByEMail(ctx context.Context, email string) (database.User, error)
+	GetUserByHandle(ctx context.Context, handle string) (database.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error)
+	SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error)
+	UpdateUserCredentials(ctx context.Context, arg database.UpdateUserCredentialsParams) (database.User, error)
+	UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error)
+	UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error)
+	UpdateUserSensitiveContent(ctx context.Context, arg database.UpdateUserSensitiveContentParams) (database.User, error)
+	UpgradeUserToRed(ctx context.Context, id uuid.UUID) error
+
+	// chirps
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	DeleteChirpByID(ctx context.Context, id uuid.UUID) error
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetChirpList(ctx context.Context) ([]database.Chirp, error)
+	GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]database.Chirp, error)
+	PostChirp(ctx context.Context, arg database.PostChirpParams) (database.Chirp, error)
+	SetChirpWarning(ctx context.Context, arg database.SetChirpWarningParams) (database.Chirp, error)
+
+	// scheduled chirps
+	DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error)
+	GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error)
+	PublishScheduledChirp(ctx context.Context, arg database.PublishScheduledChirpParams) (database.Chirp, error)
+
+	// drafts
+	CreateDraft(ctx context.Context, arg database.CreateDraftParams) (database.Draft, error)
+	DeleteDraft(ctx context.Context, arg database.DeleteDraftParams) (int64, error)
+	GetDraftByID(ctx context.Context, arg database.GetDraftByIDParams) (database.Draft, error)
+	GetDraftsForUser(ctx context.Context, userID uuid.UUID) ([]database.Draft, error)
+	PublishDraft(ctx context.Context, arg database.PublishDraftParams) (database.Chirp, error)
+	UpdateDraft(ctx context.Context, arg database.UpdateDraftParams) (database.Draft, error)
+
+	// polls
+	CreatePollVote(ctx context.Context, arg database.CreatePollVoteParams) (database.PollVote, error)
+	GetPoll(ctx context.Context, chirpID uuid.UUID) (database.GetPollRow, error)
+	GetPollResults(ctx context.Context, chirpID uuid.UUID) ([]database.GetPollResultsRow, error)
+	GetPollsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollsForChirpsRow, error)
+	GetPollResultsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollResultsForChirpsRow, error)
+	GetPollVotesForChirps(ctx context.Context, arg database.GetPollVotesForChirpsParams) ([]database.PollVote, error)
+
+	// bookmarks
+	CreateCollection(ctx context.Context, arg database.CreateCollectionParams) (database.Collection, error)
+	DeleteBookmark(ctx context.Context, arg database.DeleteBookmarkParams) (int64, error)
+	DeleteCollection(ctx context.Context, arg database.DeleteCollectionParams) (int64, error)
+	GetBookmarks(ctx context.Context, arg database.GetBookmarksParams) ([]database.Bookmark, error)
+	GetBookmarksBefore(ctx context.Context, arg database.GetBookmarksBeforeParams) ([]database.Bookmark, error)
+	GetCollectionByID(ctx context.Context, arg database.GetCollectionByIDParams) (database.Collection, error)
+	GetCollectionsForUser(ctx context.Context, userID uuid.UUID) ([]database.Collection, error)
+	SaveBookmark(ctx context.Context, arg database.SaveBookmarkParams) (database.Bookmark, error)
+	UpdateCollection(ctx context.Context, arg database.UpdateCollectionParams) (database.Collection, error)
+
+	// pins
+	GetPinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	GetPinsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Pin, error)
+	IsPinned(ctx context.Context, chirpID uuid.UUID) (bool, error)
+	PinChirp(ctx context.Context, arg database.PinChirpParams) (int64, error)
+	UnpinChirp(ctx context.Context, arg database.UnpinChirpParams) (int64, error)
+
+	// refresh tokens
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error
+	GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+
+	// notifications
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	GetNotifications(ctx context.Context, arg database.GetNotificationsParams) ([]database.Notification, error)
+	GetNotificationsBefore(ctx context.Context, arg database.GetNotificationsBeforeParams) ([]database.Notification, error)
+	MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error)
+	MarkNotificationsReadUpTo(ctx context.Context, arg database.MarkNotificationsReadUpToParams) (int64, error)
+	SetNotificationPreference(ctx context.Context, arg database.SetNotificationPreferenceParams) error
+
+	// direct messages
+	CountUnreadMessagesForConversations(ctx context.Context, arg database.CountUnreadMessagesForConversationsParams) ([]database.CountUnreadMessagesForConversationsRow, error)
+	CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error)
+	GetConversationByID(ctx context.Context, id uuid.UUID) (database.Conversation, error)
+	GetConversationMember(ctx context.Context, arg database.GetConversationMemberParams) (database.ConversationMember, error)
+	GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]database.ConversationMember, error)
+	GetConversationsForUser(ctx context.Context, userID uuid.UUID) ([]database.Conversation, error)
+	GetMembersForConversations(ctx context.Context, conversationIds []uuid.UUID) ([]database.ConversationMember, error)
+	GetMessages(ctx context.Context, arg database.GetMessagesParams) ([]database.Message, error)
+	GetMessagesBefore(ctx context.Context, arg database.GetMessagesBeforeParams) ([]database.Message, error)
+	MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error
+	StartConversation(ctx context.Context, arg database.StartConversationParams) (database.StartConversationRow, error)
+	TouchConversation(ctx context.Context, id uuid.UUID) error
+
+	// blocks and mutes
+	BlockUser(ctx context.Context, arg database.BlockUserParams) error
+	GetHiddenUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	HasBlocked(ctx context.Context, arg database.HasBlockedParams) (bool, error)
+	MuteUser(ctx context.Context, arg database.MuteUserParams) error
+	UnblockUser(ctx context.Context, arg database.UnblockUserParams) error
+	UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) error
+
+	// follows
+	FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error)
+	GetFollowedUserIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	IsFollowing(ctx context.Context, arg database.IsFollowingParams) (bool, error)
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error)
+
+	// media
+	CreateMedia(ctx context.Context, arg database.CreateMediaParams) (database.Medium, error)
+	GetMediaByID(ctx context.Context, id uuid.UUID) (database.Medium, error)
+	GetMediaForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]database.Medium, error)
+}
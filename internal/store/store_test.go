@@ -0,0 +1,1113 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/database"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// getTestStores returns every store the contract tests run against,
+// Postgres is only tested if TEST_DB_URL points to a migrated database
+func getTestStores(t *testing.T) map[string]Store {
+	t.Helper()
+	sqliteStore, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "chirpy.db"), os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+	t.Cleanup(func() { sqliteStore.Close() })
+
+	stores := map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": sqliteStore,
+	}
+	if dbURL := os.Getenv("TEST_DB_URL"); dbURL != "" {
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			t.Fatalf("Error opening the database: %s", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		stores["postgres"] = NewPostgres(db)
+	}
+	return stores
+}
+
+func TestStoreUsers(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			err := s.DeleteAllUsers(ctx)
+			if err != nil {
+				t.Fatalf("DeleteAllUsers() error = %v", err)
+			}
+
+			user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com", HashedPassword: "hash"})
+			if err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			if user.Role != "user" || user.IsChirpyRed || user.SensitiveContent != "collapse" {
+				t.Errorf("CreateUser() defaults = %+v", user)
+			}
+
+			_, err = s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com", HashedPassword: "hash"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != UniqueViolation || c.Constraint != "users_email_key" {
+				t.Errorf("CreateUser() duplicate error = %v", err)
+			}
+
+			other, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com", HashedPassword: "hash"})
+			_, err = s.UpdateUserCredentials(ctx, database.UpdateUserCredentialsParams{ID: other.ID, Email: "a@example.com", HashedPassword: "hash"})
+			if _, ok := AsConstraintError(err); !ok {
+				t.Errorf("UpdateUserCredentials() to a taken email error = %v", err)
+			}
+
+			_, err = s.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: "superuser"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("SetUserRole() invalid role error = %v", err)
+			}
+
+			_, err = s.UpdateUserSensitiveContent(ctx, database.UpdateUserSensitiveContentParams{ID: user.ID, SensitiveContent: "blur"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("UpdateUserSensitiveContent() invalid value error = %v", err)
+			}
+			if u, err := s.UpdateUserSensitiveContent(ctx, database.UpdateUserSensitiveContentParams{ID: user.ID, SensitiveContent: "hide"}); err != nil || u.SensitiveContent != "hide" {
+				t.Errorf("UpdateUserSensitiveContent() = %+v, %v", u, err)
+			}
+
+			err = s.UpgradeUserToRed(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("UpgradeUserToRed() error = %v", err)
+			}
+			got, err := s.GetUserByEMail(ctx, "a@example.com")
+			if err != nil || !got.IsChirpyRed {
+				t.Errorf("GetUserByEMail() = %+v, %v", got, err)
+			}
+
+			_, err = s.GetUserByID(ctx, got.ID)
+			if err != nil {
+				t.Errorf("GetUserByID() error = %v", err)
+			}
+			_, err = s.GetUserByEMail(ctx, "missing@example.com")
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetUserByEMail() missing error = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestStoreUserProfiles(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+			if alice.Handle.Valid || alice.DisplayName != "" {
+				t.Errorf("CreateUser() profile defaults = %+v", alice)
+			}
+
+			updated, err := s.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+				ID:          alice.ID,
+				Handle:      sql.NullString{String: "Alice", Valid: true},
+				DisplayName: "Alice A.",
+				Bio:         "hello",
+				AvatarUrl:   "https://example.com/alice.png",
+			})
+			if err != nil {
+				t.Fatalf("UpdateUserProfile() error = %v", err)
+			}
+			if updated.Handle.String != "Alice" || updated.DisplayName != "Alice A." || updated.Email != alice.Email {
+				t.Errorf("UpdateUserProfile() = %+v", updated)
+			}
+
+			got, err := s.GetUserByHandle(ctx, "aLICE")
+			if err != nil || got.ID != alice.ID {
+				t.Errorf("GetUserByHandle() = %+v, %v, want the handle to match regardless of case", got, err)
+			}
+			_, err = s.GetUserByHandle(ctx, "missing")
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetUserByHandle() missing error = %v, want sql.ErrNoRows", err)
+			}
+
+			_, err = s.UpdateUserProfile(ctx, database.UpdateUserProfileParams{ID: bob.ID, Handle: sql.NullString{String: "alice", Valid: true}})
+			if c, ok := AsConstraintError(err); !ok || c.Code != UniqueViolation || c.Constraint != "users_handle_key" {
+				t.Errorf("UpdateUserProfile() taken handle error = %v", err)
+			}
+
+			// users without a handle do not collide
+			_, err = s.UpdateUserProfile(ctx, database.UpdateUserProfileParams{ID: bob.ID, DisplayName: "Bob"})
+			if err != nil {
+				t.Errorf("UpdateUserProfile() without handle error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreChirpsAndTokens(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			bodies := []string{"first", "second", "third"}
+			for i, body := range bodies {
+				author := alice.ID
+				if i == 1 {
+					author = bob.ID
+				}
+				_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: author, Visibility: "public"})
+				if err != nil {
+					t.Fatalf("CreateChirp() error = %v", err)
+				}
+			}
+
+			chirps, _ := s.GetChirpList(ctx)
+			if len(chirps) != 3 || chirps[0].Body != "first" || chirps[2].Body != "third" {
+				t.Errorf("GetChirpList() = %v, want the chirps in order of creation", chirps)
+			}
+			aliceChirps, _ := s.GetChirpsByAuthor(ctx, alice.ID)
+			if len(aliceChirps) != 2 {
+				t.Errorf("GetChirpsByAuthor() = %v, want 2 chirps", aliceChirps)
+			}
+			byIDs, _ := s.GetChirpsByIDs(ctx, []uuid.UUID{chirps[2].ID, chirps[0].ID, uuid.New()})
+			if len(byIDs) != 2 || byIDs[0].Body != "first" || byIDs[1].Body != "third" {
+				t.Errorf("GetChirpsByIDs() = %v, want the first and third chirp in order of creation", byIDs)
+			}
+
+			err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "token", UserID: alice.ID})
+			if err != nil {
+				t.Fatalf("CreateRefreshToken() error = %v", err)
+			}
+			rt, err := s.GetRefreshToken(ctx, "token")
+			if err != nil || rt.RevokedAt.Valid || !rt.ExpiresAt.After(rt.CreatedAt) {
+				t.Errorf("GetRefreshToken() = %+v, %v", rt, err)
+			}
+			revoked, err := s.RevokeAllRefreshTokensForUser(ctx, alice.ID)
+			if err != nil || revoked != 1 {
+				t.Errorf("RevokeAllRefreshTokensForUser() = %d, %v, want 1", revoked, err)
+			}
+
+			// deleting a user deletes their chirps and refresh tokens
+			err = s.DeleteUser(ctx, alice.ID)
+			if err != nil {
+				t.Fatalf("DeleteUser() error = %v", err)
+			}
+			chirps, _ = s.GetChirpList(ctx)
+			if len(chirps) != 1 || chirps[0].UserID != bob.ID {
+				t.Errorf("GetChirpList() after DeleteUser() = %v", chirps)
+			}
+			_, err = s.GetRefreshToken(ctx, "token")
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetRefreshToken() after DeleteUser() error = %v", err)
+			}
+
+			_, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "ghost", UserID: alice.ID, Visibility: "public"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("CreateChirp() for a deleted user error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreNotifications(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+			chirp, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: bob.ID, Visibility: "public"})
+
+			var created []database.Notification
+			for range 5 {
+				n, err := s.CreateNotification(ctx, database.CreateNotificationParams{
+					UserID:  alice.ID,
+					Type:    "mention",
+					ActorID: uuid.NullUUID{UUID: bob.ID, Valid: true},
+					ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+				})
+				if err != nil {
+					t.Fatalf("CreateNotification() error = %v", err)
+				}
+				created = append(created, n)
+			}
+			_, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: bob.ID, Type: "chirpy_red"})
+			if err != nil {
+				t.Fatalf("CreateNotification() without actor and chirp error = %v", err)
+			}
+			_, err = s.CreateNotification(ctx, database.CreateNotificationParams{UserID: uuid.New(), Type: "chirpy_red"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("CreateNotification() for a missing user error = %v", err)
+			}
+
+			// newest first, in pages
+			page, err := s.GetNotifications(ctx, database.GetNotificationsParams{UserID: alice.ID, Limit: 3})
+			if err != nil || len(page) != 3 || page[0].ID != created[4].ID || page[2].ID != created[2].ID {
+				t.Fatalf("GetNotifications() = %v, %v", page, err)
+			}
+			page, err = s.GetNotificationsBefore(ctx, database.GetNotificationsBeforeParams{UserID: alice.ID, ID: page[2].ID, Limit: 3})
+			if err != nil || len(page) != 2 || page[0].ID != created[1].ID || page[1].ID != created[0].ID {
+				t.Fatalf("GetNotificationsBefore() = %v, %v", page, err)
+			}
+
+			marked, err := s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: created[4].ID, UserID: bob.ID})
+			if err != nil || marked != 0 {
+				t.Errorf("MarkNotificationRead() of another user = %d, %v, want 0", marked, err)
+			}
+			marked, _ = s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: created[4].ID, UserID: alice.ID})
+			if marked != 1 {
+				t.Errorf("MarkNotificationRead() = %d, want 1", marked)
+			}
+			marked, _ = s.MarkNotificationsReadUpTo(ctx, database.MarkNotificationsReadUpToParams{UserID: alice.ID, ID: created[1].ID})
+			if marked != 2 {
+				t.Errorf("MarkNotificationsReadUpTo() = %d, want 2", marked)
+			}
+			unread, err := s.CountUnreadNotifications(ctx, alice.ID)
+			if err != nil || unread != 2 {
+				t.Errorf("CountUnreadNotifications() = %d, %v, want 2", unread, err)
+			}
+
+			err = s.SetNotificationPreference(ctx, database.SetNotificationPreferenceParams{UserID: alice.ID, Type: "mention", Enabled: false})
+			if err != nil {
+				t.Fatalf("SetNotificationPreference() error = %v", err)
+			}
+			s.SetNotificationPreference(ctx, database.SetNotificationPreferenceParams{UserID: alice.ID, Type: "follow", Enabled: false})
+			s.SetNotificationPreference(ctx, database.SetNotificationPreferenceParams{UserID: alice.ID, Type: "mention", Enabled: true})
+			prefs, err := s.GetNotificationPreferences(ctx, alice.ID)
+			if err != nil || len(prefs) != 2 || prefs[0].Type != "follow" || prefs[0].Enabled || !prefs[1].Enabled {
+				t.Errorf("GetNotificationPreferences() = %+v, %v", prefs, err)
+			}
+
+			// notifications go with the chirp and the actor
+			s.DeleteChirpByID(ctx, chirp.ID)
+			page, _ = s.GetNotifications(ctx, database.GetNotificationsParams{UserID: alice.ID, Limit: 10})
+			if len(page) != 0 {
+				t.Errorf("GetNotifications() after DeleteChirpByID() = %v", page)
+			}
+			s.DeleteUser(ctx, bob.ID)
+			unread, _ = s.CountUnreadNotifications(ctx, bob.ID)
+			if unread != 0 {
+				t.Errorf("CountUnreadNotifications() after DeleteUser() = %d", unread)
+			}
+		})
+	}
+}
+
+func TestStoreStartConversation(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+			members := []uuid.UUID{alice.ID, bob.ID}
+
+			key := sql.NullString{String: uuid.NewString(), Valid: true}
+			c, err := s.StartConversation(ctx, database.StartConversationParams{DirectKey: key, MemberIds: members})
+			if err != nil || !c.Created || c.DirectKey != key {
+				t.Fatalf("StartConversation() = %+v, %v", c, err)
+			}
+			got, err := s.GetConversationMembers(ctx, c.ID)
+			if err != nil || len(got) != 2 {
+				t.Errorf("GetConversationMembers() = %v, %v", got, err)
+			}
+			again, err := s.StartConversation(ctx, database.StartConversationParams{DirectKey: key, MemberIds: members})
+			if err != nil || again.Created || again.ID != c.ID {
+				t.Errorf("StartConversation() with a used direct key = %+v, %v, want the first one", again, err)
+			}
+
+			// group conversations are never the same
+			first, _ := s.StartConversation(ctx, database.StartConversationParams{MemberIds: members})
+			second, err := s.StartConversation(ctx, database.StartConversationParams{MemberIds: members})
+			if err != nil || !second.Created || second.ID == first.ID {
+				t.Errorf("StartConversation() without direct key = %+v, %v", second, err)
+			}
+
+			// nothing is left behind if a member does not exist
+			missing := sql.NullString{String: uuid.NewString(), Valid: true}
+			_, err = s.StartConversation(ctx, database.StartConversationParams{DirectKey: missing, MemberIds: []uuid.UUID{alice.ID, uuid.New()}})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("StartConversation() with an unknown member error = %v", err)
+			}
+			c, err = s.StartConversation(ctx, database.StartConversationParams{DirectKey: missing, MemberIds: members})
+			if err != nil || !c.Created {
+				t.Errorf("StartConversation() after a failed start = %+v, %v, want a new conversation", c, err)
+			}
+		})
+	}
+}
+
+func TestStoreMessages(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			key := sql.NullString{String: uuid.NewString(), Valid: true}
+			c, err := s.StartConversation(ctx, database.StartConversationParams{DirectKey: key, MemberIds: []uuid.UUID{alice.ID, bob.ID}})
+			if err != nil {
+				t.Fatalf("StartConversation() error = %v", err)
+			}
+			group, err := s.StartConversation(ctx, database.StartConversationParams{MemberIds: []uuid.UUID{alice.ID}})
+			if err != nil {
+				t.Fatalf("StartConversation() without direct key error = %v", err)
+			}
+			members, err := s.GetConversationMembers(ctx, c.ID)
+			if err != nil || len(members) != 2 {
+				t.Errorf("GetConversationMembers() = %v, %v", members, err)
+			}
+			ids := []uuid.UUID{group.ID, c.ID}
+			all, err := s.GetMembersForConversations(ctx, ids)
+			if err != nil || len(all) != 3 {
+				t.Errorf("GetMembersForConversations() = %v, %v, want the members of both", all, err)
+			}
+			if all, err := s.GetMembersForConversations(ctx, nil); err != nil || len(all) != 0 {
+				t.Errorf("GetMembersForConversations(nil) = %v, %v, want none", all, err)
+			}
+			_, err = s.GetConversationMember(ctx, database.GetConversationMemberParams{ConversationID: group.ID, UserID: bob.ID})
+			if err != sql.ErrNoRows {
+				t.Errorf("GetConversationMember() of a non member error = %v, want sql.ErrNoRows", err)
+			}
+
+			var sent []database.Message
+			for range 5 {
+				msg, err := s.CreateMessage(ctx, database.CreateMessageParams{ConversationID: c.ID, SenderID: alice.ID, Body: "hi"})
+				if err != nil {
+					t.Fatalf("CreateMessage() error = %v", err)
+				}
+				sent = append(sent, msg)
+			}
+			s.TouchConversation(ctx, c.ID)
+
+			// newest first, in pages
+			page, err := s.GetMessages(ctx, database.GetMessagesParams{ConversationID: c.ID, Limit: 3})
+			if err != nil || len(page) != 3 || page[0].ID != sent[4].ID || page[2].ID != sent[2].ID {
+				t.Fatalf("GetMessages() = %v, %v", page, err)
+			}
+			page, err = s.GetMessagesBefore(ctx, database.GetMessagesBeforeParams{ConversationID: c.ID, ID: page[2].ID, Limit: 3})
+			if err != nil || len(page) != 2 || page[0].ID != sent[1].ID || page[1].ID != sent[0].ID {
+				t.Fatalf("GetMessagesBefore() = %v, %v", page, err)
+			}
+
+			// the most recently active conversation comes first
+			conversations, err := s.GetConversationsForUser(ctx, alice.ID)
+			if err != nil || len(conversations) != 2 || conversations[0].ID != c.ID {
+				t.Errorf("GetConversationsForUser() = %v, %v", conversations, err)
+			}
+
+			// messages of the user themselves are never unread, conversations without unread messages are left out
+			unread, err := s.CountUnreadMessagesForConversations(ctx, database.CountUnreadMessagesForConversationsParams{ConversationIds: ids, UserID: bob.ID})
+			if err != nil || len(unread) != 1 || unread[0].ConversationID != c.ID || unread[0].Unread != 5 {
+				t.Errorf("CountUnreadMessagesForConversations() = %+v, %v, want 5 in one", unread, err)
+			}
+			unread, _ = s.CountUnreadMessagesForConversations(ctx, database.CountUnreadMessagesForConversationsParams{ConversationIds: ids, UserID: alice.ID})
+			if len(unread) != 0 {
+				t.Errorf("CountUnreadMessagesForConversations() of the sender = %+v, want none", unread)
+			}
+			err = s.MarkConversationRead(ctx, database.MarkConversationReadParams{ConversationID: c.ID, UserID: bob.ID})
+			if err != nil {
+				t.Fatalf("MarkConversationRead() error = %v", err)
+			}
+			member, _ := s.GetConversationMember(ctx, database.GetConversationMemberParams{ConversationID: c.ID, UserID: bob.ID})
+			unread, _ = s.CountUnreadMessagesForConversations(ctx, database.CountUnreadMessagesForConversationsParams{ConversationIds: ids, UserID: bob.ID})
+			if !member.LastReadAt.Valid || len(unread) != 0 {
+				t.Errorf("after MarkConversationRead() last read %v, unread %+v", member.LastReadAt, unread)
+			}
+
+			// messages and memberships go with the user, the conversation stays
+			s.DeleteUser(ctx, alice.ID)
+			page, _ = s.GetMessages(ctx, database.GetMessagesParams{ConversationID: c.ID, Limit: 10})
+			members, _ = s.GetConversationMembers(ctx, c.ID)
+			if len(page) != 0 || len(members) != 1 {
+				t.Errorf("after DeleteUser() %d messages and %d members", len(page), len(members))
+			}
+		})
+	}
+}
+
+func TestStoreBlocksAndMutes(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+			carol, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "carol@example.com", HashedPassword: "hash"})
+
+			// blocking twice is fine
+			for range 2 {
+				err := s.BlockUser(ctx, database.BlockUserParams{UserID: alice.ID, BlockedID: bob.ID})
+				if err != nil {
+					t.Fatalf("BlockUser() error = %v", err)
+				}
+			}
+			err := s.BlockUser(ctx, database.BlockUserParams{UserID: alice.ID, BlockedID: alice.ID})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("BlockUser() of themselves error = %v", err)
+			}
+			err = s.MuteUser(ctx, database.MuteUserParams{UserID: alice.ID, MutedID: uuid.New()})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("MuteUser() of a missing user error = %v", err)
+			}
+
+			blocked, err := s.HasBlocked(ctx, database.HasBlockedParams{UserID: alice.ID, BlockedID: bob.ID})
+			if err != nil || !blocked {
+				t.Errorf("HasBlocked() = %v, %v, want true", blocked, err)
+			}
+			blocked, _ = s.HasBlocked(ctx, database.HasBlockedParams{UserID: bob.ID, BlockedID: alice.ID})
+			if blocked {
+				t.Errorf("HasBlocked() the other way = true, blocks only go one way")
+			}
+
+			// a user both blocked and muted is hidden once
+			s.MuteUser(ctx, database.MuteUserParams{UserID: alice.ID, MutedID: bob.ID})
+			s.MuteUser(ctx, database.MuteUserParams{UserID: alice.ID, MutedID: carol.ID})
+			hidden, err := s.GetHiddenUserIDs(ctx, alice.ID)
+			if err != nil || len(hidden) != 2 {
+				t.Errorf("GetHiddenUserIDs() = %v, %v", hidden, err)
+			}
+
+			s.UnblockUser(ctx, database.UnblockUserParams{UserID: alice.ID, BlockedID: bob.ID})
+			s.UnmuteUser(ctx, database.UnmuteUserParams{UserID: alice.ID, MutedID: bob.ID})
+			blocked, _ = s.HasBlocked(ctx, database.HasBlockedParams{UserID: alice.ID, BlockedID: bob.ID})
+			hidden, _ = s.GetHiddenUserIDs(ctx, alice.ID)
+			if blocked || len(hidden) != 1 || hidden[0] != carol.ID {
+				t.Errorf("after unblocking and unmuting bob: blocked %v, hidden %v", blocked, hidden)
+			}
+
+			s.DeleteUser(ctx, carol.ID)
+			hidden, _ = s.GetHiddenUserIDs(ctx, alice.ID)
+			if len(hidden) != 0 {
+				t.Errorf("GetHiddenUserIDs() after DeleteUser() = %v", hidden)
+			}
+		})
+	}
+}
+
+func TestStoreMedia(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			var uploads []database.Medium
+			for _, key := range []string{"media/a.png", "media/b.png"} {
+				md, err := s.CreateMedia(ctx, database.CreateMediaParams{
+					UserID:       alice.ID,
+					ContentType:  "image/png",
+					Size:         100,
+					Width:        10,
+					Height:       20,
+					BlobKey:      key,
+					ThumbnailKey: key + ".thumb",
+				})
+				if err != nil {
+					t.Fatalf("CreateMedia() error = %v", err)
+				}
+				uploads = append(uploads, md)
+			}
+			_, err := s.CreateMedia(ctx, database.CreateMediaParams{UserID: uuid.New(), BlobKey: "x"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("CreateMedia() for a missing user error = %v", err)
+			}
+
+			// only the uploader can attach, and only once, no chirp is stored otherwise
+			_, err = s.PostChirp(ctx, database.PostChirpParams{MediaIds: []uuid.UUID{uploads[0].ID}, UserID: bob.ID, Body: "mine", Visibility: "public"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("PostChirp() with another user's upload error = %v, want sql.ErrNoRows", err)
+			}
+			chirp, err := s.PostChirp(ctx, database.PostChirpParams{
+				MediaIds:   []uuid.UUID{uploads[1].ID, uploads[0].ID},
+				UserID:     alice.ID,
+				Body:       "look",
+				Visibility: "public",
+			})
+			if err != nil {
+				t.Fatalf("PostChirp() error = %v", err)
+			}
+			_, err = s.PostChirp(ctx, database.PostChirpParams{MediaIds: []uuid.UUID{uploads[0].ID}, UserID: alice.ID, Body: "again", Visibility: "public"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("PostChirp() with an attached upload error = %v, want sql.ErrNoRows", err)
+			}
+			_, err = s.PostChirp(ctx, database.PostChirpParams{MediaIds: []uuid.UUID{uuid.New()}, UserID: alice.ID, Body: "missing", Visibility: "public"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("PostChirp() with a missing upload error = %v, want sql.ErrNoRows", err)
+			}
+			if chirps, _ := s.GetChirpsByAuthor(ctx, alice.ID); len(chirps) != 1 {
+				t.Errorf("GetChirpsByAuthor() = %+v, want only the chirp with the media", chirps)
+			}
+			if chirps, _ := s.GetChirpsByAuthor(ctx, bob.ID); len(chirps) != 0 {
+				t.Errorf("GetChirpsByAuthor() = %+v, want no chirp of the failed attach", chirps)
+			}
+
+			other, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "no media", UserID: alice.ID, Visibility: "public"})
+			got, err := s.GetMediaForChirps(ctx, []uuid.UUID{other.ID, chirp.ID})
+			if err != nil || len(got) != 2 || got[0].ID != uploads[1].ID || got[0].Width != 10 || got[1].Position != 1 {
+				t.Errorf("GetMediaForChirps() = %+v, %v, want both by position", got, err)
+			}
+			if got, err := s.GetMediaForChirps(ctx, nil); err != nil || len(got) != 0 {
+				t.Errorf("GetMediaForChirps(nil) = %+v, %v, want none", got, err)
+			}
+
+			err = s.DeleteChirpByID(ctx, chirp.ID)
+			if err != nil {
+				t.Fatalf("DeleteChirpByID() error = %v", err)
+			}
+			_, err = s.GetMediaByID(ctx, uploads[0].ID)
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetMediaByID() of a deleted chirp error = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestStoreScheduledChirps(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			start := time.Now().Add(time.Hour)
+			at := func(minutes int) sql.NullTime {
+				return sql.NullTime{Time: start.Add(time.Duration(minutes) * time.Minute), Valid: true}
+			}
+
+			published, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "now", UserID: alice.ID, Visibility: "public"})
+			if published.PublishAt.Valid {
+				t.Errorf("CreateChirp() without publish_at = %v, want NULL", published.PublishAt)
+			}
+			later, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "later", UserID: alice.ID, PublishAt: at(20), Visibility: "public"})
+			sooner, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "sooner", UserID: alice.ID, PublishAt: at(10), Visibility: "public"})
+			canceled, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "canceled", UserID: alice.ID, PublishAt: at(30), Visibility: "public"})
+
+			scheduled, err := s.GetScheduledChirps(ctx, alice.ID)
+			if err != nil || len(scheduled) != 3 || scheduled[0].ID != sooner.ID || scheduled[0].PublishAt.Time.Sub(at(10).Time).Abs() > time.Millisecond {
+				t.Errorf("GetScheduledChirps() = %+v, %v, want 3 by publish_at", scheduled, err)
+			}
+
+			// published chirps and other users cannot cancel
+			for _, arg := range []database.DeleteScheduledChirpParams{{ID: published.ID, UserID: alice.ID}, {ID: canceled.ID, UserID: uuid.New()}} {
+				if n, err := s.DeleteScheduledChirp(ctx, arg); err != nil || n != 0 {
+					t.Errorf("DeleteScheduledChirp(%+v) = %d, %v, want 0", arg, n, err)
+				}
+			}
+			if n, err := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: canceled.ID, UserID: alice.ID}); err != nil || n != 1 {
+				t.Errorf("DeleteScheduledChirp() = %d, %v, want 1", n, err)
+			}
+
+			// the same goes for publishing one right away
+			for _, arg := range []database.PublishScheduledChirpParams{{ID: published.ID, UserID: alice.ID}, {ID: canceled.ID, UserID: alice.ID}, {ID: later.ID, UserID: uuid.New()}} {
+				if _, err := s.PublishScheduledChirp(ctx, arg); !errors.Is(err, sql.ErrNoRows) {
+					t.Errorf("PublishScheduledChirp(%+v) error = %v, want sql.ErrNoRows", arg, err)
+				}
+			}
+			rightAway, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "right away", UserID: alice.ID, PublishAt: at(40), Visibility: "public"})
+			rightAway, err = s.PublishScheduledChirp(ctx, database.PublishScheduledChirpParams{ID: rightAway.ID, UserID: alice.ID})
+			if err != nil || rightAway.PublishAt.Valid {
+				t.Errorf("PublishScheduledChirp() = %+v, %v, want it published", rightAway, err)
+			}
+
+			due, err := s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: start.Add(-time.Minute), MaxCount: 10})
+			if err != nil || len(due) != 0 {
+				t.Errorf("PublishDueChirps() before any is due = %+v, %v", due, err)
+			}
+			due, err = s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: start.Add(time.Hour), MaxCount: 1})
+			if err != nil || len(due) != 1 || due[0].ID != sooner.ID || due[0].PublishAt.Valid {
+				t.Errorf("PublishDueChirps() = %+v, %v, want the sooner chirp", due, err)
+			}
+			due, _ = s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: start.Add(time.Hour), MaxCount: 10})
+			if len(due) != 1 || due[0].ID != later.ID {
+				t.Errorf("PublishDueChirps() again = %+v, want the later chirp", due)
+			}
+
+			// published chirps are listed after the ones that were there before
+			chirps, _ := s.GetChirpsByAuthor(ctx, alice.ID)
+			if len(chirps) != 4 || chirps[0].ID != published.ID {
+				t.Errorf("GetChirpsByAuthor() = %+v, want the chirp published first in front", chirps)
+			}
+			if scheduled, _ := s.GetScheduledChirps(ctx, alice.ID); len(scheduled) != 0 {
+				t.Errorf("GetScheduledChirps() after publishing = %+v, want none", scheduled)
+			}
+		})
+	}
+}
+
+func TestStoreDrafts(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			first, err := s.CreateDraft(ctx, database.CreateDraftParams{UserID: alice.ID, Body: "first thoughts"})
+			if err != nil || first.Body != "first thoughts" {
+				t.Fatalf("CreateDraft() = %+v, %v", first, err)
+			}
+			second, _ := s.CreateDraft(ctx, database.CreateDraftParams{UserID: alice.ID, Body: "second"})
+			_, err = s.CreateDraft(ctx, database.CreateDraftParams{UserID: uuid.New(), Body: "x"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("CreateDraft() for a missing user error = %v", err)
+			}
+
+			// drafts only exist for their author
+			_, err = s.UpdateDraft(ctx, database.UpdateDraftParams{ID: first.ID, UserID: bob.ID, Body: "mine now"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("UpdateDraft() by another user error = %v, want sql.ErrNoRows", err)
+			}
+			if _, err := s.GetDraftByID(ctx, database.GetDraftByIDParams{ID: first.ID, UserID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetDraftByID() by another user error = %v, want sql.ErrNoRows", err)
+			}
+			updated, err := s.UpdateDraft(ctx, database.UpdateDraftParams{ID: first.ID, UserID: alice.ID, Body: "better thoughts"})
+			if err != nil || updated.Body != "better thoughts" || updated.UpdatedAt.Before(first.UpdatedAt) {
+				t.Errorf("UpdateDraft() = %+v, %v", updated, err)
+			}
+			// newest first, SQLite only stores milliseconds, so the update may tie with the
+			// second draft, which is then ordered by id
+			want := []uuid.UUID{updated.ID, second.ID}
+			if updated.UpdatedAt.Equal(second.UpdatedAt) && bytes.Compare(second.ID[:], updated.ID[:]) > 0 {
+				want = []uuid.UUID{second.ID, updated.ID}
+			}
+			drafts, err := s.GetDraftsForUser(ctx, alice.ID)
+			if err != nil || len(drafts) != 2 || drafts[0].ID != want[0] || drafts[1].ID != want[1] {
+				t.Errorf("GetDraftsForUser() = %+v, %v, want %v", drafts, err, want)
+			}
+
+			// publishing checks the draft is unchanged and replaces it with the chirp
+			_, err = s.PublishDraft(ctx, database.PublishDraftParams{ID: first.ID, UserID: alice.ID, DraftBody: "first thoughts", Body: "first thoughts"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("PublishDraft() of a changed draft error = %v, want sql.ErrNoRows", err)
+			}
+			chirp, err := s.PublishDraft(ctx, database.PublishDraftParams{ID: first.ID, UserID: alice.ID, DraftBody: "better thoughts", Body: "better ****"})
+			if err != nil || chirp.Body != "better ****" || chirp.UserID != alice.ID {
+				t.Fatalf("PublishDraft() = %+v, %v", chirp, err)
+			}
+			if got, err := s.GetChirpByID(ctx, chirp.ID); err != nil || got.Body != chirp.Body {
+				t.Errorf("GetChirpByID() of the published draft = %+v, %v", got, err)
+			}
+			_, err = s.PublishDraft(ctx, database.PublishDraftParams{ID: first.ID, UserID: alice.ID, DraftBody: "better thoughts", Body: "again"})
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("PublishDraft() twice error = %v, want sql.ErrNoRows", err)
+			}
+
+			if n, err := s.DeleteDraft(ctx, database.DeleteDraftParams{ID: second.ID, UserID: bob.ID}); err != nil || n != 0 {
+				t.Errorf("DeleteDraft() by another user = %d, %v", n, err)
+			}
+			if n, err := s.DeleteDraft(ctx, database.DeleteDraftParams{ID: second.ID, UserID: alice.ID}); err != nil || n != 1 {
+				t.Errorf("DeleteDraft() = %d, %v", n, err)
+			}
+			if drafts, _ := s.GetDraftsForUser(ctx, alice.ID); len(drafts) != 0 {
+				t.Errorf("GetDraftsForUser() after publishing and deleting = %+v, want none", drafts)
+			}
+		})
+	}
+}
+
+func TestStorePolls(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			closesAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			chirp, err := s.PostChirp(ctx, database.PostChirpParams{
+				UserID:             alice.ID,
+				Body:               "tea or coffee?",
+				Visibility:         "public",
+				PollClosesAt:       sql.NullTime{Time: closesAt, Valid: true},
+				PollMultipleChoice: true,
+				PollOptions:        []string{"tea", "coffee", "water"},
+			})
+			if err != nil {
+				t.Fatalf("PostChirp() error = %v", err)
+			}
+
+			_, err = s.CreatePollVote(ctx, database.CreatePollVoteParams{ChirpID: chirp.ID, UserID: alice.ID, Choices: 0b011})
+			if err != nil {
+				t.Fatalf("CreatePollVote() error = %v", err)
+			}
+			s.CreatePollVote(ctx, database.CreatePollVoteParams{ChirpID: chirp.ID, UserID: bob.ID, Choices: 0b010})
+			// one vote per user
+			_, err = s.CreatePollVote(ctx, database.CreatePollVoteParams{ChirpID: chirp.ID, UserID: bob.ID, Choices: 0b100})
+			if c, ok := AsConstraintError(err); !ok || c.Code != UniqueViolation || c.Constraint != "poll_votes_pkey" {
+				t.Errorf("CreatePollVote() twice error = %v, want poll_votes_pkey", err)
+			}
+
+			got, err := s.GetPoll(ctx, chirp.ID)
+			if err != nil || got.VoterCount != 2 || !got.ClosesAt.Equal(closesAt) || !got.MultipleChoice {
+				t.Errorf("GetPoll() = %+v, %v, want 2 voters", got, err)
+			}
+			results, err := s.GetPollResults(ctx, chirp.ID)
+			if err != nil || len(results) != 3 {
+				t.Fatalf("GetPollResults() = %+v, %v", results, err)
+			}
+			for i, want := range []int64{1, 2, 0} {
+				if results[i].Position != int32(i) || results[i].Votes != want {
+					t.Errorf("GetPollResults()[%d] = %+v, want %d votes", i, results[i], want)
+				}
+			}
+
+			// the same for a list of chirps, chirps without a poll are left out
+			other, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "no poll", UserID: alice.ID, Visibility: "public"})
+			ids := []uuid.UUID{other.ID, chirp.ID}
+			polls, err := s.GetPollsForChirps(ctx, ids)
+			if err != nil || len(polls) != 1 || polls[0].ChirpID != chirp.ID || polls[0].VoterCount != 2 {
+				t.Errorf("GetPollsForChirps() = %+v, %v, want the poll with 2 voters", polls, err)
+			}
+			allResults, err := s.GetPollResultsForChirps(ctx, ids)
+			if err != nil || len(allResults) != 3 || allResults[1].ChirpID != chirp.ID || allResults[1].Votes != 2 {
+				t.Errorf("GetPollResultsForChirps() = %+v, %v, want the 3 options by position", allResults, err)
+			}
+			votes, err := s.GetPollVotesForChirps(ctx, database.GetPollVotesForChirpsParams{ChirpIds: ids, UserID: bob.ID})
+			if err != nil || len(votes) != 1 || votes[0].Choices != 0b010 {
+				t.Errorf("GetPollVotesForChirps() = %+v, %v", votes, err)
+			}
+
+			// a chirp with its poll at once, nothing is stored if any of it fails
+			withPoll, err := s.PostChirp(ctx, database.PostChirpParams{
+				UserID:       alice.ID,
+				Body:         "cats or dogs?",
+				Visibility:   "public",
+				PollClosesAt: sql.NullTime{Time: closesAt, Valid: true},
+				PollOptions:  []string{"cats", "dogs"},
+			})
+			if err != nil || withPoll.Body != "cats or dogs?" {
+				t.Fatalf("PostChirp() = %+v, %v", withPoll, err)
+			}
+			if got, err := s.GetPoll(ctx, withPoll.ID); err != nil || !got.ClosesAt.Equal(closesAt) {
+				t.Errorf("GetPoll() of a chirp created with its poll = %+v, %v", got, err)
+			}
+			if results, _ := s.GetPollResults(ctx, withPoll.ID); len(results) != 2 || results[1].Position != 1 || results[1].Text != "dogs" {
+				t.Errorf("GetPollResults() of a chirp created with its poll = %+v, want both options by position", results)
+			}
+			_, err = s.PostChirp(ctx, database.PostChirpParams{
+				UserID:       alice.ID,
+				Body:         "lost",
+				Visibility:   "everyone",
+				PollClosesAt: sql.NullTime{Time: closesAt, Valid: true},
+				PollOptions:  []string{"a", "b"},
+			})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("PostChirp() with an invalid visibility error = %v", err)
+			}
+			if chirps, _ := s.GetChirpsByAuthor(ctx, alice.ID); len(chirps) != 3 {
+				t.Errorf("GetChirpsByAuthor() = %+v, want no chirp of the failed poll", chirps)
+			}
+
+			// deleting a voter takes back their vote, deleting the chirp the poll
+			s.DeleteUser(ctx, bob.ID)
+			if got, _ := s.GetPoll(ctx, chirp.ID); got.VoterCount != 1 {
+				t.Errorf("GetPoll() after deleting a voter = %+v, want 1 voter", got)
+			}
+			s.DeleteChirpByID(ctx, chirp.ID)
+			if _, err := s.GetPoll(ctx, chirp.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetPoll() of a deleted chirp error = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestStoreBookmarks(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			reading, err := s.CreateCollection(ctx, database.CreateCollectionParams{UserID: alice.ID, Name: "Reading"})
+			if err != nil || reading.Name != "Reading" {
+				t.Fatalf("CreateCollection() = %+v, %v", reading, err)
+			}
+			_, err = s.CreateCollection(ctx, database.CreateCollectionParams{UserID: alice.ID, Name: "reading"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != UniqueViolation || c.Constraint != "collections_user_id_name_key" {
+				t.Errorf("CreateCollection() with a taken name error = %v, want collections_user_id_name_key", err)
+			}
+			if _, err := s.CreateCollection(ctx, database.CreateCollectionParams{UserID: bob.ID, Name: "Reading"}); err != nil {
+				t.Errorf("CreateCollection() with the name of another user's collection error = %v", err)
+			}
+			if _, err := s.GetCollectionByID(ctx, database.GetCollectionByIDParams{ID: reading.ID, UserID: bob.ID}); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetCollectionByID() by another user error = %v, want sql.ErrNoRows", err)
+			}
+			funny, _ := s.CreateCollection(ctx, database.CreateCollectionParams{UserID: alice.ID, Name: "funny"})
+			renamed, err := s.UpdateCollection(ctx, database.UpdateCollectionParams{ID: funny.ID, UserID: alice.ID, Name: "Funny"})
+			if err != nil || renamed.Name != "Funny" {
+				t.Errorf("UpdateCollection() = %+v, %v", renamed, err)
+			}
+			if list, err := s.GetCollectionsForUser(ctx, alice.ID); err != nil || len(list) != 2 || list[0].ID != funny.ID {
+				t.Errorf("GetCollectionsForUser() = %+v, %v, want them by name", list, err)
+			}
+
+			var chirps []database.Chirp
+			for _, body := range []string{"one", "two", "three"} {
+				c, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: bob.ID, Visibility: "public"})
+				chirps = append(chirps, c)
+				_, err := s.SaveBookmark(ctx, database.SaveBookmarkParams{UserID: alice.ID, ChirpID: c.ID})
+				if err != nil {
+					t.Fatalf("SaveBookmark() error = %v", err)
+				}
+			}
+			// saving again moves the bookmark into the collection
+			moved, err := s.SaveBookmark(ctx, database.SaveBookmarkParams{
+				UserID:       alice.ID,
+				ChirpID:      chirps[0].ID,
+				CollectionID: uuid.NullUUID{UUID: reading.ID, Valid: true},
+			})
+			if err != nil || moved.CollectionID.UUID != reading.ID {
+				t.Errorf("SaveBookmark() again = %+v, %v", moved, err)
+			}
+			_, err = s.SaveBookmark(ctx, database.SaveBookmarkParams{UserID: alice.ID, ChirpID: uuid.New()})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("SaveBookmark() of a missing chirp error = %v", err)
+			}
+
+			page, err := s.GetBookmarks(ctx, database.GetBookmarksParams{UserID: alice.ID, MaxCount: 2})
+			if err != nil || len(page) != 2 || page[0].ChirpID != chirps[2].ID {
+				t.Fatalf("GetBookmarks() = %+v, %v, want the newest 2", page, err)
+			}
+			next, err := s.GetBookmarksBefore(ctx, database.GetBookmarksBeforeParams{UserID: alice.ID, ID: page[1].ID, MaxCount: 2})
+			if err != nil || len(next) != 1 || next[0].ID != moved.ID {
+				t.Errorf("GetBookmarksBefore() = %+v, %v, want the oldest", next, err)
+			}
+			inCollection := uuid.NullUUID{UUID: reading.ID, Valid: true}
+			if list, _ := s.GetBookmarks(ctx, database.GetBookmarksParams{UserID: alice.ID, CollectionID: inCollection, MaxCount: 10}); len(list) != 1 {
+				t.Errorf("GetBookmarks() of the collection = %+v, want 1", list)
+			}
+			if list, _ := s.GetBookmarks(ctx, database.GetBookmarksParams{UserID: bob.ID, MaxCount: 10}); len(list) != 0 {
+				t.Errorf("GetBookmarks() of another user = %+v, want none", list)
+			}
+
+			// deleting the collection keeps its bookmarks, deleting a chirp removes them
+			if n, err := s.DeleteCollection(ctx, database.DeleteCollectionParams{ID: reading.ID, UserID: bob.ID}); err != nil || n != 0 {
+				t.Errorf("DeleteCollection() by another user = %d, %v", n, err)
+			}
+			if n, err := s.DeleteCollection(ctx, database.DeleteCollectionParams{ID: reading.ID, UserID: alice.ID}); err != nil || n != 1 {
+				t.Errorf("DeleteCollection() = %d, %v", n, err)
+			}
+			s.DeleteChirpByID(ctx, chirps[2].ID)
+			list, _ := s.GetBookmarks(ctx, database.GetBookmarksParams{UserID: alice.ID, MaxCount: 10})
+			if len(list) != 2 || list[1].CollectionID.Valid {
+				t.Errorf("GetBookmarks() after deleting a collection and a chirp = %+v", list)
+			}
+			if n, err := s.DeleteBookmark(ctx, database.DeleteBookmarkParams{UserID: alice.ID, ChirpID: chirps[1].ID}); err != nil || n != 1 {
+				t.Errorf("DeleteBookmark() = %d, %v", n, err)
+			}
+
+			s.DeleteUser(ctx, bob.ID)
+			if list, _ := s.GetBookmarks(ctx, database.GetBookmarksParams{UserID: alice.ID, MaxCount: 10}); len(list) != 0 {
+				t.Errorf("GetBookmarks() after the author was deleted = %+v, want none", list)
+			}
+		})
+	}
+}
+
+func TestStorePins(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+
+			var chirps []database.Chirp
+			for _, body := range []string{"one", "two", "three"} {
+				c, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: alice.ID, Visibility: "public"})
+				chirps = append(chirps, c)
+			}
+			for _, c := range chirps[:2] {
+				if n, err := s.PinChirp(ctx, database.PinChirpParams{ChirpID: c.ID, UserID: alice.ID, MaxCount: 2}); err != nil || n != 1 {
+					t.Fatalf("PinChirp() = %d, %v", n, err)
+				}
+			}
+			// pinning again changes nothing, and there are no more pins than max_count
+			if n, err := s.PinChirp(ctx, database.PinChirpParams{ChirpID: chirps[0].ID, UserID: alice.ID, MaxCount: 3}); err != nil || n != 0 {
+				t.Errorf("PinChirp() again = %d, %v, want 0", n, err)
+			}
+			if n, err := s.PinChirp(ctx, database.PinChirpParams{ChirpID: chirps[2].ID, UserID: alice.ID, MaxCount: 2}); err != nil || n != 0 {
+				t.Errorf("PinChirp() over max_count = %d, %v, want 0", n, err)
+			}
+			_, err := s.PinChirp(ctx, database.PinChirpParams{ChirpID: uuid.New(), UserID: alice.ID, MaxCount: 3})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("PinChirp() of a missing chirp error = %v", err)
+			}
+
+			ids, err := s.GetPinnedChirpIDs(ctx, alice.ID)
+			if err != nil || len(ids) != 2 || ids[0] != chirps[1].ID {
+				t.Errorf("GetPinnedChirpIDs() = %v, %v, want the last pinned first", ids, err)
+			}
+			if pinned, err := s.IsPinned(ctx, chirps[2].ID); err != nil || pinned {
+				t.Errorf("IsPinned() of an unpinned chirp = %v, %v", pinned, err)
+			}
+			pins, err := s.GetPinsForChirps(ctx, []uuid.UUID{chirps[2].ID, chirps[1].ID})
+			if err != nil || len(pins) != 1 || pins[0].ChirpID != chirps[1].ID || pins[0].UserID != alice.ID {
+				t.Errorf("GetPinsForChirps() = %+v, %v, want the pinned one", pins, err)
+			}
+
+			if n, err := s.UnpinChirp(ctx, database.UnpinChirpParams{ChirpID: chirps[0].ID, UserID: bob.ID}); err != nil || n != 0 {
+				t.Errorf("UnpinChirp() by another user = %d, %v", n, err)
+			}
+			if n, err := s.UnpinChirp(ctx, database.UnpinChirpParams{ChirpID: chirps[0].ID, UserID: alice.ID}); err != nil || n != 1 {
+				t.Errorf("UnpinChirp() = %d, %v", n, err)
+			}
+			s.DeleteChirpByID(ctx, chirps[1].ID)
+			if ids, _ := s.GetPinnedChirpIDs(ctx, alice.ID); len(ids) != 0 {
+				t.Errorf("GetPinnedChirpIDs() after unpinning and deleting = %v, want none", ids)
+			}
+		})
+	}
+}
+
+func TestStorePinLimitConcurrently(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+
+			var wg sync.WaitGroup
+			for range 10 {
+				c, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "pin me", UserID: alice.ID, Visibility: "public"})
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.PinChirp(ctx, database.PinChirpParams{ChirpID: c.ID, UserID: alice.ID, MaxCount: 3})
+					if err != nil {
+						t.Errorf("PinChirp() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			if ids, _ := s.GetPinnedChirpIDs(ctx, alice.ID); len(ids) != 3 {
+				t.Errorf("GetPinnedChirpIDs() after pinning concurrently = %v, want 3 chirps", ids)
+			}
+		})
+	}
+}
+
+func TestStoreContentWarnings(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+
+			warned, err := s.CreateChirp(ctx, database.CreateChirpParams{
+				Body:           "spoilers",
+				UserID:         alice.ID,
+				ContentWarning: sql.NullString{String: "film ending", Valid: true},
+				Sensitive:      true,
+				Visibility:     "public",
+			})
+			if err != nil || warned.ContentWarning.String != "film ending" || !warned.Sensitive {
+				t.Fatalf("CreateChirp() with a warning = %+v, %v", warned, err)
+			}
+			plain, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: alice.ID, Visibility: "public"})
+			if plain.ContentWarning.Valid || plain.Sensitive {
+				t.Errorf("CreateChirp() defaults = %+v", plain)
+			}
+
+			changed, err := s.SetChirpWarning(ctx, database.SetChirpWarningParams{
+				ID:             plain.ID,
+				ContentWarning: sql.NullString{String: "loud", Valid: true},
+			})
+			if err != nil || changed.ContentWarning.String != "loud" || changed.Sensitive || changed.Body != "hello" {
+				t.Errorf("SetChirpWarning() = %+v, %v", changed, err)
+			}
+			if got, _ := s.GetChirpByID(ctx, warned.ID); got.ContentWarning != warned.ContentWarning || !got.Sensitive {
+				t.Errorf("GetChirpByID() = %+v, want the warning", got)
+			}
+			if _, err := s.SetChirpWarning(ctx, database.SetChirpWarningParams{ID: uuid.New()}); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("SetChirpWarning() of a missing chirp error = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestStoreFollowsAndVisibility(t *testing.T) {
+	for name, s := range getTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s.DeleteAllUsers(ctx)
+			alice, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "hash"})
+			bob, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com", HashedPassword: "hash"})
+			carol, _ := s.CreateUser(ctx, database.CreateUserParams{Email: "carol@example.com", HashedPassword: "hash"})
+
+			chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "friends only", UserID: alice.ID, Visibility: "followers"})
+			if err != nil || chirp.Visibility != "followers" {
+				t.Fatalf("CreateChirp() = %+v, %v", chirp, err)
+			}
+			if got, _ := s.GetChirpByID(ctx, chirp.ID); got.Visibility != "followers" {
+				t.Errorf("GetChirpByID() visibility = %q, want followers", got.Visibility)
+			}
+			_, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: alice.ID, Visibility: "secret"})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("CreateChirp() with an unknown visibility error = %v", err)
+			}
+
+			// following twice is fine, the second time changes nothing
+			n, err := s.FollowUser(ctx, database.FollowUserParams{UserID: bob.ID, FollowedID: alice.ID})
+			if err != nil || n != 1 {
+				t.Fatalf("FollowUser() = %d, %v, want 1", n, err)
+			}
+			n, _ = s.FollowUser(ctx, database.FollowUserParams{UserID: bob.ID, FollowedID: alice.ID})
+			if n != 0 {
+				t.Errorf("FollowUser() again = %d, want 0", n)
+			}
+			_, err = s.FollowUser(ctx, database.FollowUserParams{UserID: bob.ID, FollowedID: bob.ID})
+			if c, ok := AsConstraintError(err); !ok || c.Code != CheckViolation {
+				t.Errorf("FollowUser() of themselves error = %v", err)
+			}
+			_, err = s.FollowUser(ctx, database.FollowUserParams{UserID: bob.ID, FollowedID: uuid.New()})
+			if c, ok := AsConstraintError(err); !ok || c.Code != ForeignKeyViolation {
+				t.Errorf("FollowUser() of a missing user error = %v", err)
+			}
+
+			following, err := s.IsFollowing(ctx, database.IsFollowingParams{UserID: bob.ID, FollowedID: alice.ID})
+			if err != nil || !following {
+				t.Errorf("IsFollowing() = %v, %v, want true", following, err)
+			}
+			following, _ = s.IsFollowing(ctx, database.IsFollowingParams{UserID: alice.ID, FollowedID: bob.ID})
+			if following {
+				t.Errorf("IsFollowing() the other way = true, follows only go one way")
+			}
+
+			s.FollowUser(ctx, database.FollowUserParams{UserID: bob.ID, FollowedID: carol.ID})
+			followed, err := s.GetFollowedUserIDs(ctx, bob.ID)
+			if err != nil || len(followed) != 2 {
+				t.Errorf("GetFollowedUserIDs() = %v, %v", followed, err)
+			}
+
+			n, err = s.UnfollowUser(ctx, database.UnfollowUserParams{UserID: bob.ID, FollowedID: alice.ID})
+			if err != nil || n != 1 {
+				t.Errorf("UnfollowUser() = %d, %v, want 1", n, err)
+			}
+			n, _ = s.UnfollowUser(ctx, database.UnfollowUserParams{UserID: bob.ID, FollowedID: alice.ID})
+			if n != 0 {
+				t.Errorf("UnfollowUser() again = %d, want 0", n)
+			}
+
+			s.DeleteUser(ctx, carol.ID)
+			followed, _ = s.GetFollowedUserIDs(ctx, bob.ID)
+			if len(followed) != 0 {
+				t.Errorf("GetFollowedUserIDs() after DeleteUser() = %v", followed)
+			}
+		})
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"sync/atomic"
 
-	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/blob"
+	"github.com/zelieen/Chirpy/internal/events"
+	"github.com/zelieen/Chirpy/internal/store"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -15,23 +23,60 @@ import (
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
-	db             *database.Queries
+	db             store.Store
+	events         events.Bus
+	blobs          blob.BlobStore
 	platform       string
 	secret         string
 	polkaKey       string
 }
 
+//go:embed sql/sqlite/schema/*.sql
+var sqliteMigrations embed.FS
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 
-	// load all the environment values
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+	// STORAGE in the environment sets the default, the flag wins
+	defaultStorage := os.Getenv("STORAGE")
+	if defaultStorage == "" {
+		defaultStorage = "postgres"
+	}
+	storage := flag.String("storage", defaultStorage, "where to store data: postgres, sqlite or memory")
+	flag.Parse()
+
+	// connect to the storage
+	db, err := openStore(*storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// connect to the other server processes
+	bus, err := openEventBus(*storage)
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	// where uploads are kept
+	blobs, err := openBlobStore(os.Getenv("MEDIA_STORAGE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// run an admin command instead of the server
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "admin" {
+		admin := &apiConfig{db: db, events: bus, blobs: blobs}
+		err = runAdmin(context.Background(), admin, os.Stdout, args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	platform := os.Getenv("PLATFORM")
 	if platform == "" {
 		log.Fatal("PLATFORM must be set")
@@ -45,58 +90,100 @@ func main() {
 		log.Fatal("POLKA_KEY must be set")
 	}
 
-	// connect to the database
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		log.Fatalf("Error opening the database: %s", err)
-	}
-	dbQueries := database.New(db)
-
 	const filepathRoot = "."
 	const port = "8080"
 
 	// set config
 	cfg := apiConfig{
 		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
+		db:             db,
+		events:         bus,
+		blobs:          blobs,
 		platform:       platform,
 		secret:         secret,
 		polkaKey:       polkaKey,
 	}
 
 	// set server
-	ServeMux := http.NewServeMux()
-	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
-	ServeMux.Handle("/app/", cfg.middlewareMetricsInc(appHandler))
-
-	ServeMux.HandleFunc("GET /api/healthz", readyHandler)
-	ServeMux.HandleFunc("GET /admin/metrics", cfg.metricHandler)
-	ServeMux.HandleFunc("POST /admin/reset", cfg.resetHandler)
-	ServeMux.HandleFunc("POST /api/chirps", cfg.chirpHandler)
-	ServeMux.HandleFunc("GET /api/chirps", cfg.chirpListHandler)
-	ServeMux.HandleFunc("GET /api/chirps/{chirpID}", cfg.chirpGetHandler)
-	ServeMux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.chirpDeleteHandler)
-	ServeMux.HandleFunc("POST /api/users", cfg.userHandler)
-	ServeMux.HandleFunc("PUT /api/users", cfg.updateUserHandler)
-	ServeMux.HandleFunc("POST /api/login", cfg.loginHandler)
-	ServeMux.HandleFunc("POST /api/refresh", cfg.refreshHandler)
-	ServeMux.HandleFunc("POST /api/revoke", cfg.revokeHandler)
-	ServeMux.HandleFunc("POST /api/polka/webhooks", cfg.polkaHandler)
-
 	Server := &http.Server{
-		Handler: ServeMux,
+		Handler: cfg.newServeMux(filepathRoot),
 		Addr:    ":" + port,
 	}
 
+	// publish scheduled chirps when they are due
+	go cfg.runScheduler(context.Background(), schedulerInterval)
+
 	// start the server
 	log.Printf("Serving from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(Server.ListenAndServe())
 }
 
-// this is building a nameless return function to inject a nameless function that builds a handler after it increased the fileserverHits
-func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
-		next.ServeHTTP(w, r)
-	})
+// openStore connects to the storage backend, postgres needs DB_URL and sqlite uses SQLITE_PATH
+func openStore(storage string) (store.Store, error) {
+	switch storage {
+	case "postgres":
+		dbURL := os.Getenv("DB_URL")
+		if dbURL == "" {
+			return nil, errors.New("DB_URL must be set")
+		}
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return nil, fmt.Errorf("error opening the database: %w", err)
+		}
+		return store.NewPostgres(db), nil
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "chirpy.db"
+		}
+		migrations, err := fs.Sub(sqliteMigrations, "sql/sqlite/schema")
+		if err != nil {
+			return nil, err
+		}
+		return store.OpenSQLite(context.Background(), path, migrations)
+	case "memory":
+		log.Println("Using in-memory storage, all data is lost on exit")
+		return store.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage: '%s'", storage)
+	}
+}
+
+// openEventBus connects to the event bus, with postgres storage events reach the
+// subscribers of all server processes on the same database
+func openEventBus(storage string) (events.Bus, error) {
+	local := events.NewLocal(eventHistorySize, streamBufferSize)
+	if storage != "postgres" {
+		return local, nil
+	}
+	return events.NewPostgres(os.Getenv("DB_URL"), local)
+}
+
+// openBlobStore returns the store for uploads, local keeps them in MEDIA_DIR and s3 needs the S3_* settings
+func openBlobStore(storage string) (blob.BlobStore, error) {
+	switch storage {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		return blob.NewLocal(dir), nil
+	case "s3":
+		cfg := blob.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, errors.New("S3_ENDPOINT and S3_BUCKET must be set")
+		}
+		if cfg.Region == "" {
+			cfg.Region = "us-east-1"
+		}
+		return blob.NewS3(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown media storage: '%s'", storage)
+	}
 }
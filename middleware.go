@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+)
+
+// this is building a nameless return function to inject a nameless function that builds a handler after it increased the fileserverHits
+func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.fileserverHits.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate reads the access token of a request, it returns false if there is no token at all
+func (cfg *apiConfig) authenticate(r *http.Request) (auth.Principal, bool, error) {
+	if r.Header.Get("Authorization") == "" {
+		return auth.Principal{}, false, nil
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return auth.Principal{}, true, err
+	}
+	claims, err := auth.ValidateJWTClaims(token, cfg.secret)
+	if err != nil {
+		return auth.Principal{}, true, err
+	}
+	principal, err := auth.NewPrincipal(claims)
+	if err != nil {
+		return auth.Principal{}, true, err
+	}
+	return principal, true, nil
+}
+
+// requireAuth only calls next for requests with a valid access token and stores the caller in the request context
+func (cfg *apiConfig) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, found, err := cfg.authenticate(r)
+		if !found {
+			respondUnauthorized(w, CodeMissingToken, "Missing access token", nil)
+			return
+		}
+		if err != nil {
+			log.Printf("Invalid token: %s", err)
+			respondUnauthorized(w, CodeInvalidToken, "Invalid access token", err)
+			return
+		}
+		next(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+	}
+}
+
+// optionalAuth lets anonymous requests through, but still rejects requests with an invalid access token
+func (cfg *apiConfig) optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, found, err := cfg.authenticate(r)
+		if !found {
+			next(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Invalid token: %s", err)
+			respondUnauthorized(w, CodeInvalidToken, "Invalid access token", err)
+			return
+		}
+		next(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+	}
+}
+
+// only lets a request through to the next handler if its access token carries at least the required role
+func (cfg *apiConfig) middlewareRequireRole(required auth.Role, next http.Handler) http.Handler {
+	return cfg.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		if !principal.HasRole(required) {
+			respondWithError(w, http.StatusForbidden, CodeForbidden, "Not allowed", fmt.Errorf("user %s is not %s", principal.UserID, required))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
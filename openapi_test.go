@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPIDocument(t *testing.T) openAPIDocument {
+	t.Helper()
+	doc := openAPIDocument{}
+	err := json.Unmarshal(openAPISpec, &doc)
+	if err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	documented := []string{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch":
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	cfg := &apiConfig{}
+	registered := []string{}
+	for _, rt := range append(cfg.getAPIRoutes(), cfg.getAdminRoutes()...) {
+		registered = append(registered, rt.pattern)
+	}
+
+	for _, pattern := range registered {
+		if !slices.Contains(documented, pattern) {
+			t.Errorf("route %q is registered but missing from openapi.json", pattern)
+		}
+	}
+	for _, pattern := range documented {
+		if !slices.Contains(registered, pattern) {
+			t.Errorf("route %q is in openapi.json but not registered", pattern)
+		}
+	}
+}
+
+// jsonFields returns the JSON names of all fields of a struct and whether they are always present
+func jsonFields(typ reflect.Type) map[string]bool {
+	fields := map[string]bool{}
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			for n, always := range jsonFields(f.Type) {
+				fields[n] = always
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = !strings.Contains(opts, "omitempty")
+	}
+	return fields
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	tests := []struct {
+		schema string
+		value  any
+	}{
+		{"User", User{}},
+		{"Profile", Profile{}},
+		{"Chirp", Chirp{}},
+		{"Media", Media{}},
+		{"Draft", Draft{}},
+		{"Poll", Poll{}},
+		{"PollOption", PollOption{}},
+		{"Bookmark", Bookmark{}},
+		{"BookmarkList", bookmarksResponse{}},
+		{"Collection", Collection{}},
+		{"LoginResponse", loginResponse{}},
+		{"TokenResponse", refreshResponse{}},
+		{"Problem", problem{}},
+		{"FieldError", FieldError{}},
+		{"Notification", Notification{}},
+		{"NotificationList", notificationsResponse{}},
+		{"MarkReadResponse", markReadResponse{}},
+		{"Conversation", Conversation{}},
+		{"ConversationMember", ConversationMember{}},
+		{"Message", Message{}},
+		{"MessageList", messagesResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("schema %s is missing from openapi.json", tt.schema)
+			}
+			fields := jsonFields(reflect.TypeOf(tt.value))
+			for name, always := range fields {
+				if _, ok := schema.Properties[name]; !ok {
+					t.Errorf("field %q is missing from schema %s", name, tt.schema)
+				}
+				if always && !slices.Contains(schema.Required, name) {
+					t.Errorf("field %q is always sent but not required in schema %s", name, tt.schema)
+				}
+			}
+			for name := range schema.Properties {
+				if _, ok := fields[name]; !ok {
+					t.Errorf("schema %s documents %q which the struct does not have", tt.schema, name)
+				}
+			}
+		})
+	}
+}
@@ -2,23 +2,68 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
+// problem is an RFC 7807 error response body
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   ErrorCode    `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+func respondWithError(w http.ResponseWriter, status int, code ErrorCode, msg string, err error) {
+	respondWithAPIError(w, newAPIError(status, code, msg, err))
+}
+
+// respondWithAPIError responds with the APIError in err, any other error is an internal error
+func respondWithAPIError(w http.ResponseWriter, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = newAPIError(http.StatusInternalServerError, CodeInternal, "Internal server error", err)
 	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+	if apiErr.Err != nil {
+		log.Println(apiErr.Err)
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+	if apiErr.Status > 499 {
+		log.Printf("Responding with 5XX error: %s", apiErr.Message)
 	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
+
+	dat, err := json.Marshal(problem{
+		Type:   "urn:chirpy:problem:" + string(apiErr.Code),
+		Title:  http.StatusText(apiErr.Status),
+		Status: apiErr.Status,
+		Detail: apiErr.Message,
+		Code:   apiErr.Code,
+		Errors: apiErr.Fields,
 	})
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	w.Write(dat)
+}
+
+func respondWithDBError(w http.ResponseWriter, err error, msg string) {
+	respondWithAPIError(w, translateDBError(err, msg))
+}
+
+// respondUnauthorized tells the client how to authenticate, as required for 401 responses
+func respondUnauthorized(w http.ResponseWriter, code ErrorCode, msg string, err error) {
+	challenge := `Bearer realm="chirpy"`
+	if code != CodeMissingToken {
+		challenge += `, error="invalid_token"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, http.StatusUnauthorized, code, msg, err)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
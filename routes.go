@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/zelieen/Chirpy/internal/auth"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// newServeMux builds the handler for all routes of the server, static files are served from filepathRoot
+func (cfg *apiConfig) newServeMux(filepathRoot string) *http.ServeMux {
+	ServeMux := http.NewServeMux()
+	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
+	ServeMux.Handle("/app/", cfg.middlewareMetricsInc(appHandler))
+	ServeMux.HandleFunc("GET /media/{key...}", cfg.mediaFileHandler)
+	ServeMux.Handle("GET /swagger-ui/", http.StripPrefix("/swagger-ui", http.FileServerFS(swaggerFiles.FS)))
+
+	for _, rt := range cfg.getAPIRoutes() {
+		ServeMux.HandleFunc(rt.pattern, rt.handler)
+	}
+
+	// admin routes all require the admin role
+	adminMux := http.NewServeMux()
+	for _, rt := range cfg.getAdminRoutes() {
+		adminMux.HandleFunc(rt.pattern, rt.handler)
+	}
+	ServeMux.Handle("/admin/", cfg.middlewareRequireRole(auth.RoleAdmin, adminMux))
+
+	return ServeMux
+}
+
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// getAPIRoutes lists every /api route, openapi.json has to describe all of them
+func (cfg *apiConfig) getAPIRoutes() []route {
+	return []route{
+		{"GET /api/healthz", readyHandler},
+		{"GET /api/openapi.json", openAPIHandler},
+		{"GET /api/docs", docsHandler},
+		{"POST /api/chirps", cfg.requireAuth(cfg.chirpHandler)},
+		{"GET /api/chirps", cfg.optionalAuth(cfg.chirpListHandler)},
+		{"GET /api/chirps/stream", cfg.chirpStreamHandler},
+		{"GET /api/chirps/scheduled", cfg.requireAuth(cfg.scheduledChirpsHandler)},
+		// the id is a query parameter, DELETE /api/chirps/scheduled/{chirpID} would conflict with the bookmark route
+		{"DELETE /api/chirps/scheduled", cfg.requireAuth(cfg.scheduledChirpCancelHandler)},
+		{"POST /api/chirps/scheduled/{chirpID}/publish", cfg.requireAuth(cfg.scheduledChirpPublishHandler)},
+		{"GET /api/ws", cfg.requireAuth(cfg.websocketHandler)},
+		{"GET /api/chirps/{chirpID}", cfg.optionalAuth(cfg.chirpGetHandler)},
+		{"DELETE /api/chirps/{chirpID}", cfg.requireAuth(cfg.chirpDeleteHandler)},
+		{"PUT /api/chirps/{chirpID}/warning", cfg.requireAuth(cfg.chirpWarningHandler)},
+		{"POST /api/chirps/{chirpID}/poll/votes", cfg.requireAuth(cfg.pollVoteHandler)},
+		{"POST /api/chirps/{chirpID}/bookmark", cfg.requireAuth(cfg.bookmarkHandler)},
+		{"DELETE /api/chirps/{chirpID}/bookmark", cfg.requireAuth(cfg.bookmarkDeleteHandler)},
+		{"GET /api/bookmarks", cfg.requireAuth(cfg.bookmarkListHandler)},
+		{"POST /api/collections", cfg.requireAuth(cfg.collectionCreateHandler)},
+		{"GET /api/collections", cfg.requireAuth(cfg.collectionListHandler)},
+		{"PUT /api/collections/{collectionID}", cfg.requireAuth(cfg.collectionUpdateHandler)},
+		{"DELETE /api/collections/{collectionID}", cfg.requireAuth(cfg.collectionDeleteHandler)},
+		{"POST /api/drafts", cfg.requireAuth(cfg.draftCreateHandler)},
+		{"GET /api/drafts", cfg.requireAuth(cfg.draftListHandler)},
+		{"PUT /api/drafts/{draftID}", cfg.requireAuth(cfg.draftUpdateHandler)},
+		{"DELETE /api/drafts/{draftID}", cfg.requireAuth(cfg.draftDeleteHandler)},
+		{"POST /api/drafts/{draftID}/publish", cfg.requireAuth(cfg.draftPublishHandler)},
+		{"POST /api/users", cfg.userHandler},
+		{"PUT /api/users", cfg.requireAuth(cfg.updateUserHandler)},
+		{"GET /api/users/{handleOrID}", cfg.profileHandler},
+		{"PUT /api/users/me/avatar", cfg.requireAuth(cfg.avatarHandler)},
+		{"PUT /api/users/me/pins/{chirpID}", cfg.requireAuth(cfg.pinHandler)},
+		{"DELETE /api/users/me/pins/{chirpID}", cfg.requireAuth(cfg.unpinHandler)},
+		{"POST /api/media", cfg.requireAuth(cfg.mediaUploadHandler)},
+		{"POST /api/login", cfg.loginHandler},
+		{"POST /api/refresh", cfg.refreshHandler},
+		{"POST /api/revoke", cfg.revokeHandler},
+		{"POST /api/users/{userID}/block", cfg.requireAuth(cfg.blockHandler)},
+		{"DELETE /api/users/{userID}/block", cfg.requireAuth(cfg.unblockHandler)},
+		{"POST /api/users/{userID}/mute", cfg.requireAuth(cfg.muteHandler)},
+		{"DELETE /api/users/{userID}/mute", cfg.requireAuth(cfg.unmuteHandler)},
+		{"POST /api/users/{userID}/follow", cfg.requireAuth(cfg.followHandler)},
+		{"DELETE /api/users/{userID}/follow", cfg.requireAuth(cfg.unfollowHandler)},
+		{"POST /api/polka/webhooks", cfg.polkaHandler},
+		{"GET /api/notifications", cfg.requireAuth(cfg.notificationsHandler)},
+		{"POST /api/notifications/read", cfg.requireAuth(cfg.notificationsReadHandler)},
+		{"GET /api/notifications/preferences", cfg.requireAuth(cfg.notificationPreferencesHandler)},
+		{"PUT /api/notifications/preferences", cfg.requireAuth(cfg.updateNotificationPreferencesHandler)},
+		{"POST /api/conversations", cfg.requireAuth(cfg.conversationCreateHandler)},
+		{"GET /api/conversations", cfg.requireAuth(cfg.conversationListHandler)},
+		{"GET /api/conversations/{conversationID}", cfg.requireAuth(cfg.conversationGetHandler)},
+		{"POST /api/conversations/{conversationID}/read", cfg.requireAuth(cfg.conversationReadHandler)},
+		{"GET /api/conversations/{conversationID}/messages", cfg.requireAuth(cfg.messageListHandler)},
+		{"POST /api/conversations/{conversationID}/messages", cfg.requireAuth(cfg.messageCreateHandler)},
+	}
+}
+
+// getAdminRoutes lists every /admin route, they are all served behind the admin role check
+func (cfg *apiConfig) getAdminRoutes() []route {
+	return []route{
+		{"GET /admin/metrics", cfg.metricHandler},
+		{"POST /admin/reset", cfg.resetHandler},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zelieen/Chirpy/internal/database"
+	"github.com/zelieen/Chirpy/internal/events"
+
+	"github.com/google/uuid"
+)
+
+const (
+	schedulerInterval = 10 * time.Second
+	// schedulerBatchSize is how many chirps one query publishes, the rest follow in the same run
+	schedulerBatchSize = 100
+)
+
+// runScheduler publishes due chirps until ctx is done. Every server process runs it,
+// PublishDueChirps skips the chirps another process is publishing, so each is announced once.
+func (cfg *apiConfig) runScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg.publishDueChirps(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDueChirps publishes the chirps scheduled up to now and announces them like new chirps
+func (cfg *apiConfig) publishDueChirps(ctx context.Context, now time.Time) {
+	for {
+		due, err := cfg.db.PublishDueChirps(ctx, database.PublishDueChirpsParams{
+			Now:      now.UTC(),
+			MaxCount: schedulerBatchSize,
+		})
+		if err != nil {
+			log.Printf("Error publishing scheduled chirps: %s", err)
+			return
+		}
+		for _, c := range due {
+			// the chirp is published already, so it is announced even if what is attached to it can't be read
+			chirp, err := cfg.makeChirp(ctx, c, uuid.Nil)
+			if err != nil {
+				log.Printf("Error building scheduled chirp %s, announcing it without media, poll and pin: %s", c.ID, err)
+				chirp = bareChirp(c)
+			}
+			cfg.publish(ctx, events.ChirpCreated, c.UserID, chirp)
+		}
+		if len(due) < schedulerBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (user_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE user_id = $1
+AND blocked_id = $2;
+
+-- name: HasBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_id = $1
+    AND blocked_id = $2
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (user_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM user_mutes
+WHERE user_id = $1
+AND muted_id = $2;
+
+-- name: GetHiddenUserIDs :many
+SELECT blocked_id FROM user_blocks
+WHERE user_blocks.user_id = $1
+UNION
+SELECT muted_id FROM user_mutes
+WHERE user_mutes.user_id = $1;
@@ -0,0 +1,69 @@
+-- name: SaveBookmark :one
+INSERT INTO bookmarks (id, created_at, user_id, chirp_id, collection_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+AND chirp_id = $2;
+
+-- name: GetBookmarks :many
+SELECT * FROM bookmarks
+WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(collection_id)::uuid IS NULL OR collection_id = sqlc.narg(collection_id))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_count);
+
+-- name: GetBookmarksBefore :many
+SELECT * FROM bookmarks
+WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(collection_id)::uuid IS NULL OR collection_id = sqlc.narg(collection_id))
+AND (created_at, id) < (
+    SELECT b.created_at, b.id FROM bookmarks b
+    WHERE b.id = sqlc.arg(id)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_count);
+
+-- name: CreateCollection :one
+INSERT INTO collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetCollectionByID :one
+SELECT * FROM collections
+WHERE id = $1
+AND user_id = $2;
+
+-- name: GetCollectionsForUser :many
+SELECT * FROM collections
+WHERE user_id = $1
+ORDER BY LOWER(name), id;
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name = $3,
+updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteCollection :execrows
+DELETE FROM collections
+WHERE id = $1
+AND user_id = $2;
@@ -1,14 +1,62 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
+-- name: PostChirp :one
+WITH available AS (
+    -- the uploads stay locked until the chirp holding them is stored
+    SELECT id FROM media
+    WHERE id = ANY(sqlc.arg(media_ids)::uuid[])
+    AND user_id = sqlc.arg(user_id)::uuid
+    AND chirp_id IS NULL
+    FOR UPDATE
+), chirp AS (
+    INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility)
+    SELECT
+        gen_random_uuid(),
+        NOW(),
+        NOW(),
+        sqlc.arg(body)::text,
+        sqlc.arg(user_id)::uuid,
+        sqlc.narg(publish_at)::timestamp,
+        sqlc.narg(content_warning)::text,
+        sqlc.arg(sensitive)::boolean,
+        sqlc.arg(visibility)::text
+    -- nothing is stored if one of the uploads is missing or attached to another chirp
+    WHERE (SELECT COUNT(*) FROM available) = COALESCE(CARDINALITY(sqlc.arg(media_ids)::uuid[]), 0)
+    RETURNING *
+), attached AS (
+    -- the uploads are stored at their position in the list, counting from 0
+    UPDATE media
+    SET chirp_id = chirp.id,
+    position = (ARRAY_POSITION(sqlc.arg(media_ids)::uuid[], media.id) - 1)::integer
+    FROM chirp
+    WHERE media.id IN (SELECT id FROM available)
+), poll AS (
+    INSERT INTO polls (chirp_id, created_at, closes_at, multiple_choice)
+    SELECT chirp.id, NOW(), sqlc.narg(poll_closes_at)::timestamp, sqlc.arg(poll_multiple_choice)::boolean
+    FROM chirp
+    WHERE sqlc.narg(poll_closes_at)::timestamp IS NOT NULL
+    RETURNING chirp_id
+), options AS (
+    -- the options are stored at their position in the list, counting from 0
+    INSERT INTO poll_options (chirp_id, position, text)
+    SELECT poll.chirp_id, (o.n - 1)::integer, o.text
+    FROM poll, UNNEST(sqlc.arg(poll_options)::text[]) WITH ORDINALITY AS o(text, n)
+)
+SELECT id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility FROM chirp;
+
 -- name: GetChirpList :many
 SELECT * FROM chirps
 ORDER BY created_at ASC;
@@ -22,6 +70,56 @@ ORDER BY created_at ASC;
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+ORDER BY created_at ASC;
+
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND publish_at IS NOT NULL
+ORDER BY publish_at ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1
+AND user_id = $2
+AND publish_at IS NOT NULL;
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET publish_at = NULL,
+created_at = NOW(),
+updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE publish_at <= sqlc.arg(now)::timestamp
+    ORDER BY publish_at
+    LIMIT sqlc.arg(max_count)
+    -- replicas running the scheduler at the same time each take different chirps
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET publish_at = NULL,
+created_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND publish_at IS NOT NULL
+RETURNING *;
+
+-- name: SetChirpWarning :one
+UPDATE chirps
+SET updated_at = NOW(),
+content_warning = $2,
+sensitive = $3
+WHERE id = $1
+RETURNING *;
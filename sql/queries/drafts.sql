@@ -0,0 +1,47 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetDraftsForUser :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC, id DESC;
+
+-- name: GetDraftByID :one
+SELECT * FROM drafts
+WHERE id = $1
+AND user_id = $2;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $3,
+updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1
+AND user_id = $2;
+
+-- name: PublishDraft :one
+WITH draft AS (
+    DELETE FROM drafts
+    WHERE drafts.id = sqlc.arg(id)
+    AND drafts.user_id = sqlc.arg(user_id)
+    -- nothing is published if the draft changed since it was validated
+    AND drafts.body = sqlc.arg(draft_body)
+    RETURNING drafts.user_id
+)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg(body), draft.user_id
+FROM draft
+RETURNING *;
@@ -0,0 +1,93 @@
+-- name: StartConversation :one
+WITH conversation AS (
+    INSERT INTO conversations (id, created_at, updated_at, direct_key)
+    VALUES (
+        gen_random_uuid(),
+        NOW(),
+        NOW(),
+        sqlc.narg(direct_key)
+    )
+    -- a direct conversation that exists already is returned with created false, and keeps its members
+    ON CONFLICT (direct_key) DO UPDATE
+    SET direct_key = EXCLUDED.direct_key
+    RETURNING id, created_at, updated_at, direct_key, (xmax = 0) AS created
+), members AS (
+    INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+    SELECT conversation.id, member_id, NOW()
+    FROM conversation, UNNEST(sqlc.arg(member_ids)::uuid[]) AS member_id
+    WHERE conversation.created
+)
+SELECT id, created_at, updated_at, direct_key, created FROM conversation;
+
+-- name: GetConversationByID :one
+SELECT * FROM conversations
+WHERE id = $1;
+
+-- name: GetConversationsForUser :many
+SELECT conversations.* FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC, conversations.id DESC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+AND user_id = $2;
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at, user_id;
+
+-- name: GetMembersForConversations :many
+SELECT * FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, joined_at, user_id;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1
+AND user_id = $2;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: GetMessagesBefore :many
+SELECT * FROM messages
+WHERE conversation_id = $1
+AND (created_at, id) < (
+    SELECT c.created_at, c.id FROM messages c
+    WHERE c.id = $2
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $3;
+
+-- name: CountUnreadMessagesForConversations :many
+SELECT messages.conversation_id, COUNT(*) AS unread FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE messages.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+AND conversation_members.user_id = sqlc.arg(user_id)
+AND messages.sender_id != sqlc.arg(user_id)
+AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+-- conversations without unread messages are left out
+GROUP BY messages.conversation_id;
@@ -0,0 +1,62 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: GetNotificationsBefore :many
+SELECT * FROM notifications
+WHERE user_id = $1
+AND (created_at, id) < (
+    SELECT c.created_at, c.id FROM notifications c
+    WHERE c.id = $2
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $3;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND read_at IS NULL;
+
+-- name: MarkNotificationsReadUpTo :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL
+AND (created_at, id) <= (
+    SELECT c.created_at, c.id FROM notifications c
+    WHERE c.id = $2
+    AND c.user_id = $1
+);
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled,
+updated_at = NOW();
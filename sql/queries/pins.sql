@@ -0,0 +1,29 @@
+-- name: PinChirp :execrows
+INSERT INTO pins (chirp_id, user_id, created_at)
+SELECT sqlc.arg(chirp_id)::uuid, sqlc.arg(user_id)::uuid, NOW()
+-- nothing is pinned if the chirp is pinned already or the user has max_count pins
+WHERE (
+    SELECT COUNT(*) FROM pins
+    WHERE user_id = sqlc.arg(user_id)::uuid
+) < sqlc.arg(max_count)::bigint
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pins
+WHERE chirp_id = $1
+AND user_id = $2;
+
+-- name: IsPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pins
+    WHERE chirp_id = $1
+);
+
+-- name: GetPinsForChirps :many
+SELECT * FROM pins
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPinnedChirpIDs :many
+SELECT chirp_id FROM pins
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,48 @@
+-- name: GetPoll :one
+SELECT polls.*, (
+    SELECT COUNT(*) FROM poll_votes
+    WHERE poll_votes.chirp_id = polls.chirp_id
+) AS voter_count
+FROM polls
+WHERE polls.chirp_id = $1;
+
+-- name: GetPollResults :many
+SELECT poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.chirp_id = poll_options.chirp_id
+AND (poll_votes.choices & (1 << poll_options.position)) <> 0
+WHERE poll_options.chirp_id = $1
+GROUP BY poll_options.position, poll_options.text
+ORDER BY poll_options.position;
+
+-- name: GetPollsForChirps :many
+SELECT polls.*, (
+    SELECT COUNT(*) FROM poll_votes
+    WHERE poll_votes.chirp_id = polls.chirp_id
+) AS voter_count
+FROM polls
+WHERE polls.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollResultsForChirps :many
+SELECT poll_options.chirp_id, poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.chirp_id = poll_options.chirp_id
+AND (poll_votes.choices & (1 << poll_options.position)) <> 0
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY poll_options.chirp_id, poll_options.position, poll_options.text
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: GetPollVotesForChirps :many
+SELECT * FROM poll_votes
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+AND user_id = sqlc.arg(user_id);
+
+-- name: CreatePollVote :one
+INSERT INTO poll_votes (chirp_id, user_id, created_at, choices)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+)
+RETURNING *;
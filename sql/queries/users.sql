@@ -32,4 +32,56 @@ RETURNING *;
 UPDATE users
 SET updated_at = NOW(),
 is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET updated_at = NOW(),
+hashed_password = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: DowngradeUserFromRed :exec
+UPDATE users
+SET updated_at = NOW(),
+is_chirpy_red = false
+WHERE id = $1;
+
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(),
+role = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE LOWER(handle) = LOWER(sqlc.arg(handle));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET updated_at = NOW(),
+handle = $2,
+display_name = $3,
+bio = $4,
+avatar_url = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserSensitiveContent :one
+UPDATE users
+SET updated_at = NOW(),
+sensitive_content = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: LockUser :one
+SELECT id FROM users
+WHERE id = $1
+-- holds the row of the user until the transaction ends, so changes that count rows of the user run one after another
+FOR UPDATE;
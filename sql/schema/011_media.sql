@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE media(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	-- NULL until the upload is attached to a chirp
+	chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	content_type TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	blob_key TEXT NOT NULL,
+	thumbnail_key TEXT NOT NULL
+);
+
+CREATE INDEX media_chirp_id_idx ON media(chirp_id);
+
+-- +goose Down
+DROP TABLE media;
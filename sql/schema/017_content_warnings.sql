@@ -0,0 +1,14 @@
+-- +goose Up
+-- NULL if the chirp has no warning, moderators may set one on any chirp
+ALTER TABLE chirps ADD COLUMN content_warning TEXT;
+ALTER TABLE chirps ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- how chirps with a warning or sensitive media are shown to the user
+ALTER TABLE users
+ADD COLUMN sensitive_content TEXT NOT NULL DEFAULT 'collapse'
+CHECK (sensitive_content IN ('collapse', 'expand', 'hide'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN sensitive_content;
+ALTER TABLE chirps DROP COLUMN sensitive;
+ALTER TABLE chirps DROP COLUMN content_warning;
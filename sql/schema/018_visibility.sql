@@ -0,0 +1,18 @@
+-- +goose Up
+-- unlisted chirps are left out of listings but can be opened by id,
+-- followers-only chirps are only shown to the author and their followers
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+CHECK (visibility IN ('public', 'unlisted', 'followers'));
+
+CREATE TABLE user_follows(
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	followed_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, followed_id),
+	CHECK (user_id != followed_id)
+);
+
+-- +goose Down
+DROP TABLE user_follows;
+ALTER TABLE chirps DROP COLUMN visibility;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- events too large for NOTIFY are kept here for a minute, the notification only carries their id
+CREATE TABLE event_payloads(
+	id UUID PRIMARY KEY,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE event_payloads;
@@ -0,0 +1,69 @@
+-- name: SaveBookmark :one
+INSERT INTO bookmarks (id, created_at, user_id, chirp_id, collection_id)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3
+)
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = ?1
+AND chirp_id = ?2;
+
+-- name: GetBookmarks :many
+SELECT * FROM bookmarks
+WHERE user_id = ?1
+AND (?2 IS NULL OR collection_id = ?2)
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?3;
+
+-- name: GetBookmarksBefore :many
+SELECT * FROM bookmarks
+WHERE user_id = ?1
+AND (?2 IS NULL OR collection_id = ?2)
+AND (created_at, rowid) < (
+    SELECT b.created_at, b.rowid FROM bookmarks b
+    WHERE b.id = ?3
+)
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?4;
+
+-- name: CreateCollection :one
+INSERT INTO collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2
+)
+RETURNING *;
+
+-- name: GetCollectionByID :one
+SELECT * FROM collections
+WHERE id = ?1
+AND user_id = ?2;
+
+-- name: GetCollectionsForUser :many
+SELECT * FROM collections
+WHERE user_id = ?1
+ORDER BY LOWER(name), id;
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name = ?3,
+updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1
+AND user_id = ?2
+RETURNING *;
+
+-- name: DeleteCollection :execrows
+DELETE FROM collections
+WHERE id = ?1
+AND user_id = ?2;
\ No newline at end of file
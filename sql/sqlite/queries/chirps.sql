@@ -0,0 +1,79 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, content_warning, sensitive, visibility)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6
+)
+RETURNING *;
+
+-- name: GetChirpList :many
+SELECT * FROM chirps
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: GetChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = ?1
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: GetChirpByID :one
+SELECT * FROM chirps
+WHERE id = ?1;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id IN (SELECT value FROM json_each(?1))
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: DeleteChirpByID :exec
+DELETE FROM chirps
+WHERE id = ?1;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = ?1
+AND publish_at IS NOT NULL
+ORDER BY publish_at ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = ?1
+AND user_id = ?2
+AND publish_at IS NOT NULL;
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET publish_at = NULL,
+created_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE publish_at <= ?1
+    ORDER BY publish_at
+    LIMIT ?2
+)
+RETURNING *;
+
+-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET publish_at = NULL,
+created_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1
+AND user_id = ?2
+AND publish_at IS NOT NULL
+RETURNING *;
+
+-- name: SetChirpWarning :one
+UPDATE chirps
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+content_warning = ?2,
+sensitive = ?3
+WHERE id = ?1
+RETURNING *;
\ No newline at end of file
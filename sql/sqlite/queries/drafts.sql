@@ -0,0 +1,42 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2
+)
+RETURNING *;
+
+-- name: GetDraftsForUser :many
+SELECT * FROM drafts
+WHERE user_id = ?1
+ORDER BY updated_at DESC, id DESC;
+
+-- name: GetDraftByID :one
+SELECT * FROM drafts
+WHERE id = ?1
+AND user_id = ?2;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = ?3,
+updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1
+AND user_id = ?2
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = ?1
+AND user_id = ?2;
+
+-- name: PublishDraft :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))), strftime('%Y-%m-%d %H:%M:%f', 'now'), strftime('%Y-%m-%d %H:%M:%f', 'now'), ?4, drafts.user_id
+FROM drafts
+WHERE drafts.id = ?1
+AND drafts.user_id = ?2
+AND drafts.body = ?3
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- name: FollowUser :execrows
+INSERT INTO user_follows (user_id, followed_id, created_at)
+VALUES (?1, ?2, strftime('%Y-%m-%d %H:%M:%f', 'now'))
+ON CONFLICT (user_id, followed_id) DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM user_follows
+WHERE user_id = ?1
+AND followed_id = ?2;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+    SELECT 1 FROM user_follows
+    WHERE user_id = ?1
+    AND followed_id = ?2
+);
+
+-- name: GetFollowedUserIDs :many
+SELECT followed_id FROM user_follows
+WHERE user_id = ?1;
\ No newline at end of file
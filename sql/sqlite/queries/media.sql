@@ -0,0 +1,31 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, content_type, size, width, height, blob_key, thumbnail_key)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7
+)
+RETURNING *;
+
+-- name: GetMediaByID :one
+SELECT * FROM media
+WHERE id = ?1;
+
+-- name: GetMediaForChirps :many
+SELECT * FROM media
+WHERE chirp_id IN (SELECT value FROM json_each(?1))
+ORDER BY chirp_id, position;
+
+-- name: AttachMedia :execrows
+UPDATE media
+SET chirp_id = ?1,
+position = ?2
+WHERE id = ?3
+AND user_id = ?4
+AND chirp_id IS NULL;
\ No newline at end of file
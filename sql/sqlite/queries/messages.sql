@@ -0,0 +1,90 @@
+-- name: GetConversationByID :one
+SELECT * FROM conversations
+WHERE id = ?1;
+
+-- name: GetConversationsForUser :many
+SELECT conversations.* FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = ?1
+ORDER BY conversations.updated_at DESC, conversations.rowid DESC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1;
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = ?1
+AND user_id = ?2;
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = ?1
+ORDER BY joined_at, user_id;
+
+-- name: GetMembersForConversations :many
+SELECT * FROM conversation_members
+WHERE conversation_id IN (SELECT value FROM json_each(?1))
+ORDER BY conversation_id, joined_at, user_id;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE conversation_id = ?1
+AND user_id = ?2;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3
+)
+RETURNING *;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = ?1
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?2;
+
+-- name: GetMessagesBefore :many
+SELECT * FROM messages
+WHERE conversation_id = ?1
+AND (created_at, rowid) < (
+    SELECT c.created_at, c.rowid FROM messages c
+    WHERE c.id = ?2
+)
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?3;
+
+-- name: CountUnreadMessagesForConversations :many
+SELECT messages.conversation_id, COUNT(*) AS unread FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE messages.conversation_id IN (SELECT value FROM json_each(?1))
+AND conversation_members.user_id = ?2
+AND messages.sender_id != ?2
+AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+-- conversations without unread messages are left out
+GROUP BY messages.conversation_id;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (?1, ?2, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, direct_key)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1
+)
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations
+WHERE direct_key = ?1;
\ No newline at end of file
@@ -0,0 +1,62 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, chirp_id)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2,
+    ?3,
+    ?4
+)
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = ?1
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?2;
+
+-- name: GetNotificationsBefore :many
+SELECT * FROM notifications
+WHERE user_id = ?1
+AND (created_at, rowid) < (
+    SELECT c.created_at, c.rowid FROM notifications c
+    WHERE c.id = ?2
+)
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?3;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = ?1
+AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?1
+AND user_id = ?2
+AND read_at IS NULL;
+
+-- name: MarkNotificationsReadUpTo :execrows
+UPDATE notifications
+SET read_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = ?1
+AND read_at IS NULL
+AND (created_at, rowid) <= (
+    SELECT c.created_at, c.rowid FROM notifications c
+    WHERE c.id = ?2
+    AND c.user_id = ?1
+);
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = ?1
+ORDER BY type;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (?1, ?2, ?3, strftime('%Y-%m-%d %H:%M:%f', 'now'))
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled,
+updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
\ No newline at end of file
@@ -0,0 +1,29 @@
+-- name: PinChirp :execrows
+INSERT INTO pins (chirp_id, user_id, created_at)
+SELECT ?1, ?2, strftime('%Y-%m-%d %H:%M:%f', 'now')
+-- nothing is pinned if the chirp is pinned already or the user has max_count pins
+WHERE (
+    SELECT COUNT(*) FROM pins
+    WHERE user_id = ?2
+) < ?3
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pins
+WHERE chirp_id = ?1
+AND user_id = ?2;
+
+-- name: IsPinned :one
+SELECT EXISTS (
+    SELECT 1 FROM pins
+    WHERE chirp_id = ?1
+);
+
+-- name: GetPinsForChirps :many
+SELECT * FROM pins
+WHERE chirp_id IN (SELECT value FROM json_each(?1));
+
+-- name: GetPinnedChirpIDs :many
+SELECT chirp_id FROM pins
+WHERE user_id = ?1
+ORDER BY created_at DESC, rowid DESC;
\ No newline at end of file
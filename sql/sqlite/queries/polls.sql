@@ -0,0 +1,62 @@
+-- name: GetPoll :one
+SELECT polls.*, (
+    SELECT COUNT(*) FROM poll_votes
+    WHERE poll_votes.chirp_id = polls.chirp_id
+) AS voter_count
+FROM polls
+WHERE polls.chirp_id = ?1;
+
+-- name: GetPollResults :many
+SELECT poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.chirp_id = poll_options.chirp_id
+AND (poll_votes.choices & (1 << poll_options.position)) <> 0
+WHERE poll_options.chirp_id = ?1
+GROUP BY poll_options.position, poll_options.text
+ORDER BY poll_options.position;
+
+-- name: GetPollsForChirps :many
+SELECT polls.chirp_id, polls.created_at, polls.closes_at, polls.multiple_choice, (
+    SELECT COUNT(*) FROM poll_votes
+    WHERE poll_votes.chirp_id = polls.chirp_id
+) AS voter_count
+FROM polls
+WHERE polls.chirp_id IN (SELECT value FROM json_each(?1));
+
+-- name: GetPollResultsForChirps :many
+SELECT poll_options.chirp_id, poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.chirp_id = poll_options.chirp_id
+AND (poll_votes.choices & (1 << poll_options.position)) <> 0
+WHERE poll_options.chirp_id IN (SELECT value FROM json_each(?1))
+GROUP BY poll_options.chirp_id, poll_options.position, poll_options.text
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: GetPollVotesForChirps :many
+SELECT * FROM poll_votes
+WHERE chirp_id IN (SELECT value FROM json_each(?1))
+AND user_id = ?2;
+
+-- name: CreatePollVote :one
+INSERT INTO poll_votes (chirp_id, user_id, created_at, choices)
+VALUES (
+    ?1,
+    ?2,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?3
+)
+RETURNING *;
+
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at, multiple_choice)
+VALUES (
+    ?1,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?2,
+    ?3
+)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES (?1, ?2, ?3);
\ No newline at end of file
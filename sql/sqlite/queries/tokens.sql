@@ -0,0 +1,26 @@
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+VALUES (
+    ?1,
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?2,
+    strftime('%Y-%m-%d %H:%M:%f', 'now', '+60 days')
+);
+
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = ?1;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = ?1;
+
+-- name: RevokeAllRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = ?1
+AND revoked_at IS NULL;
\ No newline at end of file
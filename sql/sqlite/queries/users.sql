@@ -0,0 +1,80 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+    lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    ?1,
+    ?2
+)
+RETURNING *;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = ?1;
+
+-- name: GetUserByEMail :one
+SELECT * FROM users
+WHERE email = ?1;
+
+-- name: UpdateUserCredentials :one
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+email = ?2,
+hashed_password = ?3
+WHERE id = ?1
+RETURNING *;
+
+-- name: UpgradeUserToRed :exec
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+is_chirpy_red = true
+WHERE id = ?1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+hashed_password = ?2
+WHERE id = ?1
+RETURNING *;
+
+-- name: DowngradeUserFromRed :exec
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+is_chirpy_red = false
+WHERE id = ?1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+role = ?2
+WHERE id = ?1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE LOWER(handle) = LOWER(sqlc.arg(handle));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+handle = ?2,
+display_name = ?3,
+bio = ?4,
+avatar_url = ?5
+WHERE id = ?1
+RETURNING *;
+
+-- name: UpdateUserSensitiveContent :one
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+sensitive_content = ?2
+WHERE id = ?1
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+-- NULL once the chirp is published, until then only its author sees it
+ALTER TABLE chirps ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX chirps_publish_at_idx ON chirps(publish_at);
+
+-- +goose Down
+DROP INDEX chirps_publish_at_idx;
+ALTER TABLE chirps DROP COLUMN publish_at;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE polls(
+	chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	closes_at TIMESTAMP NOT NULL,
+	multiple_choice BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE TABLE poll_options(
+	chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	PRIMARY KEY (chirp_id, position)
+);
+
+-- one row per voter, so nobody votes twice; bit n of choices is set if they chose the option at position n
+CREATE TABLE poll_votes(
+	chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	choices INTEGER NOT NULL,
+	PRIMARY KEY (chirp_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
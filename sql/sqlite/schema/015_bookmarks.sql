@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE collections(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX collections_user_id_name_key ON collections (user_id, LOWER(name));
+
+-- deleting a collection keeps its bookmarks, they are only taken out of it
+CREATE TABLE bookmarks(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	collection_id UUID REFERENCES collections(id) ON DELETE SET NULL,
+	UNIQUE (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks (user_id, created_at);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE collections;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- only the author pins a chirp, so every chirp is pinned at most once
+CREATE TABLE pins(
+	chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX pins_user_id_idx ON pins(user_id);
+
+-- +goose Down
+DROP TABLE pins;
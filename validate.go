@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxRequestBytes   = 1 << 20
+	maxChirpLength    = 140
+	maxMessageLength  = 1000
+	maxDraftLength    = 1000
+	maxChirpMedia     = 4
+	maxContentWarning = 100
+	maxScheduleDays   = 365
+	minPollOptions    = 2
+	maxPollOptions    = 4
+	maxPollOption     = 25
+	minPollMinutes    = 5
+	maxPollDays       = 7
+	maxRedPollDays    = 30
+	maxCollectionName = 50
+	minHandleLength   = 3
+	maxHandleLength   = 15
+	maxDisplayName    = 50
+	maxBioLength      = 160
+	maxURLLength      = 2048
+	minPasswordLength = 8
+	maxPasswordBytes  = 72 // bcrypt ignores everything after 72 bytes
+	defaultPageSize   = 20
+	maxPageSize       = 100
+)
+
+// validation collects the field errors of a request, so clients see all of them at once
+type validation struct {
+	fields []FieldError
+}
+
+func (v *validation) add(field string, code ErrorCode, msg string) {
+	v.fields = append(v.fields, FieldError{Field: field, Code: code, Message: msg})
+}
+
+func (v *validation) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, CodeRequired, "must not be empty")
+		return false
+	}
+	return true
+}
+
+func (v *validation) email(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		v.add(field, CodeInvalidEmail, "must be a valid email address")
+	}
+}
+
+func (v *validation) password(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	if utf8.RuneCountInString(value) < minPasswordLength {
+		v.add(field, CodeInvalidPassword, fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	}
+	if len(value) > maxPasswordBytes {
+		v.add(field, CodeInvalidPassword, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	}
+}
+
+func (v *validation) chirpBody(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	if utf8.RuneCountInString(value) > maxChirpLength {
+		v.add(field, CodeChirpTooLong, fmt.Sprintf("must be at most %d characters", maxChirpLength))
+	}
+}
+
+func (v *validation) messageBody(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	if utf8.RuneCountInString(value) > maxMessageLength {
+		v.add(field, CodeMessageTooLong, fmt.Sprintf("must be at most %d characters", maxMessageLength))
+	}
+}
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// getReservedHandles lists handles nobody can take, they would be confused with the site or its routes
+func getReservedHandles() []string {
+	return []string{
+		"admin", "administrator", "api", "app", "chirpy", "docs", "help", "me",
+		"mod", "moderator", "polka", "root", "settings", "support", "system",
+	}
+}
+
+func (v *validation) handle(field, value string) {
+	if !v.required(field, value) {
+		return
+	}
+	n := len(value)
+	if n < minHandleLength || n > maxHandleLength || !handlePattern.MatchString(value) {
+		msg := fmt.Sprintf("must be %d to %d letters, digits or underscores", minHandleLength, maxHandleLength)
+		v.add(field, CodeInvalidHandle, msg)
+		return
+	}
+	if slices.Contains(getReservedHandles(), strings.ToLower(value)) {
+		v.add(field, CodeHandleReserved, "is reserved")
+	}
+}
+
+func (v *validation) maxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, CodeValidationFailed, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// contentWarning accepts nil and blank warnings, the chirp has no warning then
+func (v *validation) contentWarning(field string, value *string) {
+	if value != nil {
+		v.maxLength(field, *value, maxContentWarning)
+	}
+}
+
+// getSensitiveContentSettings lists how a user can have chirps with a warning shown
+func getSensitiveContentSettings() []string {
+	return []string{"collapse", "expand", "hide"}
+}
+
+func (v *validation) sensitiveContent(field, value string) {
+	if !slices.Contains(getSensitiveContentSettings(), value) {
+		v.add(field, CodeValidationFailed, "must be one of "+strings.Join(getSensitiveContentSettings(), ", "))
+	}
+}
+
+// getVisibilities lists who a chirp can be shown to
+func getVisibilities() []string {
+	return []string{visibilityPublic, visibilityUnlisted, visibilityFollowers}
+}
+
+func (v *validation) visibility(field, value string) {
+	if !slices.Contains(getVisibilities(), value) {
+		v.add(field, CodeValidationFailed, "must be one of "+strings.Join(getVisibilities(), ", "))
+	}
+}
+
+func (v *validation) collectionName(field, value string) {
+	if v.required(field, value) {
+		v.maxLength(field, value, maxCollectionName)
+	}
+}
+
+// httpURL accepts an empty value, anything else has to be an absolute http or https URL
+func (v *validation) httpURL(field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(value) > maxURLLength {
+		v.add(field, CodeValidationFailed, fmt.Sprintf("must be an http or https URL of at most %d bytes", maxURLLength))
+	}
+}
+
+func (v *validation) mediaIDs(field string, ids []uuid.UUID) {
+	if len(ids) > maxChirpMedia {
+		v.add(field, CodeValidationFailed, fmt.Sprintf("must be at most %d uploads", maxChirpMedia))
+		return
+	}
+	for i, id := range ids {
+		if slices.Contains(ids[:i], id) {
+			v.add(field, CodeValidationFailed, "must not contain an upload twice")
+			return
+		}
+	}
+}
+
+// publishAt checks the time a chirp is scheduled for, nil publishes it right away
+func (v *validation) publishAt(field string, t *time.Time) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	if !t.After(now) || t.After(now.AddDate(0, 0, maxScheduleDays)) {
+		v.add(field, CodeValidationFailed, fmt.Sprintf("must be in the future, at most %d days ahead", maxScheduleDays))
+	}
+}
+
+// poll checks the options of a new poll and that it runs at least minPollMinutes from start,
+// the longest duration depends on the author and is checked by the handler
+func (v *validation) poll(field string, p *pollParameters, start time.Time) {
+	if p == nil {
+		return
+	}
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		v.add(field+".options", CodeValidationFailed, fmt.Sprintf("must be %d to %d options", minPollOptions, maxPollOptions))
+	}
+	for i, o := range p.Options {
+		if strings.TrimSpace(o) == "" || utf8.RuneCountInString(o) > maxPollOption {
+			v.add(field+".options", CodeValidationFailed, fmt.Sprintf("must be 1 to %d characters each", maxPollOption))
+			break
+		}
+		if slices.Contains(p.Options[:i], o) {
+			v.add(field+".options", CodeValidationFailed, "must not contain an option twice")
+			break
+		}
+	}
+	if p.ClosesAt.Before(start.Add(minPollMinutes * time.Minute)) {
+		v.add(field+".closes_at", CodeValidationFailed, fmt.Sprintf("must be at least %d minutes after publishing", minPollMinutes))
+	}
+}
+
+// pollChoices checks the positions a user voted for against a poll with n options
+func (v *validation) pollChoices(field string, choices []int32, n int, multipleChoice bool) {
+	if len(choices) == 0 {
+		v.add(field, CodeRequired, "must contain an option")
+		return
+	}
+	if !multipleChoice && len(choices) > 1 {
+		v.add(field, CodeValidationFailed, "must contain one option, the poll is single choice")
+		return
+	}
+	for i, c := range choices {
+		if c < 0 || int(c) >= n {
+			v.add(field, CodeValidationFailed, fmt.Sprintf("must be positions from 0 to %d", n-1))
+			return
+		}
+		if slices.Contains(choices[:i], c) {
+			v.add(field, CodeValidationFailed, "must not contain an option twice")
+			return
+		}
+	}
+}
+
+// pageLimit parses the number of items per page, an empty value is the default size
+func (v *validation) pageLimit(field, value string) int32 {
+	if value == "" {
+		return defaultPageSize
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxPageSize {
+		v.add(field, CodeValidationFailed, fmt.Sprintf("must be a number from 1 to %d", maxPageSize))
+		return defaultPageSize
+	}
+	return int32(n)
+}
+
+// cursor parses the id of the last item of the previous page, an empty value is the first page
+func (v *validation) cursor(field, value string) uuid.UUID {
+	if value == "" {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		v.add(field, CodeInvalidID, "must be the next_cursor of a previous page")
+	}
+	return id
+}
+
+// err turns the collected field errors into a single error,
+// a lone field error keeps its own code so clients can tell e.g. chirp_too_long apart
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	code := CodeValidationFailed
+	if len(v.fields) == 1 {
+		code = v.fields[0].Code
+	}
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    code,
+		Message: "Request validation failed",
+		Fields:  v.fields,
+	}
+}
+
+// decodeJSON strictly decodes the request body into params and runs validate on the result,
+// the returned error is an *APIError ready for respondWithAPIError
+func decodeJSON(w http.ResponseWriter, r *http.Request, params any, validate func(v *validation)) error {
+	return decodeBody(w, r, params, true, validate)
+}
+
+// decodeWebhookJSON tolerates unknown fields, since the sender may add fields at any time
+func decodeWebhookJSON(w http.ResponseWriter, r *http.Request, params any) error {
+	return decodeBody(w, r, params, false, nil)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, params any, strict bool, validate func(v *validation)) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(params)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			msg := fmt.Sprintf("Request body must be at most %d bytes", maxBytesErr.Limit)
+			return newAPIError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, msg, err)
+		}
+		if errors.Is(err, io.EOF) {
+			return newAPIError(http.StatusBadRequest, CodeInvalidJSON, "Request body must not be empty", err)
+		}
+		return newAPIError(http.StatusBadRequest, CodeInvalidJSON, "Error decoding parameters: "+err.Error(), err)
+	}
+	if decoder.More() {
+		return newAPIError(http.StatusBadRequest, CodeInvalidJSON, "Request body must contain a single JSON object", nil)
+	}
+
+	if validate == nil {
+		return nil
+	}
+	v := &validation{}
+	validate(v)
+	return v.err()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type parameters struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Body     string `json:"body"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   ErrorCode
+		wantFields int
+	}{
+		{
+			name:       "Valid",
+			body:       `{"email": "user@example.com", "password": "password", "body": "hello"}`,
+			wantStatus: 0,
+		},
+		{
+			name:       "Empty body",
+			body:       ``,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Unknown field",
+			body:       `{"email": "user@example.com", "password": "password", "body": "hello", "admin": true}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Trailing data",
+			body:       `{"email": "user@example.com", "password": "password", "body": "hello"} {}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Too large",
+			body:       `{"body": "` + strings.Repeat("a", maxRequestBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   CodeRequestTooLarge,
+		},
+		{
+			name:       "Single field error keeps its code",
+			body:       `{"email": "user@example.com", "password": "password", "body": "` + strings.Repeat("a", maxChirpLength+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeChirpTooLong,
+			wantFields: 1,
+		},
+		{
+			name:       "All field errors are reported",
+			body:       `{"email": "not an email", "password": "short", "body": ""}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeValidationFailed,
+			wantFields: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			params := parameters{}
+			err := decodeJSON(w, r, &params, func(v *validation) {
+				v.email("email", params.Email)
+				v.password("password", params.Password)
+				v.chirpBody("body", params.Body)
+			})
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("decodeJSON() error = %v", err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("decodeJSON() error = %v, want an *APIError", err)
+			}
+			if apiErr.Status != tt.wantStatus || apiErr.Code != tt.wantCode {
+				t.Errorf("decodeJSON() = %d %s, want %d %s", apiErr.Status, apiErr.Code, tt.wantStatus, tt.wantCode)
+			}
+			if len(apiErr.Fields) != tt.wantFields {
+				t.Errorf("decodeJSON() fields = %v, want %d", apiErr.Fields, tt.wantFields)
+			}
+		})
+	}
+}